@@ -1,6 +1,6 @@
 package attribution
 
-// this module contains the code to create the OpenStreetMaps attribution
+// this module contains the code to create the map tile provider's attribution
 
 import (
 	"image/color"
@@ -14,11 +14,13 @@ import (
 )
 
 const (
-	ATTRIBUTION_TEXT = "© OpenStreetMap"
+	ATTRIBUTION_TEXT = "© OpenStreetMap" // default, see SetText
 )
 
 type attrib struct {
-	Img *ebiten.Image
+	Img *ebiten.Image // nil if there is no attribution to show
+
+	face font.Face
 }
 
 var MapAttribution attrib
@@ -35,17 +37,30 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	MapAttribution.face = ff
+
+	MapAttribution.SetText(ATTRIBUTION_TEXT)
+}
+
+func (a *attrib) SetText(attributionText string) {
+	// renders attributionText into a.Img, eg: the credit for the chosen tile provider
+	// not safe to call while a.Img is being drawn
+	if attributionText == "" {
+		a.Img = nil
+		return
+	}
 
 	// get size of text
-	attribRect := text.BoundString(ff, ATTRIBUTION_TEXT)
+	attribRect := text.BoundString(a.face, attributionText)
 
 	// prepare new image
-	MapAttribution.Img = ebiten.NewImage(attribRect.Dx()+10, attribRect.Dy()+10)
-	MapAttribution.Img.Fill(color.RGBA{R: 0, G: 0, B: 0, A: 128})
+	img := ebiten.NewImage(attribRect.Dx()+10, attribRect.Dy()+10)
+	img.Fill(color.RGBA{R: 0, G: 0, B: 0, A: 128})
 
 	// where to render text
-	x := (MapAttribution.Img.Bounds().Dx() / 2) - (attribRect.Dx() / 2)
-	y := (MapAttribution.Img.Bounds().Dy() / 2) + (attribRect.Dy() / 2)
-	text.Draw(MapAttribution.Img, ATTRIBUTION_TEXT, ff, x, y, color.White)
+	x := (img.Bounds().Dx() / 2) - (attribRect.Dx() / 2)
+	y := (img.Bounds().Dy() / 2) + (attribRect.Dy() / 2)
+	text.Draw(img, attributionText, a.face, x, y, color.White)
 
+	a.Img = img
 }
@@ -0,0 +1,152 @@
+package main
+
+// feedmonitor is a headless feed monitor: it runs the same data sources as pw-slippymap without a display,
+// logging aircraft events and a periodic summary of the aircraft & data source health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"pw_slippymap/datasources"
+	"pw_slippymap/datasources/sourceflags"
+	"pw_slippymap/geofence"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/akamensky/argparse"
+)
+
+const (
+	SUMMARY_INTERVAL_SECONDS = 10 // how often to log the summary
+)
+
+func main() {
+
+	// process the command line
+	parser := argparse.NewParser("pw-feedmonitor", "headless feed monitor for plane.watch")
+	dataSourceFlags := sourceflags.Add(parser)
+	geofencePaths := parser.StringList("", "geofence", &argparse.Options{Required: false, Help: "Logs aircraft entering or leaving the Polygon/MultiPolygon features in a GeoJSON file. Can be given multiple times."})
+	geofenceWebhook := parser.String("", "geofencewebhook", &argparse.Options{Required: false, Help: "POSTs geofence alerts as JSON to this URL"})
+	quiet := parser.Flag("q", "quiet", &argparse.Options{Required: false, Help: "Only log the summary, not position updates"})
+	err := parser.Parse(os.Args)
+	if err != nil {
+		fmt.Print(parser.Usage(err))
+		os.Exit(1)
+	}
+
+	adb := datasources.NewAircraftDB(datasources.FORGET_AIRCRAFT_AFTER_SECONDS)
+
+	// initialise data sources
+	dataSources, _, err := dataSourceFlags.DataSources(adb, nil)
+	if err != nil {
+		log.Fatalf("could not initialise datasources because: %s", err.Error())
+	}
+	if len(dataSources) == 0 {
+		fmt.Print(parser.Usage("no data sources given"))
+		os.Exit(1)
+	}
+
+	// stop on ctrl-c or kill
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// load the aircraft registration & type database in the background, as it may need downloading
+	go dataSourceFlags.LoadAircraftDB(ctx, adb)
+
+	// subscribe before starting, so no events are missed
+	sub := adb.Subscribe(0)
+	defer sub.Unsubscribe()
+
+	// watch geofences
+	var fences []*geofence.Fence
+	for _, p := range *geofencePaths {
+		f, err := geofence.Load(p)
+		if err != nil {
+			log.Fatalf("could not load geofences from %s because: %s", p, err.Error())
+		}
+		fences = append(fences, f...)
+	}
+	if len(fences) > 0 {
+		notifiers := []geofence.Notifier{geofence.LogNotifier{}}
+		if *geofenceWebhook != "" {
+			notifiers = append(notifiers, geofence.NewWebhookNotifier(*geofenceWebhook))
+		}
+		go geofence.NewMonitor(fences, notifiers...).Run(ctx, adb)
+	}
+
+	for _, ds := range dataSources {
+		err = ds.Start(ctx)
+		if err != nil {
+			log.Fatalf("could not start datasource %s because: %s", ds.Name(), err.Error())
+		}
+	}
+
+	summary := time.NewTicker(time.Second * SUMMARY_INTERVAL_SECONDS)
+	defer summary.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			for _, ds := range dataSources {
+				ds.Stop()
+			}
+			return
+
+		case e := <-sub.C:
+			if *quiet && e.Type == datasources.EVENT_POSITION_UPDATED {
+				continue
+			}
+			logEvent(e)
+
+		case <-summary.C:
+			logSummary(adb, dataSources, sub)
+		}
+	}
+}
+
+func logEvent(e datasources.Event) {
+	// logs an AircraftDB event
+	a := e.Aircraft
+	switch e.Type {
+	case datasources.EVENT_APPEARED:
+		log.Printf("%06X %-8s %s: %s %s %s", e.ICAO, a.Callsign, e.Type, a.Registration, a.AircraftType, a.Operator)
+	case datasources.EVENT_POSITION_UPDATED:
+		log.Printf("%06X %-8s %s: %.5f, %.5f, %d ft", e.ICAO, a.Callsign, e.Type, a.Lat, a.Long, a.AltBaro)
+	case datasources.EVENT_SQUAWK_CHANGED:
+		log.Printf("%06X %-8s %s: %04X", e.ICAO, a.Callsign, e.Type, a.Squawk)
+	case datasources.EVENT_EMERGENCY:
+		log.Printf("%06X %-8s %s: %s, squawk %04X", e.ICAO, a.Callsign, e.Type, datasources.EmergencyDescription(a.Emergency), a.Squawk)
+	default:
+		log.Printf("%06X %-8s %s", e.ICAO, a.Callsign, e.Type)
+	}
+}
+
+func logSummary(adb *datasources.AircraftDB, dataSources []datasources.DataSource, sub *datasources.Subscription) {
+	// logs the number of aircraft, and the health of each data source
+	aircraft := adb.GetAircraft()
+	withPosition := 0
+	for _, a := range aircraft {
+		if a.Lat != 0 || a.Long != 0 {
+			withPosition++
+		}
+	}
+	log.Printf("Summary: %d aircraft, %d with position, %d events dropped", len(aircraft), withPosition, sub.Dropped())
+
+	names := make([]string, 0, len(dataSources))
+	health := make(map[string]datasources.DataSourceHealth)
+	for _, ds := range dataSources {
+		names = append(names, ds.Name())
+		health[ds.Name()] = ds.Health()
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := health[name]
+		if h.LastError != nil {
+			log.Printf("Summary: %s: %s, %d updates, %d errors (%s)", name, h.Status, h.Updates, h.Errors, h.LastError)
+		} else {
+			log.Printf("Summary: %s: %s, %d updates, %d errors", name, h.Status, h.Updates, h.Errors)
+		}
+	}
+}
@@ -3,18 +3,190 @@ package datasources
 import (
 	_ "embed"
 
+	"sort"
 	"sync"
 	"time"
 
 	"pw_slippymap/datasources/readsb_protobuf"
-
-	"github.com/hajimehoshi/ebiten/v2"
 )
 
 const (
 	FORGET_AIRCRAFT_AFTER_SECONDS = 60
+	RECEIVER_TIMEOUT_SECONDS      = 30 // a receiver that hasn't reported an aircraft for this long no longer sees it
 )
 
+// FieldSource is where and when a value in the AircraftDB came from
+type FieldSource struct {
+	Source string    // name of the data source, eg: DataSource.Name()
+	Time   time.Time // when the value was received by the source
+}
+
+func (fs FieldSource) supersedes(current FieldSource) bool {
+	// returns true if a value from fs should replace a value from current
+	// older values are ignored, so out-of-order or stale updates from other receivers don't overwrite newer data
+	return !fs.Time.Before(current.Time)
+}
+
+// NavModes are the autopilot modes engaged, as reported by the aircraft
+type NavModes struct {
+	Autopilot bool
+	Vnav      bool
+	Althold   bool
+	Approach  bool
+	Lnav      bool
+	Tcas      bool
+}
+
+// AircraftMeta is the rest of the readsb AircraftMeta, only received from readsb data sources
+type AircraftMeta struct {
+	AltGeom        int     // geometric (GNSS / INS) altitude, feet
+	BaroRate       int     // rate of change of barometric altitude, feet/minute
+	GeomRate       int     // rate of change of geometric altitude, feet/minute
+	Ias            int     // indicated air speed, knots
+	Tas            int     // true air speed, knots
+	Mach           float32 // mach number
+	MagHeading     int     // heading, degrees clockwise from magnetic north
+	TrueHeading    int     // heading, degrees clockwise from true north
+	TrackRate      float32 // rate of change of track, degrees/second
+	Roll           float32 // degrees, negative is left roll
+	NavQnh         float32 // altimeter setting, hPa
+	NavAltitudeMcp int     // selected altitude from the MCP/FCU, feet
+	NavAltitudeFms int     // selected altitude from the FMS, feet
+	NavHeading     int     // selected heading, degrees
+	NavModes       NavModes
+	Emergency      readsb_protobuf.AircraftMeta_Emergency
+	Nic            int // navigation integrity category
+	NicBaro        int // navigation integrity category for barometric altitude
+	Rc             int // radius of containment, meters
+	NacP           int // navigation accuracy for position
+	NacV           int // navigation accuracy for velocity
+	Sil            int // source integrity level
+	SilType        readsb_protobuf.AircraftMeta_SilType
+	Version        int     // ADS-B version
+	Rssi           float32 // recent average signal power, dbFS
+	Distance       int     // distance from the receiver, meters
+	Messages       uint64  // number of Mode S messages received
+	WindSpeed      int     // calculated wind speed, knots
+	WindDirection  int     // calculated wind direction, degrees
+	Alert          bool    // flight status alert bit
+	Spi            bool    // flight status special position identification bit
+}
+
+func (m AircraftMeta) VerticalRate() int {
+	// Returns the barometric vertical rate in feet/minute, or the geometric rate if there's no barometric rate
+	if m.BaroRate == 0 {
+		return m.GeomRate
+	}
+	return m.BaroRate
+}
+
+// MetaFields is a set of AircraftMeta fields, for data sources that only have some of them
+type MetaFields uint64
+
+const (
+	META_ALT_GEOM MetaFields = 1 << iota
+	META_BARO_RATE
+	META_GEOM_RATE
+	META_IAS
+	META_TAS
+	META_MACH
+	META_MAG_HEADING
+	META_TRUE_HEADING
+	META_TRACK_RATE
+	META_ROLL
+	META_NAV_QNH
+	META_NAV_ALTITUDE_MCP
+	META_NAV_ALTITUDE_FMS
+	META_NAV_HEADING
+	META_NAV_MODES
+	META_EMERGENCY
+	META_NIC
+	META_NIC_BARO
+	META_RC
+	META_NAC_P
+	META_NAC_V
+	META_SIL
+	META_SIL_TYPE
+	META_VERSION
+	META_RSSI
+	META_DISTANCE
+	META_MESSAGES
+	META_WIND_SPEED
+	META_WIND_DIRECTION
+	META_ALERT
+	META_SPI
+
+	META_ALL = META_SPI<<1 - 1
+)
+
+func (m *AircraftMeta) setField(field MetaFields, from *AircraftMeta) {
+	// sets the single field in m to its value in from
+	switch field {
+	case META_ALT_GEOM:
+		m.AltGeom = from.AltGeom
+	case META_BARO_RATE:
+		m.BaroRate = from.BaroRate
+	case META_GEOM_RATE:
+		m.GeomRate = from.GeomRate
+	case META_IAS:
+		m.Ias = from.Ias
+	case META_TAS:
+		m.Tas = from.Tas
+	case META_MACH:
+		m.Mach = from.Mach
+	case META_MAG_HEADING:
+		m.MagHeading = from.MagHeading
+	case META_TRUE_HEADING:
+		m.TrueHeading = from.TrueHeading
+	case META_TRACK_RATE:
+		m.TrackRate = from.TrackRate
+	case META_ROLL:
+		m.Roll = from.Roll
+	case META_NAV_QNH:
+		m.NavQnh = from.NavQnh
+	case META_NAV_ALTITUDE_MCP:
+		m.NavAltitudeMcp = from.NavAltitudeMcp
+	case META_NAV_ALTITUDE_FMS:
+		m.NavAltitudeFms = from.NavAltitudeFms
+	case META_NAV_HEADING:
+		m.NavHeading = from.NavHeading
+	case META_NAV_MODES:
+		m.NavModes = from.NavModes
+	case META_EMERGENCY:
+		m.Emergency = from.Emergency
+	case META_NIC:
+		m.Nic = from.Nic
+	case META_NIC_BARO:
+		m.NicBaro = from.NicBaro
+	case META_RC:
+		m.Rc = from.Rc
+	case META_NAC_P:
+		m.NacP = from.NacP
+	case META_NAC_V:
+		m.NacV = from.NacV
+	case META_SIL:
+		m.Sil = from.Sil
+	case META_SIL_TYPE:
+		m.SilType = from.SilType
+	case META_VERSION:
+		m.Version = from.Version
+	case META_RSSI:
+		m.Rssi = from.Rssi
+	case META_DISTANCE:
+		m.Distance = from.Distance
+	case META_MESSAGES:
+		m.Messages = from.Messages
+	case META_WIND_SPEED:
+		m.WindSpeed = from.WindSpeed
+	case META_WIND_DIRECTION:
+		m.WindDirection = from.WindDirection
+	case META_ALERT:
+		m.Alert = from.Alert
+	case META_SPI:
+		m.Spi = from.Spi
+	}
+}
+
 type Aircraft struct {
 	Callsign     string
 	Lat          float64
@@ -26,19 +198,110 @@ type Aircraft struct {
 	Category     int
 	GroundSpeed  int
 	AirGround    readsb_protobuf.AircraftMeta_AirGround
+	Squawk       int // 4 octal digits, stored as if they were hex, eg: 7700 is 0x7700
+	Meta         AircraftMeta
+	Emergency    readsb_protobuf.AircraftMeta_Emergency // from Meta.Emergency, or an emergency squawk
+	FlightPhase  FlightPhase                            // from air/ground, speed, altitude & vertical rate (see flightphase.go)
 	History      []AircraftHistoryLocation
-}
 
-type AircraftHistoryLocation struct {
-	Lat  float64
-	Long float64
-	Alt  int
+	// looked up from the readsb database, by ICAO address & callsign
+	Registration      string
+	Model             string // eg: "BOEING 737-800"
+	TypeClass         string // eg: "L2J" (landplane, 2 engines, jet)
+	WakeCategory      string // L, M, H or J
+	Operator          string
+	OperatorCountry   string
+	OperatorTelephony string // radio callsign, eg: "QANTAS"
+
+	// decoded from the ICAO address allocation (see icaocountry.go)
+	Country     string // country of registration
+	CountryCode string // ISO 3166-1 alpha-2, eg: "AU"
+	Military    bool   // ICAO address is in a military block
+
+	// where each value came from
+	CallsignSource    FieldSource
+	PositionSource    FieldSource
+	TrackSource       FieldSource
+	CategorySource    FieldSource
+	GroundSpeedSource FieldSource
+	AirGroundSource   FieldSource
+	SquawkSource      FieldSource
+	MetaSource        FieldSource // the most recent of Meta's fields
+
+	// data sources currently seeing this aircraft (populated by GetAircraft)
+	Receivers []string
+
+	// when each data source last reported this aircraft
+	receivers map[string]time.Time
+
+	// where each field of Meta came from
+	metaSources map[MetaFields]FieldSource
+
+	appeared      bool                                   // EVENT_APPEARED has been emitted
+	pendingEvents []Event                                // events held until EVENT_APPEARED
+	lastAirGround readsb_protobuf.AircraftMeta_AirGround // last definite air/ground state, for EVENT_ON_GROUND & EVENT_AIRBORNE
+	phaseOnGround bool                                   // FlightPhase was worked out while on the ground
+	phaseSince    time.Time                              // when FlightPhase last changed
 }
 
 type AircraftDB struct {
 	Aircraft    map[int]*Aircraft
 	Mutex       sync.Mutex
 	idleTimeout int64 // seconds
+	retention   HistoryRetention
+	subscribers map[*Subscription]struct{}
+	onChange    func()
+	readsbDB    *ReadsbDB // registration & type lookups, nil until loaded
+}
+
+func (a *Aircraft) currentReceivers() []string {
+	// returns the sorted names of the data sources that have reported the aircraft recently
+	receivers := make([]string, 0, len(a.receivers))
+	for name, lastSeen := range a.receivers {
+		if time.Since(lastSeen) <= time.Second*RECEIVER_TIMEOUT_SECONDS {
+			receivers = append(receivers, name)
+		}
+	}
+	sort.Strings(receivers)
+	return receivers
+}
+
+func (a *Aircraft) snapshot() Aircraft {
+	// returns a copy of the aircraft, without History
+	return Aircraft{
+		Callsign:          a.Callsign,
+		Lat:               a.Lat,
+		Long:              a.Long,
+		Track:             a.Track,
+		AircraftType:      a.AircraftType,
+		AltBaro:           a.AltBaro,
+		Category:          a.Category,
+		GroundSpeed:       a.GroundSpeed,
+		AirGround:         a.AirGround,
+		Squawk:            a.Squawk,
+		Meta:              a.Meta,
+		Emergency:         a.Emergency,
+		FlightPhase:       a.FlightPhase,
+		Registration:      a.Registration,
+		Model:             a.Model,
+		TypeClass:         a.TypeClass,
+		WakeCategory:      a.WakeCategory,
+		Operator:          a.Operator,
+		OperatorCountry:   a.OperatorCountry,
+		OperatorTelephony: a.OperatorTelephony,
+		Country:           a.Country,
+		CountryCode:       a.CountryCode,
+		Military:          a.Military,
+		CallsignSource:    a.CallsignSource,
+		PositionSource:    a.PositionSource,
+		TrackSource:       a.TrackSource,
+		CategorySource:    a.CategorySource,
+		GroundSpeedSource: a.GroundSpeedSource,
+		AirGroundSource:   a.AirGroundSource,
+		SquawkSource:      a.SquawkSource,
+		MetaSource:        a.MetaSource,
+		Receivers:         a.currentReceivers(),
+	}
 }
 
 func (adb *AircraftDB) GetAircraft() map[int]Aircraft {
@@ -46,125 +309,309 @@ func (adb *AircraftDB) GetAircraft() map[int]Aircraft {
 	adb.Mutex.Lock()
 	defer adb.Mutex.Unlock()
 	for k, v := range adb.Aircraft {
-		output[k] = Aircraft{
-			Callsign:     v.Callsign,
-			Lat:          v.Lat,
-			Long:         v.Long,
-			Track:        v.Track,
-			AircraftType: v.AircraftType,
-			AltBaro:      v.AltBaro,
-			Category:     v.Category,
-			GroundSpeed:  v.GroundSpeed,
-			AirGround:    v.AirGround,
-		}
+		output[k] = v.snapshot()
 	}
 	return output
 }
 
-func (adb *AircraftDB) newAircraft(icao int) {
+func (adb *AircraftDB) SetOnChange(onChange func()) {
+	// sets a function called whenever anything in the AircraftDB changes, eg: ebiten.ScheduleFrame
+	// onChange is called with adb.Mutex held, so must be quick and must not call AircraftDB methods
 	adb.Mutex.Lock()
-	_, icaoInDB := adb.Aircraft[icao]
-	adb.Mutex.Unlock()
-	if !icaoInDB {
+	defer adb.Mutex.Unlock()
+	adb.onChange = onChange
+}
 
-		// lookup aircraft type
-		aircraftType := readsbAircraft[icao].aircraftType
+func (adb *AircraftDB) changed() {
+	// calls the onChange hook, if set
+	// adb.Mutex must be held
+	if adb.onChange != nil {
+		adb.onChange()
+	}
+}
 
-		adb.Mutex.Lock()
-		defer adb.Mutex.Unlock()
+func (adb *AircraftDB) aircraft(icao int) *Aircraft {
+	// returns the aircraft icao, creating it if it doesn't exist
+	// adb.Mutex must be held
+	a, icaoInDB := adb.Aircraft[icao]
+	if !icaoInDB {
 
 		// create Aircraft object
-		adb.Aircraft[icao] = &Aircraft{
-			AircraftType: aircraftType,
+		a = &Aircraft{
+			History:     make([]AircraftHistoryLocation, 0),
+			receivers:   make(map[string]time.Time),
+			metaSources: make(map[MetaFields]FieldSource),
 		}
 
-		// init History slice
-		adb.Aircraft[icao].History = make([]AircraftHistoryLocation, 0)
+		// lookup registration & aircraft type
+		a.enrichFromICAO(icao, adb.readsbDB)
+		a.Country, a.CountryCode, _ = ICAOCountry(icao)
+		a.Military = ICAOMilitary(icao)
+		adb.Aircraft[icao] = a
+	}
+	return a
+}
+
+func (adb *AircraftDB) SetReadsbDB(db *ReadsbDB) {
+	// sets the readsb aircraft database used to look up registration & aircraft type, including for
+	// aircraft already in the AircraftDB (as the database is usually loaded in the background)
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	defer adb.changed()
+	adb.readsbDB = db
+	for icao, a := range adb.Aircraft {
+		a.enrichFromICAO(icao, db)
+	}
+}
+
+func (adb *AircraftDB) SetHistoryRetention(r HistoryRetention) {
+	// sets how much track history is kept for each aircraft
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	adb.retention = r
+	for _, a := range adb.Aircraft {
+		a.History = r.apply(a.History)
 	}
 }
 
-func (adb *AircraftDB) AddHistory(icao int, lat, long float64, alt int) {
-	adb.newAircraft(icao)
+func (adb *AircraftDB) AddHistory(icao int, ahl AircraftHistoryLocation) {
+	// adds a point to the aircraft's track, eg: from readsb history
 	adb.Mutex.Lock()
 	defer adb.Mutex.Unlock()
-	ahl := AircraftHistoryLocation{
-		Lat:  lat,
-		Long: long,
-		Alt:  alt,
+	a := adb.aircraft(icao)
+	a.History = adb.retention.apply(insertHistory(a.History, ahl))
+}
+
+func (adb *AircraftDB) GetHistory(icao int) []AircraftHistoryLocation {
+	// returns a copy of the aircraft's track, oldest first
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a, ok := adb.Aircraft[icao]
+	if !ok {
+		return nil
 	}
-	adb.Aircraft[icao].History = append(adb.Aircraft[icao].History, ahl)
+	return append([]AircraftHistoryLocation(nil), a.History...)
 }
 
-func (adb *AircraftDB) SetCallsign(icao int, callsign string) {
-	adb.newAircraft(icao)
-	if adb.Aircraft[icao].Callsign != callsign {
-		defer ebiten.ScheduleFrame()
-		adb.Mutex.Lock()
-		defer adb.Mutex.Unlock()
+func (adb *AircraftDB) SetCallsign(icao int, callsign string, fs FieldSource) {
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	if !fs.supersedes(a.CallsignSource) {
+		return
+	}
+	a.CallsignSource = fs
+	if a.Callsign != callsign {
+		defer adb.changed()
 		// log.Printf("AircraftDB[%X]: Updated callsign to: %s", icao, callsign)
-		adb.Aircraft[icao].Callsign = callsign
+		a.Callsign = callsign
+		a.enrichFromCallsign()
+		adb.emit(EVENT_CALLSIGN_CHANGED, icao, a, fs.Time)
 	}
 }
 
-func (adb *AircraftDB) SetPosition(icao int, lat, long float64, altBaro int) {
-	adb.newAircraft(icao)
-	if adb.Aircraft[icao].Lat != lat || adb.Aircraft[icao].Long != long || adb.Aircraft[icao].AltBaro != altBaro {
-		defer ebiten.ScheduleFrame()
-		adb.Mutex.Lock()
-		defer adb.Mutex.Unlock()
-		adb.Aircraft[icao].Lat = lat
-		adb.Aircraft[icao].Long = long
-		adb.Aircraft[icao].AltBaro = altBaro
-		go adb.AddHistory(icao, lat, long, altBaro)
+func (adb *AircraftDB) SetPosition(icao int, lat, long float64, altBaro int, fs FieldSource) {
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	adb.setPosition(icao, adb.aircraft(icao), lat, long, altBaro, fs)
+}
+
+func (adb *AircraftDB) SetLatLong(icao int, lat, long float64, fs FieldSource) {
+	// sets the position of an aircraft from a source without its altitude, keeping the altitude it had
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	adb.setPosition(icao, a, lat, long, a.AltBaro, fs)
+}
+
+func (adb *AircraftDB) setPosition(icao int, a *Aircraft, lat, long float64, altBaro int, fs FieldSource) {
+	// adb.Mutex must be held
+	if !fs.supersedes(a.PositionSource) {
+		return
+	}
+	a.PositionSource = fs
+	if a.Lat != lat || a.Long != long || a.AltBaro != altBaro {
+		defer adb.changed()
+		a.Lat = lat
+		a.Long = long
+		a.AltBaro = altBaro
+		a.History = adb.retention.apply(insertHistory(a.History, AircraftHistoryLocation{
+			Time:        fs.Time,
+			Lat:         lat,
+			Long:        long,
+			Alt:         altBaro,
+			GroundSpeed: a.GroundSpeed,
+			Track:       a.Track,
+		}))
+		adb.emit(EVENT_POSITION_UPDATED, icao, a, fs.Time)
+		adb.updateFlightPhase(icao, a, fs)
 	}
 }
 
-func (adb *AircraftDB) SetTrack(icao int, track int) {
-	adb.newAircraft(icao)
-	if adb.Aircraft[icao].Track != track {
-		defer ebiten.ScheduleFrame()
-		adb.Mutex.Lock()
-		defer adb.Mutex.Unlock()
+func (adb *AircraftDB) SetTrack(icao int, track int, fs FieldSource) {
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	if !fs.supersedes(a.TrackSource) {
+		return
+	}
+	a.TrackSource = fs
+	if a.Track != track {
+		defer adb.changed()
 		// log.Printf("AircraftDB[%X]: Updated track to: %d", icao, track)
-		adb.Aircraft[icao].Track = track
+		a.Track = track
 	}
 }
 
-func (adb *AircraftDB) SetCategory(icao int, category int) {
-	adb.newAircraft(icao)
-	if adb.Aircraft[icao].Category != category {
-		defer ebiten.ScheduleFrame()
-		adb.Mutex.Lock()
-		defer adb.Mutex.Unlock()
-		adb.Aircraft[icao].Category = category
+func (adb *AircraftDB) SetCategory(icao int, category int, fs FieldSource) {
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	if !fs.supersedes(a.CategorySource) {
+		return
+	}
+	a.CategorySource = fs
+	if a.Category != category {
+		defer adb.changed()
+		a.Category = category
 	}
 }
 
-func (adb *AircraftDB) SetGs(icao int, gs int) {
-	adb.newAircraft(icao)
-	if adb.Aircraft[icao].GroundSpeed != gs {
-		defer ebiten.ScheduleFrame()
-		adb.Mutex.Lock()
-		defer adb.Mutex.Unlock()
-		adb.Aircraft[icao].GroundSpeed = gs
+func (adb *AircraftDB) SetGs(icao int, gs int, fs FieldSource) {
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	if !fs.supersedes(a.GroundSpeedSource) {
+		return
+	}
+	a.GroundSpeedSource = fs
+	if a.GroundSpeed != gs {
+		defer adb.changed()
+		a.GroundSpeed = gs
+		adb.updateFlightPhase(icao, a, fs)
 	}
 }
 
-func (adb *AircraftDB) SetAirGround(icao int, ag readsb_protobuf.AircraftMeta_AirGround) {
-	adb.newAircraft(icao)
-	if adb.Aircraft[icao].AirGround != ag {
-		defer ebiten.ScheduleFrame()
-		adb.Mutex.Lock()
-		defer adb.Mutex.Unlock()
-		adb.Aircraft[icao].AirGround = ag
+func (adb *AircraftDB) SetAirGround(icao int, ag readsb_protobuf.AircraftMeta_AirGround, fs FieldSource) {
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	if !fs.supersedes(a.AirGroundSource) {
+		return
+	}
+	a.AirGroundSource = fs
+	if a.AirGround != ag {
+		defer adb.changed()
+		a.AirGround = ag
+	}
+
+	// only transitions between definite states are events, so uncertain reports don't cause spurious ones
+	if ag == readsb_protobuf.AircraftMeta_AG_GROUND || ag == readsb_protobuf.AircraftMeta_AG_AIRBORNE {
+		previous := a.lastAirGround
+		a.lastAirGround = ag
+		switch {
+		case previous == readsb_protobuf.AircraftMeta_AG_AIRBORNE && ag == readsb_protobuf.AircraftMeta_AG_GROUND:
+			adb.emit(EVENT_ON_GROUND, icao, a, fs.Time)
+		case previous == readsb_protobuf.AircraftMeta_AG_GROUND && ag == readsb_protobuf.AircraftMeta_AG_AIRBORNE:
+			adb.emit(EVENT_AIRBORNE, icao, a, fs.Time)
+		}
+		adb.updateFlightPhase(icao, a, fs)
 	}
 }
 
-func (adb *AircraftDB) SetLastSeen(icao int) {
-	adb.newAircraft(icao)
+func (adb *AircraftDB) SetSquawk(icao int, squawk int, fs FieldSource) {
 	adb.Mutex.Lock()
 	defer adb.Mutex.Unlock()
-	adb.Aircraft[icao].LastUpdated = time.Now().Unix()
+	a := adb.aircraft(icao)
+	if !fs.supersedes(a.SquawkSource) {
+		return
+	}
+	a.SquawkSource = fs
+	if a.Squawk != squawk {
+		defer adb.changed()
+		a.Squawk = squawk
+		adb.emit(EVENT_SQUAWK_CHANGED, icao, a, fs.Time)
+		adb.updateEmergency(icao, a, fs)
+	}
+}
+
+func (adb *AircraftDB) SetMeta(icao int, meta AircraftMeta, fs FieldSource) {
+	// sets every field of Aircraft.Meta
+	adb.MergeMeta(icao, meta, META_ALL, fs)
+}
+
+func (adb *AircraftDB) MergeMeta(icao int, meta AircraftMeta, fields MetaFields, fs FieldSource) {
+	// sets the fields of Aircraft.Meta in fields from meta, leaving the others as they were
+	// each field is only replaced by a newer value, so a receiver that only has some fields doesn't overwrite another's
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	merged := a.Meta
+	applied := false
+	for field := MetaFields(1); field <= META_SPI; field <<= 1 {
+		if fields&field == 0 || !fs.supersedes(a.metaSources[field]) {
+			continue
+		}
+		a.metaSources[field] = fs
+		merged.setField(field, &meta)
+		applied = true
+	}
+	if !applied {
+		return
+	}
+	if fs.supersedes(a.MetaSource) {
+		a.MetaSource = fs
+	}
+	if a.Meta != merged {
+		defer adb.changed()
+		a.Meta = merged
+		adb.updateEmergency(icao, a, fs)
+		adb.updateFlightPhase(icao, a, fs)
+	}
+}
+
+func (adb *AircraftDB) SetLastSeen(icao int, fs FieldSource) {
+	// records that the data source fs.Source has seen the aircraft
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a := adb.aircraft(icao)
+	a.LastUpdated = time.Now().Unix()
+	a.receivers[fs.Source] = time.Now()
+
+	// sources call SetLastSeen after the other setters, so the event has what's known about the aircraft,
+	// and is followed by the events from those setters (see emit)
+	if !a.appeared {
+		a.appeared = true
+		adb.emit(EVENT_APPEARED, icao, a, fs.Time)
+	}
+}
+
+func (adb *AircraftDB) Remove(icao int) {
+	// forgets the aircraft, eg: when its data source says it has gone
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	a, ok := adb.Aircraft[icao]
+	if !ok {
+		return
+	}
+	defer adb.changed()
+	if a.appeared {
+		adb.emit(EVENT_TIMED_OUT, icao, a, time.Now())
+	}
+	delete(adb.Aircraft, icao)
+}
+
+func (adb *AircraftDB) Clear() {
+	// forgets all aircraft
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	defer adb.changed()
+	for icao, a := range adb.Aircraft {
+		if a.appeared {
+			adb.emit(EVENT_TIMED_OUT, icao, a, time.Now())
+		}
+	}
+	adb.Aircraft = make(map[int]*Aircraft)
 }
 
 func (adb *AircraftDB) forgetter() {
@@ -182,6 +629,10 @@ func (adb *AircraftDB) forgetter() {
 		// forget entries with LastUpdated older than adb.idleTimeout
 		if time.Now().Unix() > adb.Aircraft[k].LastUpdated+adb.idleTimeout {
 			// log.Printf("AircraftDB[%X]: Forgetting inactive aircraft", k)
+			if adb.Aircraft[k].appeared {
+				adb.emit(EVENT_TIMED_OUT, k, adb.Aircraft[k], time.Now())
+			}
+			defer adb.changed()
 			defer delete(adb.Aircraft, k)
 		}
 	}
@@ -194,6 +645,8 @@ func NewAircraftDB(idleTimeout int64) *AircraftDB {
 	// Initialises and returns a pointer to an aircraft db
 	adb := AircraftDB{
 		idleTimeout: idleTimeout,
+		retention:   DefaultHistoryRetention(),
+		subscribers: make(map[*Subscription]struct{}),
 	}
 	adb.Aircraft = make(map[int]*Aircraft)
 	go adb.forgetter()
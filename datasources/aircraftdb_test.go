@@ -2,10 +2,12 @@ package datasources
 
 import (
 	"log"
+	"pw_slippymap/datasources/readsb_protobuf"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAircraftDB(t *testing.T) {
@@ -15,17 +17,17 @@ func TestAircraftDB(t *testing.T) {
 	adb := NewAircraftDB(2)
 	assert.IsType(t, &AircraftDB{}, adb)
 
-	adb.SetCallsign(0xAAAAAA, "TEST1")
-	adb.SetLat(0xAAAAAA, -31.9523)
-	adb.SetLong(0xAAAAAA, 115.8613)
-	adb.SetTrack(0xAAAAAA, 123)
-	adb.SetLastSeen(0xAAAAAA)
+	fs := FieldSource{Source: "test", Time: time.Now()}
 
-	adb.SetCallsign(0x7C1465, "TEST2")
-	adb.SetLat(0x7C1465, -31.9523)
-	adb.SetLong(0x7C1465, 115.8613)
-	adb.SetTrack(0x7C1465, 123)
-	adb.SetLastSeen(0x7C1465)
+	adb.SetCallsign(0xAAAAAA, "TEST1", fs)
+	adb.SetPosition(0xAAAAAA, -31.9523, 115.8613, 1000, fs)
+	adb.SetTrack(0xAAAAAA, 123, fs)
+	adb.SetLastSeen(0xAAAAAA, fs)
+
+	adb.SetCallsign(0x7C1465, "TEST2", fs)
+	adb.SetPosition(0x7C1465, -31.9523, 115.8613, 1000, fs)
+	adb.SetTrack(0x7C1465, 123, fs)
+	adb.SetLastSeen(0x7C1465, fs)
 
 	output := adb.GetAircraft()
 
@@ -35,6 +37,12 @@ func TestAircraftDB(t *testing.T) {
 	assert.Equal(t, 123, output[0xAAAAAA].Track)
 	assert.Equal(t, "", output[0xAAAAAA].AircraftType)
 
+	adb.Remove(0x7C1465)
+	adb.Remove(0x123456)
+	output = adb.GetAircraft()
+	assert.NotContains(t, output, 0x7C1465)
+	assert.Contains(t, output, 0xAAAAAA)
+
 	log.Println("Waiting for forgetter...")
 	time.Sleep(time.Second * 5)
 
@@ -43,3 +51,159 @@ func TestAircraftDB(t *testing.T) {
 	assert.Equal(t, false, ok)
 
 }
+
+func TestAircraftDBMerge(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	now := time.Now()
+	rx1 := FieldSource{Source: "rx1", Time: now}
+	rx2 := FieldSource{Source: "rx2", Time: now.Add(time.Second)}
+	rx1Old := FieldSource{Source: "rx1", Time: now.Add(-time.Second)}
+
+	adb.SetPosition(0x7C79CA, -32.1, 115.8, 1000, rx1)
+	adb.SetCallsign(0x7C79CA, "HARR89", rx1)
+	adb.SetLastSeen(0x7C79CA, rx1)
+
+	t.Run("Test newer update replaces older", func(t *testing.T) {
+		adb.SetPosition(0x7C79CA, -32.2, 115.9, 1100, rx2)
+		adb.SetLastSeen(0x7C79CA, rx2)
+		a := adb.GetAircraft()[0x7C79CA]
+		assert.Equal(t, -32.2, a.Lat)
+		assert.Equal(t, 1100, a.AltBaro)
+		assert.Equal(t, rx2, a.PositionSource)
+		assert.Equal(t, rx1, a.CallsignSource)
+	})
+
+	t.Run("Test out-of-order update is ignored", func(t *testing.T) {
+		adb.SetPosition(0x7C79CA, -32.0, 115.7, 900, rx1Old)
+		adb.SetPosition(0x7C79CA, -32.0, 115.7, 900, rx1)
+		adb.SetCallsign(0x7C79CA, "STALE", rx1Old)
+		a := adb.GetAircraft()[0x7C79CA]
+		assert.Equal(t, -32.2, a.Lat)
+		assert.Equal(t, rx2, a.PositionSource)
+		assert.Equal(t, "HARR89", a.Callsign)
+	})
+
+	t.Run("Test history only has applied positions", func(t *testing.T) {
+		history := adb.GetHistory(0x7C79CA)
+		require.Len(t, history, 2)
+		assert.Equal(t, rx1.Time, history[0].Time)
+		assert.Equal(t, rx2.Time, history[1].Time)
+		assert.Nil(t, adb.GetHistory(0x123456))
+	})
+
+	t.Run("Test history retention", func(t *testing.T) {
+		adb.AddHistory(0x7C79CA, AircraftHistoryLocation{Time: now.Add(-time.Minute * 30)})
+		assert.Len(t, adb.GetHistory(0x7C79CA), 3)
+		adb.SetHistoryRetention(HistoryRetention{MaxAge: time.Minute})
+		assert.Len(t, adb.GetHistory(0x7C79CA), 2)
+	})
+
+	t.Run("Test squawk & meta", func(t *testing.T) {
+		adb.SetSquawk(0x7C79CA, 0x7700, rx1)
+		adb.SetMeta(0x7C79CA, AircraftMeta{AltGeom: 1150, Emergency: readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL}, rx1)
+		adb.SetMeta(0x7C79CA, AircraftMeta{AltGeom: 900}, rx1Old)
+		a := adb.GetAircraft()[0x7C79CA]
+		assert.Equal(t, 0x7700, a.Squawk)
+		assert.Equal(t, 1150, a.Meta.AltGeom)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL, a.Meta.Emergency)
+	})
+
+	t.Run("Test receivers", func(t *testing.T) {
+		assert.Equal(t, []string{"rx1", "rx2"}, adb.GetAircraft()[0x7C79CA].Receivers)
+
+		// rx1 stops seeing the aircraft
+		adb.Mutex.Lock()
+		adb.Aircraft[0x7C79CA].receivers["rx1"] = now.Add(-time.Second * (RECEIVER_TIMEOUT_SECONDS + 1))
+		adb.Mutex.Unlock()
+		assert.Equal(t, []string{"rx2"}, adb.GetAircraft()[0x7C79CA].Receivers)
+	})
+}
+
+func TestAircraftDBMergeMeta(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	sub := adb.Subscribe(16)
+	defer sub.Unsubscribe()
+	now := time.Now()
+	update := func(source string, at time.Time, a *readsb_protobuf.AircraftMeta) {
+		a.Addr = 0x7C79CA
+		a.Seen = uint64(at.UnixMilli())
+		updateFromReadsbProtobuf(adb, &readsb_protobuf.AircraftsUpdate{Now: uint64(at.Unix()), Aircraft: []*readsb_protobuf.AircraftMeta{a}}, source)
+	}
+
+	// rx1 hears ADS-B, including an emergency without a 7x00 squawk, rx2 only hears Mode S
+	adsb := func() *readsb_protobuf.AircraftMeta {
+		return &readsb_protobuf.AircraftMeta{
+			BaroRate:  -1500,
+			Emergency: readsb_protobuf.AircraftMeta_EMERGENCY_MINFUEL,
+			NavModes:  &readsb_protobuf.AircraftMeta_NavModes{Autopilot: true},
+			Rssi:      -20,
+			ValidSource: &readsb_protobuf.AircraftMeta_ValidSource{
+				BaroRate:  1,
+				Emergency: 1,
+				NavModes:  1,
+			},
+		}
+	}
+	modeS := func() *readsb_protobuf.AircraftMeta {
+		return &readsb_protobuf.AircraftMeta{
+			Squawk:      0x3000,
+			Rssi:        -30,
+			ValidSource: &readsb_protobuf.AircraftMeta_ValidSource{Squawk: 1},
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		at := now.Add(time.Second * time.Duration(i))
+		update("rx1", at, adsb())
+		update("rx2", at.Add(time.Millisecond*500), modeS())
+	}
+
+	t.Run("Test Mode S receiver doesn't replace ADS-B meta", func(t *testing.T) {
+		a := adb.GetAircraft()[0x7C79CA]
+		assert.Equal(t, -1500, a.Meta.BaroRate)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_MINFUEL, a.Meta.Emergency)
+		assert.True(t, a.Meta.NavModes.Autopilot)
+		assert.Equal(t, float32(-30), a.Meta.Rssi)
+		assert.Equal(t, "rx2", a.MetaSource.Source)
+	})
+
+	t.Run("Test emergency doesn't flap", func(t *testing.T) {
+		var emergencies []EventType
+		for len(sub.C) > 0 {
+			e := <-sub.C
+			if e.Type == EVENT_EMERGENCY || e.Type == EVENT_EMERGENCY_ENDED {
+				emergencies = append(emergencies, e.Type)
+			}
+		}
+		assert.Equal(t, []EventType{EVENT_EMERGENCY}, emergencies)
+	})
+
+	t.Run("Test older field is ignored", func(t *testing.T) {
+		adb.MergeMeta(0x7C79CA, AircraftMeta{BaroRate: 500}, META_BARO_RATE, FieldSource{Source: "rx2", Time: now})
+		assert.Equal(t, -1500, adb.GetAircraft()[0x7C79CA].Meta.BaroRate)
+	})
+}
+
+func TestAircraftDBOnChange(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	changes := 0
+	adb.SetOnChange(func() { changes++ })
+	fs := FieldSource{Source: "test", Time: time.Now()}
+
+	adb.SetCallsign(0x7C79CA, "HARR89", fs)
+	assert.Equal(t, 1, changes)
+
+	// no change, no call
+	adb.SetCallsign(0x7C79CA, "HARR89", fs)
+	assert.Equal(t, 1, changes)
+
+	adb.SetPosition(0x7C79CA, -32.1, 115.8, 1000, fs)
+	adb.SetSquawk(0x7C79CA, 0x3000, fs)
+	assert.Equal(t, 3, changes)
+
+	adb.Clear()
+	assert.Equal(t, 4, changes)
+}
@@ -0,0 +1,155 @@
+package datasources
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	AVR_TIMESTAMP_HEX_CHARS = 12 // 6 byte MLAT timestamp
+	AVR_SIGNAL_HEX_CHARS    = 2  // 1 byte RSSI
+)
+
+// AVRSource is a DataSource that reads AVR format (raw hex) messages, eg: readsb/dump1090 port 30002.
+// Messages can be read from TCP, a file or stdin. Supported formats:
+//   - plain:       *8D4840D6202CC371C32CE0576098;
+//   - timestamped: @0000016A5C7E8D4840D6202CC371C32CE0576098;
+//   - MLAT:        <0000016A5C7E808D4840D6202CC371C32CE0576098;
+type AVRSource struct {
+	*dataSourceRunner
+
+	addr    string // host:port to connect to, if reading from TCP
+	path    string // file to read, "-" is stdin, if reading from a file
+	decoder *modeSDecoder
+}
+
+// compile-time check that AVRSource is a DataSource
+var _ DataSource = &AVRSource{}
+
+var errAVRNotMessage = errors.New("not an AVR message")
+
+func NewAVRSource(addr string, adb *AircraftDB) *AVRSource {
+	// Returns a DataSource that updates the AircraftDB adb from AVR format messages read from addr (host:port)
+	name := fmt.Sprintf("avr %s", addr)
+	src := &AVRSource{
+		addr:    addr,
+		decoder: newModeSDecoder(name, adb),
+	}
+	src.dataSourceRunner = newDataSourceRunner(name, src.runTCP)
+	return src
+}
+
+func NewAVRFileSource(path string, adb *AircraftDB) *AVRSource {
+	// Returns a DataSource that updates the AircraftDB adb from AVR format messages read from the file at path
+	// If path is "-", messages are read from stdin
+	name := fmt.Sprintf("avr %s", path)
+	if path == "-" {
+		name = "avr stdin"
+	}
+	src := &AVRSource{
+		path:    path,
+		decoder: newModeSDecoder(name, adb),
+	}
+	src.dataSourceRunner = newDataSourceRunner(name, src.runFile)
+	return src
+}
+
+func parseAVRLine(line string) ([]byte, error) {
+	// returns the raw Mode S message from a line of AVR format data
+
+	line = strings.TrimSpace(line)
+	if len(line) < 2 || !strings.HasSuffix(line, ";") {
+		return nil, errAVRNotMessage
+	}
+
+	var msg string
+	switch line[0] {
+	case '*':
+		msg = line[1 : len(line)-1]
+	case '@':
+		if len(line) < 2+AVR_TIMESTAMP_HEX_CHARS {
+			return nil, errAVRNotMessage
+		}
+		msg = line[1+AVR_TIMESTAMP_HEX_CHARS : len(line)-1]
+	case '<':
+		if len(line) < 2+AVR_TIMESTAMP_HEX_CHARS+AVR_SIGNAL_HEX_CHARS {
+			return nil, errAVRNotMessage
+		}
+		msg = line[1+AVR_TIMESTAMP_HEX_CHARS+AVR_SIGNAL_HEX_CHARS : len(line)-1]
+	default:
+		return nil, errAVRNotMessage
+	}
+
+	data, err := hex.DecodeString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message %q: %w", msg, err)
+	}
+	return data, nil
+}
+
+func (src *AVRSource) readMessages(r io.Reader) (numMessages int, err error) {
+	// reads & applies messages from r until EOF
+	// returns the number of messages applied
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		data, err := parseAVRLine(scanner.Text())
+		if err != nil {
+			if err != errAVRNotMessage {
+				log.Printf("datasources.AVR: Skipping line: %s", err)
+			}
+			continue
+		}
+		if err := src.decoder.decode(data, time.Now()); err != nil {
+			continue
+		}
+		src.markUpdated()
+		numMessages++
+	}
+	return numMessages, scanner.Err()
+}
+
+func (src *AVRSource) runTCP(ctx context.Context) {
+	// connects to the AVR feed, reconnecting with exponential backoff, until ctx is cancelled
+	runTCPStream(ctx, src.dataSourceRunner, "datasources.AVR", src.addr, func(ctx context.Context, conn net.Conn) (int, error) {
+		return src.readMessages(conn)
+	})
+}
+
+func (src *AVRSource) runFile(ctx context.Context) {
+	// reads the file (or stdin) until EOF or ctx is cancelled
+
+	var f *os.File
+	if src.path == "-" {
+		f = os.Stdin
+	} else {
+		var err error
+		f, err = os.Open(src.path)
+		if err != nil {
+			log.Printf("datasources.AVR: %s", err)
+			src.markFailed(err)
+			return
+		}
+		defer f.Close()
+	}
+
+	stop := closeOnDone(ctx, f)
+	defer stop()
+
+	numMessages, err := src.readMessages(f)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("datasources.AVR: %s: %s", src.path, err)
+		src.markFailed(err)
+		return
+	}
+	log.Printf("datasources.AVR: Finished reading %s, %d messages", src.path, numMessages)
+}
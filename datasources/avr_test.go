@@ -0,0 +1,156 @@
+package datasources
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAVRLine(t *testing.T) {
+
+	expected, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+
+	t.Run("Test plain", func(t *testing.T) {
+		data, err := parseAVRLine("*8D4840D6202CC371C32CE0576098;")
+		require.NoError(t, err)
+		assert.Equal(t, expected, data)
+	})
+
+	t.Run("Test timestamped", func(t *testing.T) {
+		data, err := parseAVRLine("@0000016A5C7E8D4840D6202CC371C32CE0576098;\r\n")
+		require.NoError(t, err)
+		assert.Equal(t, expected, data)
+	})
+
+	t.Run("Test MLAT", func(t *testing.T) {
+		data, err := parseAVRLine("<0000016A5C7EA08D4840D6202CC371C32CE0576098;")
+		require.NoError(t, err)
+		assert.Equal(t, expected, data)
+	})
+
+	t.Run("Test errors", func(t *testing.T) {
+		_, err := parseAVRLine("")
+		assert.ErrorIs(t, err, errAVRNotMessage)
+		_, err = parseAVRLine("*8D4840D6202CC371C32CE0576098")
+		assert.ErrorIs(t, err, errAVRNotMessage)
+		_, err = parseAVRLine("#8D4840D6202CC371C32CE0576098;")
+		assert.ErrorIs(t, err, errAVRNotMessage)
+		_, err = parseAVRLine("@0000;")
+		assert.ErrorIs(t, err, errAVRNotMessage)
+		_, err = parseAVRLine("*NOTHEX;")
+		assert.Error(t, err)
+	})
+}
+
+func checkAVRCapture(t *testing.T, output map[int]Aircraft) {
+	// checks the aircraft decoded from testdata/avr/capture.txt
+
+	t.Run("Test identification", func(t *testing.T) {
+		require.Contains(t, output, 0x4840D6)
+		assert.Equal(t, "KLM1023 ", output[0x4840D6].Callsign)
+	})
+
+	t.Run("Test airborne position", func(t *testing.T) {
+		require.Contains(t, output, 0x40621D)
+		assert.InDelta(t, 52.2572, output[0x40621D].Lat, 0.001)
+		assert.InDelta(t, 3.9194, output[0x40621D].Long, 0.001)
+		assert.Equal(t, 38000, output[0x40621D].AltBaro)
+	})
+
+	t.Run("Test airborne velocity", func(t *testing.T) {
+		require.Contains(t, output, 0x485020)
+		assert.Equal(t, 159, output[0x485020].GroundSpeed)
+	})
+
+	t.Run("Test surface position", func(t *testing.T) {
+		require.Contains(t, output, 0x484175)
+		assert.InDelta(t, 52.3230, output[0x484175].Lat, 0.001)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_GROUND, output[0x484175].AirGround)
+	})
+}
+
+func TestAVRFileSource(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	adb := NewAircraftDB(60)
+	src := NewAVRFileSource(filepath.Join("testdata", "avr", "capture.txt"), adb)
+	assert.Implements(t, (*DataSource)(nil), src)
+	assert.Contains(t, src.Name(), "capture.txt")
+
+	require.NoError(t, src.Start(context.Background()))
+
+	// the source stops by itself at the end of the file
+	require.Eventually(t, func() bool {
+		return src.Health().Status == DATASOURCE_STOPPED
+	}, time.Second*5, time.Millisecond*50)
+	assert.Equal(t, uint64(6), src.Health().Updates)
+	assert.NoError(t, src.Health().LastError)
+
+	checkAVRCapture(t, adb.GetAircraft())
+
+	t.Run("Test missing file", func(t *testing.T) {
+		missing := NewAVRFileSource(filepath.Join("testdata", "avr", "missing.txt"), adb)
+		require.NoError(t, missing.Start(context.Background()))
+		require.Eventually(t, func() bool {
+			return missing.Health().LastError != nil
+		}, time.Second*5, time.Millisecond*50)
+	})
+
+	t.Run("Test stdin", func(t *testing.T) {
+		assert.Contains(t, NewAVRFileSource("-", adb).Name(), "stdin")
+	})
+}
+
+func TestAVRSource(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	replay, err := ioutil.ReadFile(filepath.Join("testdata", "avr", "capture.txt"))
+	require.NoError(t, err)
+
+	// listen on a local port & replay the captured data to each connection, then disconnect
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	var connections int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&connections, 1)
+			conn.Write(replay)
+			conn.Close()
+		}
+	}()
+
+	adb := NewAircraftDB(60)
+	src := NewAVRSource(listener.Addr().String(), adb)
+	assert.Contains(t, src.Name(), listener.Addr().String())
+
+	require.NoError(t, src.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&connections) >= 2
+	}, time.Second*10, time.Millisecond*50)
+	require.NoError(t, src.Stop())
+
+	checkAVRCapture(t, adb.GetAircraft())
+}
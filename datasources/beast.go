@@ -0,0 +1,63 @@
+package datasources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"pw_slippymap/datasources/beast"
+	"time"
+)
+
+// BeastSource is a DataSource that reads Beast binary format messages from TCP (eg: readsb/dump1090 port 30005),
+// decoding the Mode S messages itself
+type BeastSource struct {
+	*dataSourceRunner
+
+	addr    string // host:port to connect to
+	decoder *modeSDecoder
+}
+
+// compile-time check that BeastSource is a DataSource
+var _ DataSource = &BeastSource{}
+
+func NewBeastSource(addr string, adb *AircraftDB) *BeastSource {
+	// Returns a DataSource that updates the AircraftDB adb from Beast format messages read from addr (host:port)
+	name := fmt.Sprintf("beast %s", addr)
+	src := &BeastSource{
+		addr:    addr,
+		decoder: newModeSDecoder(name, adb),
+	}
+	src.dataSourceRunner = newDataSourceRunner(name, src.run)
+	return src
+}
+
+func (src *BeastSource) readMessages(ctx context.Context, conn net.Conn) (numMessages int, err error) {
+	// reads & applies messages from conn until the connection is closed
+	// returns the number of messages applied
+
+	r := beast.NewReader(conn)
+	for {
+		frame, err := r.ReadFrame()
+		if err == io.EOF {
+			return numMessages, nil
+		}
+		if err != nil {
+			return numMessages, err
+		}
+		if frame.Type != beast.FRAME_MODE_S_LONG {
+			// only extended squitters are decoded
+			continue
+		}
+		if err := src.decoder.decode(frame.Data, time.Now()); err != nil {
+			continue
+		}
+		src.markUpdated()
+		numMessages++
+	}
+}
+
+func (src *BeastSource) run(ctx context.Context) {
+	// connects to the beast feed, reconnecting with exponential backoff, until ctx is cancelled
+	runTCPStream(ctx, src.dataSourceRunner, "datasources.Beast", src.addr, src.readMessages)
+}
@@ -0,0 +1,144 @@
+package beast
+
+// Beast binary format framing
+// ref: https://github.com/firestuff/adsb-tools/blob/master/protocols/beast.md
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	BEAST_ESCAPE = 0x1A // start of frame, doubled when it appears in frame data
+
+	FRAME_MODE_AC     = '1' // Mode A/C reply, 2 bytes
+	FRAME_MODE_S      = '2' // Mode S short reply, 7 bytes
+	FRAME_MODE_S_LONG = '3' // Mode S long reply, 14 bytes
+	FRAME_STATUS      = '4' // receiver status/config, 14 bytes
+
+	TIMESTAMP_BYTES = 6 // 12MHz MLAT timestamp
+)
+
+var ErrUnknownFrameType = errors.New("unknown beast frame type")
+
+// Frame is a single beast frame
+type Frame struct {
+	Type      byte
+	Timestamp uint64 // 12MHz MLAT clock
+	Signal    byte   // RSSI, 0-255
+	Data      []byte // raw message
+}
+
+// Reader reads beast frames from a stream
+type Reader struct {
+	r *bufio.Reader
+
+	// set when a frame was truncated by the start of the next frame
+	inFrame bool
+}
+
+func NewReader(r io.Reader) *Reader {
+	// Returns a Reader that reads beast frames from r
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func frameDataLength(frameType byte) (int, error) {
+	// returns the length of the message data in a frame of type frameType
+	switch frameType {
+	case FRAME_MODE_AC:
+		return 2, nil
+	case FRAME_MODE_S:
+		return 7, nil
+	case FRAME_MODE_S_LONG, FRAME_STATUS:
+		return 14, nil
+	default:
+		return 0, fmt.Errorf("%w: 0x%02X", ErrUnknownFrameType, frameType)
+	}
+}
+
+func (br *Reader) sync() (byte, error) {
+	// discards input until the start of a frame, returns the frame type
+	for {
+		if br.inFrame {
+			// the escape byte has already been consumed
+			br.inFrame = false
+		} else {
+			b, err := br.r.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			if b != BEAST_ESCAPE {
+				continue
+			}
+		}
+		t, err := br.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		// an escaped 0x1A is frame data, not the start of a frame
+		if t == BEAST_ESCAPE {
+			continue
+		}
+		return t, nil
+	}
+}
+
+func (br *Reader) readUnescaped(buf []byte) error {
+	// fills buf with frame data, removing escaping
+	for i := range buf {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == BEAST_ESCAPE {
+			next, err := br.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if next != BEAST_ESCAPE {
+				// start of a new frame, this frame is truncated
+				br.r.UnreadByte()
+				br.inFrame = true
+				return io.ErrUnexpectedEOF
+			}
+		}
+		buf[i] = b
+	}
+	return nil
+}
+
+func (br *Reader) ReadFrame() (*Frame, error) {
+	// returns the next frame, skipping unknown or truncated frames
+	// returns an error only if the underlying reader does
+	for {
+		frameType, err := br.sync()
+		if err != nil {
+			return nil, err
+		}
+		dataLen, err := frameDataLength(frameType)
+		if err != nil {
+			continue
+		}
+
+		buf := make([]byte, TIMESTAMP_BYTES+1+dataLen)
+		err = br.readUnescaped(buf)
+		if err == io.ErrUnexpectedEOF {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		f := &Frame{
+			Type:   frameType,
+			Signal: buf[TIMESTAMP_BYTES],
+			Data:   buf[TIMESTAMP_BYTES+1:],
+		}
+		for _, b := range buf[:TIMESTAMP_BYTES] {
+			f.Timestamp = (f.Timestamp << 8) | uint64(b)
+		}
+		return f, nil
+	}
+}
@@ -0,0 +1,62 @@
+package beast
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFrame(t *testing.T) {
+
+	longMsg, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	shortMsg, _ := hex.DecodeString("5D4840D6C0A21B")
+
+	var stream bytes.Buffer
+	// garbage before the first frame
+	stream.Write([]byte{0x00, 0xFF})
+	// long frame, timestamp contains an escaped 0x1A
+	stream.Write([]byte{0x1A, '3', 0x00, 0x00, 0x1A, 0x1A, 0x01, 0x02, 0x03, 0xC8})
+	stream.Write(longMsg)
+	// truncated frame, followed by a short frame
+	stream.Write([]byte{0x1A, '3', 0x00, 0x00})
+	stream.Write([]byte{0x1A, '2', 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x50})
+	stream.Write(shortMsg)
+	// unknown frame type, followed by a Mode A/C frame
+	stream.Write([]byte{0x1A, 'x', 0x01})
+	stream.Write([]byte{0x1A, '1', 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x10, 0x12, 0x34})
+
+	r := NewReader(&stream)
+
+	t.Run("Test long frame", func(t *testing.T) {
+		f, err := r.ReadFrame()
+		require.NoError(t, err)
+		assert.Equal(t, byte(FRAME_MODE_S_LONG), f.Type)
+		assert.Equal(t, uint64(0x00001A010203), f.Timestamp)
+		assert.Equal(t, byte(0xC8), f.Signal)
+		assert.Equal(t, longMsg, f.Data)
+	})
+
+	t.Run("Test truncated frame is skipped", func(t *testing.T) {
+		f, err := r.ReadFrame()
+		require.NoError(t, err)
+		assert.Equal(t, byte(FRAME_MODE_S), f.Type)
+		assert.Equal(t, uint64(1), f.Timestamp)
+		assert.Equal(t, shortMsg, f.Data)
+	})
+
+	t.Run("Test unknown frame type is skipped", func(t *testing.T) {
+		f, err := r.ReadFrame()
+		require.NoError(t, err)
+		assert.Equal(t, byte(FRAME_MODE_AC), f.Type)
+		assert.Equal(t, []byte{0x12, 0x34}, f.Data)
+	})
+
+	t.Run("Test end of stream", func(t *testing.T) {
+		_, err := r.ReadFrame()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
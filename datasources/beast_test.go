@@ -0,0 +1,118 @@
+package datasources
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func beastFrame(t *testing.T, msg string) []byte {
+	// returns msg as a beast mode S long frame, escaping 0x1A
+	data, err := hex.DecodeString(msg)
+	require.NoError(t, err)
+	raw := append([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x80}, data...)
+	return append([]byte{0x1A, '3'}, bytes.ReplaceAll(raw, []byte{0x1A}, []byte{0x1A, 0x1A})...)
+}
+
+func TestBeastSource(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	var replay []byte
+	for _, msg := range []string{
+		"8D4840D6202CC371C32CE0576098", // identification, KLM1023
+		"8D40621D58C386435CC412692AD6", // airborne position, odd
+		"8D40621D58C382D690C8AC2863A7", // airborne position, even
+		"8D485020994409940838175B284F", // airborne velocity
+		"8DA05F219B06B6AF189400CBC33F", // airborne velocity, airspeed & heading
+		"8C4841753A8A35323FAEBDAC702D", // surface position, odd
+		"8C4841753AAB238733C8CD4020B1", // surface position, even
+		"8D4840D6202CC371C32CE0576099", // bad CRC
+	} {
+		replay = append(replay, beastFrame(t, msg)...)
+	}
+
+	// listen on a local port & replay the data to each connection, then disconnect
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	var connections int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&connections, 1)
+			conn.Write(replay)
+			conn.Close()
+		}
+	}()
+
+	adb := NewAircraftDB(60)
+	adb.SetMeta(0xA05F21, AircraftMeta{NavModes: NavModes{Autopilot: true}}, FieldSource{Source: "readsb", Time: time.Now()})
+	src := NewBeastSource(listener.Addr().String(), adb)
+	assert.Implements(t, (*DataSource)(nil), src)
+	assert.Contains(t, src.Name(), listener.Addr().String())
+
+	require.NoError(t, src.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&connections) >= 2
+	}, time.Second*10, time.Millisecond*50)
+	require.NoError(t, src.Stop())
+	assert.Positive(t, src.Health().Updates)
+
+	output := adb.GetAircraft()
+
+	t.Run("Test identification", func(t *testing.T) {
+		require.Contains(t, output, 0x4840D6)
+		assert.Equal(t, "KLM1023 ", output[0x4840D6].Callsign)
+		assert.Equal(t, 0xA0, output[0x4840D6].Category)
+	})
+
+	t.Run("Test airborne position", func(t *testing.T) {
+		require.Contains(t, output, 0x40621D)
+		a := output[0x40621D]
+		assert.InDelta(t, 52.2572, a.Lat, 0.001)
+		assert.InDelta(t, 3.9194, a.Long, 0.001)
+		assert.Equal(t, 38000, a.AltBaro)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_AIRBORNE, a.AirGround)
+	})
+
+	t.Run("Test airborne velocity", func(t *testing.T) {
+		require.Contains(t, output, 0x485020)
+		assert.Equal(t, 159, output[0x485020].GroundSpeed)
+		assert.Equal(t, 183, output[0x485020].Track)
+		assert.Equal(t, -832, output[0x485020].Meta.GeomRate)
+		assert.Equal(t, -832, output[0x485020].Meta.VerticalRate())
+
+		require.Contains(t, output, 0xA05F21)
+		a := output[0xA05F21]
+		assert.Equal(t, 375, a.Meta.Tas)
+		assert.Equal(t, 0, a.Meta.Ias)
+		assert.Equal(t, 244, a.Meta.MagHeading)
+		assert.Equal(t, -2304, a.Meta.BaroRate)
+		assert.True(t, a.Meta.NavModes.Autopilot, "meta from other sources is kept")
+	})
+
+	t.Run("Test surface position", func(t *testing.T) {
+		require.Contains(t, output, 0x484175)
+		a := output[0x484175]
+		assert.InDelta(t, 52.3230, a.Lat, 0.001)
+		assert.InDelta(t, 4.7305, a.Long, 0.001)
+		assert.Equal(t, 18, a.GroundSpeed)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_GROUND, a.AirGround)
+	})
+}
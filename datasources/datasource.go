@@ -0,0 +1,212 @@
+package datasources
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	DATASOURCE_STALE_AFTER_SECONDS = 10 // a connected data source with no updates for this long is reported as stale
+
+	RECONNECT_BACKOFF_MIN_SECONDS = 1  // initial wait before reconnecting
+	RECONNECT_BACKOFF_MAX_SECONDS = 60 // maximum wait before reconnecting
+)
+
+// DataSourceStatus describes the state of a DataSource
+type DataSourceStatus int
+
+const (
+	DATASOURCE_STOPPED    DataSourceStatus = iota // not running
+	DATASOURCE_CONNECTING                         // running, but has not received any data yet
+	DATASOURCE_CONNECTED                          // running and receiving data
+	DATASOURCE_STALE                              // running, but has not received data recently
+	DATASOURCE_FAILED                             // running, but the most recent attempt to get data failed
+)
+
+func (s DataSourceStatus) String() string {
+	switch s {
+	case DATASOURCE_STOPPED:
+		return "stopped"
+	case DATASOURCE_CONNECTING:
+		return "connecting"
+	case DATASOURCE_CONNECTED:
+		return "connected"
+	case DATASOURCE_STALE:
+		return "stale"
+	case DATASOURCE_FAILED:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// DataSourceHealth is a point-in-time report on the health of a DataSource
+type DataSourceHealth struct {
+	Status     DataSourceStatus
+	LastUpdate time.Time // when data was last successfully received
+	LastError  error     // the most recent error (nil if none)
+	Updates    uint64    // number of successful updates
+	Errors     uint64    // number of failed updates
+}
+
+// DataSource is a feed of aircraft data that updates an AircraftDB
+type DataSource interface {
+	Name() string                // name of the data source, for display
+	Start(context.Context) error // start the data source, returns once the data source is running
+	Stop() error                 // stop the data source, returns once the data source has stopped
+	Health() DataSourceHealth    // report on the health of the data source
+}
+
+var (
+	ErrDataSourceRunning    = errors.New("data source already running")
+	ErrDataSourceNotRunning = errors.New("data source not running")
+
+	errConnectionClosed = errors.New("connection closed")
+)
+
+// dataSourceRunner implements the lifecycle & health tracking common to all data sources.
+// Data sources embed a *dataSourceRunner and provide a run function that loops until its context is cancelled.
+type dataSourceRunner struct {
+	name string
+	run  func(ctx context.Context)
+
+	cancel context.CancelFunc // cancels the running data source
+	done   chan struct{}      // closed when run returns
+
+	health DataSourceHealth
+	mutex  sync.Mutex
+}
+
+func newDataSourceRunner(name string, run func(ctx context.Context)) *dataSourceRunner {
+	return &dataSourceRunner{
+		name: name,
+		run:  run,
+	}
+}
+
+func (r *dataSourceRunner) Name() string {
+	return r.name
+}
+
+func (r *dataSourceRunner) Start(ctx context.Context) error {
+	// starts the data source in a new goroutine
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cancel != nil {
+		return ErrDataSourceRunning
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	r.cancel = cancel
+	r.done = done
+	r.health = DataSourceHealth{Status: DATASOURCE_CONNECTING}
+
+	go func() {
+		defer close(done)
+		r.run(runCtx)
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.health.Status = DATASOURCE_STOPPED
+	}()
+
+	return nil
+}
+
+func (r *dataSourceRunner) Stop() error {
+	// stops the data source and waits for it to finish
+
+	r.mutex.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel = nil
+	r.mutex.Unlock()
+
+	if cancel == nil {
+		return ErrDataSourceNotRunning
+	}
+
+	cancel()
+	<-done
+	return nil
+}
+
+func (r *dataSourceRunner) Health() DataSourceHealth {
+	// returns a copy of the data source health, marking the data source stale if required
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	h := r.health
+	if h.Status == DATASOURCE_CONNECTED && time.Since(h.LastUpdate) > time.Second*DATASOURCE_STALE_AFTER_SECONDS {
+		h.Status = DATASOURCE_STALE
+	}
+	return h
+}
+
+func (r *dataSourceRunner) markUpdated() {
+	// records a successful update
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.health.Status = DATASOURCE_CONNECTED
+	r.health.LastUpdate = time.Now()
+	r.health.Updates += 1
+}
+
+func (r *dataSourceRunner) markFailed(err error) {
+	// records a failed update
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.health.Status = DATASOURCE_FAILED
+	r.health.LastError = err
+	r.health.Errors += 1
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	// sleeps for d, returns false if ctx was cancelled before d elapsed
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func runWithReconnect(ctx context.Context, runner *dataSourceRunner, logPrefix, addr string, session func(ctx context.Context) (numMessages int, err error)) {
+	// runs session until ctx is cancelled, re-running it with exponential backoff whenever it returns
+	// session should connect to addr & process messages until the connection is lost, returning the number of messages processed
+
+	backoff := time.Second * RECONNECT_BACKOFF_MIN_SECONDS
+
+	for {
+		numMessages, err := session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errConnectionClosed
+		}
+
+		// if we received data, start backing off from the minimum again
+		if numMessages > 0 {
+			backoff = time.Second * RECONNECT_BACKOFF_MIN_SECONDS
+		}
+
+		log.Printf("%s: %s: %s, reconnecting in %s", logPrefix, addr, err, backoff)
+		runner.markFailed(err)
+		if !sleepContext(ctx, backoff) {
+			return
+		}
+		backoff *= 2
+		if backoff > time.Second*RECONNECT_BACKOFF_MAX_SECONDS {
+			backoff = time.Second * RECONNECT_BACKOFF_MAX_SECONDS
+		}
+	}
+}
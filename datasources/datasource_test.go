@@ -0,0 +1,63 @@
+package datasources
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSourceRunner(t *testing.T) {
+
+	updates := make(chan error)
+	var r *dataSourceRunner
+	r = newDataSourceRunner("test", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-updates:
+				if err != nil {
+					r.markFailed(err)
+				} else {
+					r.markUpdated()
+				}
+			}
+		}
+	})
+
+	assert.Equal(t, "test", r.Name())
+	assert.Equal(t, "stopped", r.Health().Status.String())
+
+	require.NoError(t, r.Start(context.Background()))
+	assert.Equal(t, DATASOURCE_CONNECTING, r.Health().Status)
+
+	updates <- nil
+	require.Eventually(t, func() bool { return r.Health().Status == DATASOURCE_CONNECTED }, time.Second, time.Millisecond*10)
+	assert.Equal(t, uint64(1), r.Health().Updates)
+
+	updates <- errors.New("oh no an error")
+	require.Eventually(t, func() bool { return r.Health().Status == DATASOURCE_FAILED }, time.Second, time.Millisecond*10)
+	assert.Equal(t, uint64(1), r.Health().Errors)
+
+	// a connected source that hasn't been updated recently is stale
+	updates <- nil
+	require.Eventually(t, func() bool { return r.Health().Status == DATASOURCE_CONNECTED }, time.Second, time.Millisecond*10)
+	r.mutex.Lock()
+	r.health.LastUpdate = time.Now().Add(-time.Second * (DATASOURCE_STALE_AFTER_SECONDS + 1))
+	r.mutex.Unlock()
+	assert.Equal(t, DATASOURCE_STALE, r.Health().Status)
+
+	require.NoError(t, r.Stop())
+	assert.Equal(t, DATASOURCE_STOPPED, r.Health().Status)
+
+	// cancelling the parent context stops the data source too
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, r.Start(ctx))
+	cancel()
+	require.Eventually(t, func() bool { return r.Health().Status == DATASOURCE_STOPPED }, time.Second, time.Millisecond*10)
+	require.NoError(t, r.Stop())
+}
@@ -0,0 +1,66 @@
+package datasources
+
+import (
+	"pw_slippymap/datasources/readsb_protobuf"
+)
+
+const (
+	SQUAWK_UNLAWFUL_INTERFERENCE = 0x7500 // hijack
+	SQUAWK_RADIO_FAILURE         = 0x7600 // lost communications
+	SQUAWK_EMERGENCY             = 0x7700 // general emergency
+)
+
+func EmergencyFromSquawk(squawk int) readsb_protobuf.AircraftMeta_Emergency {
+	// returns the emergency indicated by an emergency squawk, or EMERGENCY_NONE
+	switch squawk {
+	case SQUAWK_UNLAWFUL_INTERFERENCE:
+		return readsb_protobuf.AircraftMeta_EMERGENCY_UNLAWFUL
+	case SQUAWK_RADIO_FAILURE:
+		return readsb_protobuf.AircraftMeta_EMERGENCY_NORDO
+	case SQUAWK_EMERGENCY:
+		return readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL
+	default:
+		return readsb_protobuf.AircraftMeta_EMERGENCY_NONE
+	}
+}
+
+func EmergencyDescription(e readsb_protobuf.AircraftMeta_Emergency) string {
+	// returns a short human readable description of e, eg: for display
+	switch e {
+	case readsb_protobuf.AircraftMeta_EMERGENCY_NONE:
+		return "none"
+	case readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL:
+		return "general emergency"
+	case readsb_protobuf.AircraftMeta_EMERGENCY_LIFEGUARD:
+		return "lifeguard/medical"
+	case readsb_protobuf.AircraftMeta_EMERGENCY_MINFUEL:
+		return "minimum fuel"
+	case readsb_protobuf.AircraftMeta_EMERGENCY_NORDO:
+		return "no communications"
+	case readsb_protobuf.AircraftMeta_EMERGENCY_UNLAWFUL:
+		return "unlawful interference"
+	case readsb_protobuf.AircraftMeta_EMERGENCY_DOWNED:
+		return "downed aircraft"
+	default:
+		return "emergency"
+	}
+}
+
+func (adb *AircraftDB) updateEmergency(icao int, a *Aircraft, fs FieldSource) {
+	// works out a.Emergency from the emergency status the aircraft reports, or its squawk
+	// adb.Mutex must be held
+	emergency := a.Meta.Emergency
+	if emergency == readsb_protobuf.AircraftMeta_EMERGENCY_NONE {
+		emergency = EmergencyFromSquawk(a.Squawk)
+	}
+	if emergency == a.Emergency {
+		return
+	}
+	a.Emergency = emergency
+	adb.changed()
+	if emergency == readsb_protobuf.AircraftMeta_EMERGENCY_NONE {
+		adb.emit(EVENT_EMERGENCY_ENDED, icao, a, fs.Time)
+	} else {
+		adb.emit(EVENT_EMERGENCY, icao, a, fs.Time)
+	}
+}
@@ -0,0 +1,81 @@
+package datasources
+
+import (
+	"pw_slippymap/datasources/readsb_protobuf"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmergencyFromSquawk(t *testing.T) {
+	assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_UNLAWFUL, EmergencyFromSquawk(0x7500))
+	assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_NORDO, EmergencyFromSquawk(0x7600))
+	assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL, EmergencyFromSquawk(0x7700))
+	assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_NONE, EmergencyFromSquawk(0x3000))
+
+	// 7700 octal is not 7700 hex
+	assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_NONE, EmergencyFromSquawk(07700))
+}
+
+func TestEmergencyDescription(t *testing.T) {
+	assert.Equal(t, "general emergency", EmergencyDescription(readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL))
+	assert.Equal(t, "minimum fuel", EmergencyDescription(readsb_protobuf.AircraftMeta_EMERGENCY_MINFUEL))
+	assert.Equal(t, "emergency", EmergencyDescription(readsb_protobuf.AircraftMeta_EMERGENCY_RESERVED))
+}
+
+func TestAircraftDBEmergency(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	sub := adb.Subscribe(0)
+	defer sub.Unsubscribe()
+	fs := FieldSource{Source: "test", Time: time.Now()}
+	next := func() FieldSource {
+		fs.Time = fs.Time.Add(time.Second)
+		return fs
+	}
+
+	// skip the events we're not interested in
+	nextEmergencyEvent := func(t *testing.T) Event {
+		t.Helper()
+		for {
+			e := nextEvent(t, sub)
+			if e.Type == EVENT_EMERGENCY || e.Type == EVENT_EMERGENCY_ENDED {
+				return e
+			}
+		}
+	}
+
+	t.Run("Test squawk", func(t *testing.T) {
+		adb.SetLastSeen(0x7C6DD8, next())
+		adb.SetSquawk(0x7C6DD8, 0x7700, next())
+		e := nextEmergencyEvent(t)
+		assert.Equal(t, EVENT_EMERGENCY, e.Type)
+		assert.Equal(t, 0x7C6DD8, e.ICAO)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL, e.Aircraft.Emergency)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL, adb.GetAircraft()[0x7C6DD8].Emergency)
+
+		adb.SetSquawk(0x7C6DD8, 0x7600, next())
+		e = nextEmergencyEvent(t)
+		assert.Equal(t, EVENT_EMERGENCY, e.Type)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_NORDO, e.Aircraft.Emergency)
+
+		adb.SetSquawk(0x7C6DD8, 0x3000, next())
+		e = nextEmergencyEvent(t)
+		assert.Equal(t, EVENT_EMERGENCY_ENDED, e.Type)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_NONE, adb.GetAircraft()[0x7C6DD8].Emergency)
+	})
+
+	t.Run("Test emergency status", func(t *testing.T) {
+		// reported emergency status takes priority over the squawk
+		adb.SetLastSeen(0x7C79CA, next())
+		adb.SetSquawk(0x7C79CA, 0x7700, next())
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL, nextEmergencyEvent(t).Aircraft.Emergency)
+		adb.SetMeta(0x7C79CA, AircraftMeta{Emergency: readsb_protobuf.AircraftMeta_EMERGENCY_MINFUEL}, next())
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_MINFUEL, nextEmergencyEvent(t).Aircraft.Emergency)
+
+		// falls back to the squawk
+		adb.SetMeta(0x7C79CA, AircraftMeta{}, next())
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL, nextEmergencyEvent(t).Aircraft.Emergency)
+	})
+}
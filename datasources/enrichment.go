@@ -0,0 +1,51 @@
+package datasources
+
+// aircraft details that aren't transmitted, looked up from the readsb database (see readsbdb.go & readsb_json/)
+
+import (
+	"strings"
+)
+
+const (
+	OPERATOR_PREFIX_LENGTH = 3 // ICAO airline designators are 3 letters, eg: "QFA" for Qantas
+)
+
+func operatorPrefix(callsign string) (prefix string, ok bool) {
+	// returns the ICAO airline designator of an airline callsign, eg: "QFA" for "QFA123"
+	// registration callsigns (eg: "VHVYK") don't have a flight number, so have no designator
+	callsign = strings.TrimSpace(callsign)
+	if len(callsign) <= OPERATOR_PREFIX_LENGTH {
+		return "", false
+	}
+	for i, c := range callsign[:OPERATOR_PREFIX_LENGTH+1] {
+		if i < OPERATOR_PREFIX_LENGTH && (c < 'A' || c > 'Z') {
+			return "", false
+		}
+		if i == OPERATOR_PREFIX_LENGTH && (c < '0' || c > '9') {
+			return "", false
+		}
+	}
+	return callsign[:OPERATOR_PREFIX_LENGTH], true
+}
+
+func (a *Aircraft) enrichFromICAO(icao int, db *ReadsbDB) {
+	// sets the details looked up from the aircraft's ICAO address in db (which may be nil, if not loaded yet)
+	entry, _ := db.lookup(icao)
+	a.Registration = entry.registration
+	a.AircraftType = entry.aircraftType
+	t := readsbTypes[entry.aircraftType]
+	a.Model = t.description
+	a.TypeClass = t.typeClass
+	a.WakeCategory = t.wakeCategory
+}
+
+func (a *Aircraft) enrichFromCallsign() {
+	// sets the details looked up from the aircraft's callsign
+	var o readsbOperatorEntry
+	if prefix, ok := operatorPrefix(a.Callsign); ok {
+		o = readsbOperators[prefix]
+	}
+	a.Operator = o.name
+	a.OperatorCountry = o.country
+	a.OperatorTelephony = o.telephony
+}
@@ -0,0 +1,86 @@
+package datasources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperatorPrefix(t *testing.T) {
+	for _, test := range []struct {
+		callsign string
+		prefix   string
+		ok       bool
+	}{
+		{"QFA123  ", "QFA", true},
+		{"JST7A", "JST", true},
+		{"VHVYK", "", false},   // registration
+		{"RSCU501", "", false}, // 4 letters
+		{"QF1", "", false},
+		{"QFA", "", false},
+		{"", "", false},
+	} {
+		prefix, ok := operatorPrefix(test.callsign)
+		assert.Equal(t, test.prefix, prefix, test.callsign)
+		assert.Equal(t, test.ok, ok, test.callsign)
+	}
+}
+
+func TestAircraftDBEnrichment(t *testing.T) {
+
+	// test entries, so the test doesn't depend on the readsb database contents
+	readsbTypes["TST1"] = readsbTypeEntry{description: "TEST Aircraft", typeClass: "L2J", wakeCategory: "M"}
+	readsbOperators["TST"] = readsbOperatorEntry{name: "Test Airways", country: "Australia", telephony: "TESTER"}
+	defer func() {
+		delete(readsbTypes, "TST1")
+		delete(readsbOperators, "TST")
+	}()
+
+	adb := NewAircraftDB(60)
+	adb.SetReadsbDB(testReadsbDB(t, "version\t1\nFFFFF0\tVH-TST\tTST1\n"))
+	fs := FieldSource{Source: "test", Time: time.Now()}
+
+	t.Run("Test registration & type", func(t *testing.T) {
+		adb.SetCallsign(0xFFFFF0, "VHTST", fs)
+		a := adb.GetAircraft()[0xFFFFF0]
+		assert.Equal(t, "VH-TST", a.Registration)
+		assert.Equal(t, "TST1", a.AircraftType)
+		assert.Equal(t, "TEST Aircraft", a.Model)
+		assert.Equal(t, "L2J", a.TypeClass)
+		assert.Equal(t, "M", a.WakeCategory)
+		assert.Empty(t, a.Operator)
+	})
+
+	t.Run("Test operator", func(t *testing.T) {
+		fs.Time = fs.Time.Add(time.Second)
+		adb.SetCallsign(0xFFFFF0, "TST123  ", fs)
+		a := adb.GetAircraft()[0xFFFFF0]
+		assert.Equal(t, "Test Airways", a.Operator)
+		assert.Equal(t, "Australia", a.OperatorCountry)
+		assert.Equal(t, "TESTER", a.OperatorTelephony)
+
+		// no operator for registration callsigns
+		fs.Time = fs.Time.Add(time.Second)
+		adb.SetCallsign(0xFFFFF0, "VHTST", fs)
+		assert.Empty(t, adb.GetAircraft()[0xFFFFF0].Operator)
+	})
+
+	t.Run("Test database loaded later", func(t *testing.T) {
+		adb := NewAircraftDB(60)
+		adb.SetLastSeen(0xFFFFF0, fs)
+		assert.Empty(t, adb.GetAircraft()[0xFFFFF0].Registration)
+		adb.SetReadsbDB(testReadsbDB(t, "version\t1\nFFFFF0\tVH-TST\tTST1\n"))
+		a := adb.GetAircraft()[0xFFFFF0]
+		assert.Equal(t, "VH-TST", a.Registration)
+		assert.Equal(t, "TEST Aircraft", a.Model)
+	})
+
+	t.Run("Test unknown aircraft", func(t *testing.T) {
+		adb.SetCallsign(0xFFFFF1, "TST456", fs)
+		a := adb.GetAircraft()[0xFFFFF1]
+		assert.Empty(t, a.Registration)
+		assert.Empty(t, a.Model)
+		assert.Equal(t, "Test Airways", a.Operator)
+	})
+}
@@ -0,0 +1,142 @@
+package datasources
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EVENT_BUFFER_SIZE = 256 // default size of a Subscription's channel
+)
+
+// EventType is the kind of change an Event describes
+type EventType int
+
+const (
+	EVENT_APPEARED         EventType = iota // the aircraft was seen for the first time
+	EVENT_POSITION_UPDATED                  // the aircraft's position or altitude changed
+	EVENT_CALLSIGN_CHANGED                  // the aircraft's callsign changed, including when it is first known
+	EVENT_SQUAWK_CHANGED                    // the aircraft's squawk changed, including when it is first known
+	EVENT_ON_GROUND                         // the aircraft was airborne, and is now on the ground
+	EVENT_AIRBORNE                          // the aircraft was on the ground, and is now airborne
+	EVENT_TIMED_OUT                         // the aircraft was forgotten, because it timed out, its data source removed it, or the AircraftDB was cleared
+	EVENT_EMERGENCY                         // the aircraft declared an emergency, or changed the type of emergency (see Aircraft.Emergency)
+	EVENT_EMERGENCY_ENDED                   // the aircraft is no longer declaring an emergency
+	EVENT_DEPARTED                          // the aircraft lifted off (see Aircraft.FlightPhase)
+	EVENT_LANDED                            // the aircraft touched down (see Aircraft.FlightPhase)
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EVENT_APPEARED:
+		return "appeared"
+	case EVENT_POSITION_UPDATED:
+		return "position updated"
+	case EVENT_CALLSIGN_CHANGED:
+		return "callsign changed"
+	case EVENT_SQUAWK_CHANGED:
+		return "squawk changed"
+	case EVENT_ON_GROUND:
+		return "on ground"
+	case EVENT_AIRBORNE:
+		return "airborne"
+	case EVENT_TIMED_OUT:
+		return "timed out"
+	case EVENT_EMERGENCY:
+		return "emergency"
+	case EVENT_EMERGENCY_ENDED:
+		return "emergency ended"
+	case EVENT_DEPARTED:
+		return "departed"
+	case EVENT_LANDED:
+		return "landed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to an aircraft in the AircraftDB
+type Event struct {
+	Type     EventType
+	ICAO     int
+	Time     time.Time // when the data that caused the event was received, or when the aircraft was forgotten
+	Aircraft Aircraft  // the aircraft after the change (without History)
+}
+
+// Subscription receives Events from an AircraftDB
+// Events are dropped rather than blocking the AircraftDB if the subscriber doesn't keep up
+type Subscription struct {
+	C <-chan Event // events, closed by Unsubscribe
+
+	c       chan Event
+	adb     *AircraftDB
+	dropped uint64 // atomic
+}
+
+func (adb *AircraftDB) Subscribe(bufferSize int) *Subscription {
+	// Returns a Subscription to events from the AircraftDB, with a channel holding up to bufferSize events
+	if bufferSize <= 0 {
+		bufferSize = EVENT_BUFFER_SIZE
+	}
+	c := make(chan Event, bufferSize)
+	sub := &Subscription{
+		C:   c,
+		c:   c,
+		adb: adb,
+	}
+	adb.Mutex.Lock()
+	defer adb.Mutex.Unlock()
+	adb.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (sub *Subscription) Unsubscribe() {
+	// stops delivery of events & closes sub.C
+	sub.adb.Mutex.Lock()
+	defer sub.adb.Mutex.Unlock()
+	if _, ok := sub.adb.subscribers[sub]; ok {
+		delete(sub.adb.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+func (sub *Subscription) Dropped() uint64 {
+	// returns the number of events dropped because sub.C was full
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+func (adb *AircraftDB) emit(eventType EventType, icao int, a *Aircraft, t time.Time) {
+	// sends an event to all subscribers, without blocking
+	// events for an aircraft that hasn't appeared yet are held until it does, so EVENT_APPEARED is always its first
+	// adb.Mutex must be held
+	if len(adb.subscribers) == 0 {
+		return
+	}
+	e := Event{
+		Type:     eventType,
+		ICAO:     icao,
+		Time:     t,
+		Aircraft: a.snapshot(),
+	}
+	if !a.appeared && eventType != EVENT_APPEARED {
+		a.pendingEvents = append(a.pendingEvents, e)
+		return
+	}
+	adb.send(e)
+	for _, pending := range a.pendingEvents {
+		adb.send(pending)
+	}
+	a.pendingEvents = nil
+}
+
+func (adb *AircraftDB) send(e Event) {
+	// sends e to all subscribers, without blocking
+	// adb.Mutex must be held
+	for sub := range adb.subscribers {
+		select {
+		case sub.c <- e:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package datasources
+
+import (
+	"pw_slippymap/datasources/readsb_protobuf"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nextEvent(t *testing.T, sub *Subscription) Event {
+	// returns the next event, failing the test if there isn't one
+	t.Helper()
+	select {
+	case e, ok := <-sub.C:
+		require.True(t, ok, "subscription closed")
+		return e
+	case <-time.After(time.Second * 5):
+		require.FailNow(t, "timed out waiting for event")
+		return Event{}
+	}
+}
+
+func assertNoEvent(t *testing.T, sub *Subscription) {
+	// fails the test if an event is waiting
+	t.Helper()
+	select {
+	case e := <-sub.C:
+		assert.Fail(t, "unexpected event", "%s", e.Type)
+	default:
+	}
+}
+
+func TestAircraftDBEvents(t *testing.T) {
+
+	adb := NewAircraftDB(1)
+	sub := adb.Subscribe(0)
+	fs := FieldSource{Source: "test", Time: time.Now()}
+	next := func() FieldSource {
+		fs.Time = fs.Time.Add(time.Second)
+		return fs
+	}
+
+	t.Run("Test appeared", func(t *testing.T) {
+		// a source's first update for an aircraft
+		adb.SetCallsign(0x7C79CA, "HARR89", next())
+		adb.SetPosition(0x7C79CA, -32.1, 115.8, 1000, next())
+		assertNoEvent(t, sub)
+		adb.SetLastSeen(0x7C79CA, next())
+
+		// appeared comes first, with everything in the update
+		e := nextEvent(t, sub)
+		assert.Equal(t, EVENT_APPEARED, e.Type)
+		assert.Equal(t, 0x7C79CA, e.ICAO)
+		assert.Equal(t, fs.Time, e.Time)
+		assert.Equal(t, "HARR89", e.Aircraft.Callsign)
+		assert.Equal(t, -32.1, e.Aircraft.Lat)
+
+		// then the changes in the update
+		e = nextEvent(t, sub)
+		assert.Equal(t, EVENT_CALLSIGN_CHANGED, e.Type)
+		assert.Equal(t, "HARR89", e.Aircraft.Callsign)
+		assert.Equal(t, EVENT_POSITION_UPDATED, nextEvent(t, sub).Type)
+
+		// only once
+		adb.SetLastSeen(0x7C79CA, next())
+		assertNoEvent(t, sub)
+	})
+
+	t.Run("Test unchanged values", func(t *testing.T) {
+		adb.SetCallsign(0x7C79CA, "HARR89", next())
+		adb.SetPosition(0x7C79CA, -32.1, 115.8, 1000, next())
+		adb.SetTrack(0x7C79CA, 90, next())
+		assertNoEvent(t, sub)
+	})
+
+	t.Run("Test squawk changed", func(t *testing.T) {
+		adb.SetSquawk(0x7C79CA, 0x3000, next())
+		assert.Equal(t, EVENT_SQUAWK_CHANGED, nextEvent(t, sub).Type)
+		adb.SetSquawk(0x7C79CA, 0x7700, next())
+		e := nextEvent(t, sub)
+		assert.Equal(t, EVENT_SQUAWK_CHANGED, e.Type)
+		assert.Equal(t, 0x7700, e.Aircraft.Squawk)
+		assert.Equal(t, EVENT_EMERGENCY, nextEvent(t, sub).Type)
+	})
+
+	t.Run("Test on ground & airborne", func(t *testing.T) {
+		// the first definite state isn't a transition
+		adb.SetAirGround(0x7C79CA, readsb_protobuf.AircraftMeta_AG_AIRBORNE, next())
+		assertNoEvent(t, sub)
+
+		adb.SetAirGround(0x7C79CA, readsb_protobuf.AircraftMeta_AG_UNCERTAIN, next())
+		adb.SetAirGround(0x7C79CA, readsb_protobuf.AircraftMeta_AG_AIRBORNE, next())
+		assertNoEvent(t, sub)
+
+		adb.SetAirGround(0x7C79CA, readsb_protobuf.AircraftMeta_AG_GROUND, next())
+		assert.Equal(t, EVENT_ON_GROUND, nextEvent(t, sub).Type)
+		assert.Equal(t, EVENT_LANDED, nextEvent(t, sub).Type)
+
+		adb.SetAirGround(0x7C79CA, readsb_protobuf.AircraftMeta_AG_UNCERTAIN, next())
+		adb.SetAirGround(0x7C79CA, readsb_protobuf.AircraftMeta_AG_AIRBORNE, next())
+		assert.Equal(t, EVENT_AIRBORNE, nextEvent(t, sub).Type)
+		assert.Equal(t, EVENT_DEPARTED, nextEvent(t, sub).Type)
+	})
+
+	t.Run("Test timed out", func(t *testing.T) {
+		e := nextEvent(t, sub)
+		assert.Equal(t, EVENT_TIMED_OUT, e.Type)
+		assert.Equal(t, 0x7C79CA, e.ICAO)
+	})
+
+	t.Run("Test cleared", func(t *testing.T) {
+		adb.SetLastSeen(0x7C6DD8, next())
+		assert.Equal(t, EVENT_APPEARED, nextEvent(t, sub).Type)
+		adb.Clear()
+		e := nextEvent(t, sub)
+		assert.Equal(t, EVENT_TIMED_OUT, e.Type)
+		assert.Equal(t, 0x7C6DD8, e.ICAO)
+	})
+
+	t.Run("Test unsubscribe", func(t *testing.T) {
+		sub.Unsubscribe()
+		sub.Unsubscribe()
+		adb.SetLastSeen(0x7C6DD8, next())
+		_, ok := <-sub.C
+		assert.False(t, ok)
+	})
+}
+
+func TestAircraftDBEventsDropped(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	sub := adb.Subscribe(1)
+	defer sub.Unsubscribe()
+
+	// a slow subscriber doesn't block the AircraftDB
+	for i := 0; i < 3; i++ {
+		adb.SetLastSeen(0x7C79CA+i, FieldSource{Source: "test", Time: time.Now()})
+	}
+	assert.Equal(t, uint64(2), sub.Dropped())
+	assert.Equal(t, 0x7C79CA, nextEvent(t, sub).ICAO)
+}
+
+func TestEventTypeString(t *testing.T) {
+	assert.Equal(t, "appeared", EVENT_APPEARED.String())
+	assert.Equal(t, "timed out", EVENT_TIMED_OUT.String())
+	assert.Equal(t, "unknown", EventType(-1).String())
+}
@@ -0,0 +1,205 @@
+package datasources
+
+// Flight phase is worked out from air/ground state, ground speed, altitude & vertical rate each time one of
+// them changes. Thresholds to enter a phase are stricter than thresholds to stay in it, and airborne phases
+// are held for a minimum time, so noisy reports don't make the phase flap.
+
+import (
+	"time"
+
+	"pw_slippymap/datasources/readsb_protobuf"
+)
+
+const (
+	FLIGHT_PHASE_ROLL_GS_KTS       = 40   // on the ground, faster than this is a takeoff roll
+	FLIGHT_PHASE_TAXI_GS_KTS       = 30   // on the ground, a takeoff or landing roll ends below this
+	FLIGHT_PHASE_TAKEOFF_SECONDS   = 60   // after lifting off, an aircraft is taking off for this long, unless it descends
+	FLIGHT_PHASE_CLIMB_RATE_FPM    = 500  // vertical rate to start climbing or descending, feet/minute
+	FLIGHT_PHASE_LEVEL_RATE_FPM    = 250  // vertical rate below which a climb or descent ends, feet/minute
+	FLIGHT_PHASE_APPROACH_ALT_FT   = 3000 // a descent below this altitude is an approach
+	FLIGHT_PHASE_APPROACH_EXIT_FT  = 4000 // an approach ends above this altitude
+	FLIGHT_PHASE_MIN_SECONDS       = 10   // airborne phases are held for at least this long
+	VERTICAL_RATE_WINDOW_SECONDS   = 30   // track history used to work out vertical rate, when it isn't reported
+	VERTICAL_RATE_MIN_SPAN_SECONDS = 5    // track history needed to work out vertical rate
+)
+
+// FlightPhase is what an aircraft is doing, see Aircraft.FlightPhase
+type FlightPhase int
+
+const (
+	PHASE_UNKNOWN  FlightPhase = iota // no definite air/ground state yet
+	PHASE_TAXI                        // on the ground, below takeoff roll speed
+	PHASE_TAKEOFF                     // takeoff roll, or the first minute after lifting off
+	PHASE_CLIMB                       // airborne, climbing
+	PHASE_CRUISE                      // airborne, level
+	PHASE_DESCENT                     // airborne, descending
+	PHASE_APPROACH                    // airborne, descending at low altitude or flying an approach
+	PHASE_LANDING                     // landing roll, after touching down
+)
+
+func (p FlightPhase) String() string {
+	switch p {
+	case PHASE_TAXI:
+		return "taxi"
+	case PHASE_TAKEOFF:
+		return "takeoff"
+	case PHASE_CLIMB:
+		return "climb"
+	case PHASE_CRUISE:
+		return "cruise"
+	case PHASE_DESCENT:
+		return "descent"
+	case PHASE_APPROACH:
+		return "approach"
+	case PHASE_LANDING:
+		return "landing"
+	default:
+		return "unknown"
+	}
+}
+
+func (p FlightPhase) Glyph() string {
+	// Returns a short symbol for the phase, to draw next to a marker, or "" if unknown
+	switch p {
+	case PHASE_TAXI:
+		return "T"
+	case PHASE_TAKEOFF:
+		return "TO"
+	case PHASE_CLIMB:
+		return "^"
+	case PHASE_CRUISE:
+		return "="
+	case PHASE_DESCENT:
+		return "v"
+	case PHASE_APPROACH:
+		return "A"
+	case PHASE_LANDING:
+		return "L"
+	default:
+		return ""
+	}
+}
+
+func historyVerticalRate(history []AircraftHistoryLocation) (fpm int, ok bool) {
+	// returns the vertical rate over the last VERTICAL_RATE_WINDOW_SECONDS of history (which is in time order)
+	if len(history) < 2 {
+		return 0, false
+	}
+	last := history[len(history)-1]
+	first := last
+	for i := len(history) - 2; i >= 0; i-- {
+		if last.Time.Sub(history[i].Time) > time.Second*VERTICAL_RATE_WINDOW_SECONDS {
+			break
+		}
+		first = history[i]
+	}
+	span := last.Time.Sub(first.Time)
+	if span < time.Second*VERTICAL_RATE_MIN_SPAN_SECONDS {
+		return 0, false
+	}
+	return int(float64(last.Alt-first.Alt) / span.Minutes()), true
+}
+
+func (a *Aircraft) verticalRate() int {
+	// returns the aircraft's vertical rate in feet/minute, as reported by readsb sources, or from its track history
+	if !a.MetaSource.Time.IsZero() {
+		return a.Meta.VerticalRate()
+	}
+	fpm, _ := historyVerticalRate(a.History)
+	return fpm
+}
+
+func (a *Aircraft) classifyFlightPhase(t time.Time) (phase FlightPhase, onGround bool) {
+	// returns the flight phase the aircraft is in at t, given the phase it was in
+	current := a.FlightPhase
+	switch a.lastAirGround {
+
+	case readsb_protobuf.AircraftMeta_AG_GROUND:
+		switch {
+		case current == PHASE_UNKNOWN || current == PHASE_TAXI:
+			if a.GroundSpeed > FLIGHT_PHASE_ROLL_GS_KTS {
+				return PHASE_TAKEOFF, true
+			}
+			return PHASE_TAXI, true
+		case !a.phaseOnGround && current != PHASE_UNKNOWN:
+			// just touched down
+			return PHASE_LANDING, true
+		case a.GroundSpeed < FLIGHT_PHASE_TAXI_GS_KTS:
+			// roll finished, or takeoff rejected
+			return PHASE_TAXI, true
+		default:
+			return current, true
+		}
+
+	case readsb_protobuf.AircraftMeta_AG_AIRBORNE:
+		// just lifted off
+		if a.phaseOnGround && current != PHASE_UNKNOWN {
+			return PHASE_TAKEOFF, false
+		}
+
+		rate := a.verticalRate()
+
+		// stays taking off until it has been airborne for a while, or starts descending
+		if current == PHASE_TAKEOFF && t.Sub(a.phaseSince) < time.Second*FLIGHT_PHASE_TAKEOFF_SECONDS && rate > -FLIGHT_PHASE_LEVEL_RATE_FPM {
+			return PHASE_TAKEOFF, false
+		}
+
+		// hold airborne phases for a while, so noisy vertical rates don't make them flap
+		if current != PHASE_UNKNOWN && current != PHASE_TAKEOFF && t.Sub(a.phaseSince) < time.Second*FLIGHT_PHASE_MIN_SECONDS {
+			return current, false
+		}
+
+		climbRate := FLIGHT_PHASE_CLIMB_RATE_FPM
+		if current == PHASE_CLIMB || current == PHASE_TAKEOFF {
+			climbRate = FLIGHT_PHASE_LEVEL_RATE_FPM
+		}
+		descentRate := FLIGHT_PHASE_CLIMB_RATE_FPM
+		if current == PHASE_DESCENT || current == PHASE_APPROACH {
+			descentRate = FLIGHT_PHASE_LEVEL_RATE_FPM
+		}
+		approachAlt := FLIGHT_PHASE_APPROACH_ALT_FT
+		if current == PHASE_APPROACH {
+			approachAlt = FLIGHT_PHASE_APPROACH_EXIT_FT
+		}
+		descending := rate <= -descentRate
+		switch {
+		case rate >= climbRate:
+			// includes going around
+			return PHASE_CLIMB, false
+		case (descending || current == PHASE_APPROACH) && (a.AltBaro < approachAlt || a.Meta.NavModes.Approach):
+			return PHASE_APPROACH, false
+		case descending:
+			return PHASE_DESCENT, false
+		default:
+			return PHASE_CRUISE, false
+		}
+
+	default:
+		return current, a.phaseOnGround
+	}
+}
+
+func (adb *AircraftDB) updateFlightPhase(icao int, a *Aircraft, fs FieldSource) {
+	// works out a.FlightPhase, and emits EVENT_DEPARTED & EVENT_LANDED when it goes between ground & airborne phases
+	// adb.Mutex must be held
+	phase, onGround := a.classifyFlightPhase(fs.Time)
+	if phase == a.FlightPhase && onGround == a.phaseOnGround {
+		return
+	}
+	previous, previousOnGround := a.FlightPhase, a.phaseOnGround
+	a.FlightPhase = phase
+	a.phaseOnGround = onGround
+	a.phaseSince = fs.Time
+	adb.changed()
+
+	// the first definite phase isn't a departure or landing
+	if previous == PHASE_UNKNOWN {
+		return
+	}
+	switch {
+	case previousOnGround && !onGround:
+		adb.emit(EVENT_DEPARTED, icao, a, fs.Time)
+	case !previousOnGround && onGround:
+		adb.emit(EVENT_LANDED, icao, a, fs.Time)
+	}
+}
@@ -0,0 +1,175 @@
+package datasources
+
+import (
+	"pw_slippymap/datasources/readsb_protobuf"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryVerticalRate(t *testing.T) {
+	start := time.Now()
+	point := func(seconds, alt int) AircraftHistoryLocation {
+		return AircraftHistoryLocation{Time: start.Add(time.Second * time.Duration(seconds)), Alt: alt}
+	}
+
+	t.Run("Test climbing", func(t *testing.T) {
+		fpm, ok := historyVerticalRate([]AircraftHistoryLocation{point(0, 1000), point(10, 1100), point(20, 1200), point(30, 1300)})
+		assert.True(t, ok)
+		assert.Equal(t, 600, fpm)
+	})
+
+	t.Run("Test only recent history", func(t *testing.T) {
+		// level for a long time, then descending
+		fpm, ok := historyVerticalRate([]AircraftHistoryLocation{point(0, 5000), point(100, 5000), point(110, 4900), point(120, 4800), point(130, 4700)})
+		assert.True(t, ok)
+		assert.Equal(t, -600, fpm)
+	})
+
+	t.Run("Test not enough history", func(t *testing.T) {
+		_, ok := historyVerticalRate(nil)
+		assert.False(t, ok)
+		_, ok = historyVerticalRate([]AircraftHistoryLocation{point(0, 1000)})
+		assert.False(t, ok)
+		_, ok = historyVerticalRate([]AircraftHistoryLocation{point(0, 1000), point(2, 1100)})
+		assert.False(t, ok)
+	})
+}
+
+func TestFlightPhaseString(t *testing.T) {
+	assert.Equal(t, "approach", PHASE_APPROACH.String())
+	assert.Equal(t, "unknown", PHASE_UNKNOWN.String())
+	assert.Equal(t, "^", PHASE_CLIMB.Glyph())
+	assert.Equal(t, "", PHASE_UNKNOWN.Glyph())
+}
+
+func TestAircraftDBFlightPhase(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	sub := adb.Subscribe(0)
+	defer sub.Unsubscribe()
+	fs := FieldSource{Source: "test", Time: time.Now()}
+	wait := func(seconds int) FieldSource {
+		fs.Time = fs.Time.Add(time.Second * time.Duration(seconds))
+		return fs
+	}
+	phase := func() FlightPhase {
+		return adb.GetAircraft()[0x7C6DD8].FlightPhase
+	}
+	setRate := func(fpm int, seconds int) {
+		adb.SetMeta(0x7C6DD8, AircraftMeta{BaroRate: fpm}, wait(seconds))
+	}
+
+	// skip the events we're not interested in
+	nextPhaseEvent := func(t *testing.T) Event {
+		t.Helper()
+		for {
+			e := nextEvent(t, sub)
+			if e.Type == EVENT_DEPARTED || e.Type == EVENT_LANDED {
+				return e
+			}
+		}
+	}
+	assertNoPhaseEvent := func(t *testing.T) {
+		t.Helper()
+		for {
+			select {
+			case e := <-sub.C:
+				assert.NotEqual(t, EVENT_DEPARTED, e.Type)
+				assert.NotEqual(t, EVENT_LANDED, e.Type)
+			default:
+				return
+			}
+		}
+	}
+
+	t.Run("Test unknown", func(t *testing.T) {
+		adb.SetPosition(0x7C6DD8, -31.9, 115.9, 0, wait(1))
+		adb.SetLastSeen(0x7C6DD8, fs)
+		assert.Equal(t, PHASE_UNKNOWN, phase())
+	})
+
+	t.Run("Test taxi & takeoff roll", func(t *testing.T) {
+		adb.SetAirGround(0x7C6DD8, readsb_protobuf.AircraftMeta_AG_GROUND, wait(1))
+		adb.SetGs(0x7C6DD8, 15, wait(1))
+		assert.Equal(t, PHASE_TAXI, phase())
+		adb.SetGs(0x7C6DD8, 35, wait(1))
+		assert.Equal(t, PHASE_TAXI, phase())
+		adb.SetGs(0x7C6DD8, 90, wait(1))
+		assert.Equal(t, PHASE_TAKEOFF, phase())
+		adb.SetGs(0x7C6DD8, 35, wait(1))
+		assert.Equal(t, PHASE_TAKEOFF, phase(), "hysteresis")
+		adb.SetGs(0x7C6DD8, 140, wait(1))
+		assertNoPhaseEvent(t)
+	})
+
+	t.Run("Test departed", func(t *testing.T) {
+		adb.SetAirGround(0x7C6DD8, readsb_protobuf.AircraftMeta_AG_AIRBORNE, wait(1))
+		e := nextPhaseEvent(t)
+		assert.Equal(t, EVENT_DEPARTED, e.Type)
+		assert.Equal(t, PHASE_TAKEOFF, e.Aircraft.FlightPhase)
+
+		// still taking off shortly after lifting off
+		setRate(2000, 10)
+		assert.Equal(t, PHASE_TAKEOFF, phase())
+		adb.SetPosition(0x7C6DD8, -31.9, 115.9, 8000, wait(FLIGHT_PHASE_TAKEOFF_SECONDS))
+		setRate(2100, 1)
+		assert.Equal(t, PHASE_CLIMB, phase())
+	})
+
+	t.Run("Test climb & cruise hysteresis", func(t *testing.T) {
+		// climbing slowly is still climbing
+		setRate(300, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_CLIMB, phase())
+		setRate(0, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_CRUISE, phase())
+
+		// but doesn't start a climb
+		setRate(300, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_CRUISE, phase())
+
+		// and phases are held for a while
+		setRate(1000, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_CLIMB, phase())
+		setRate(-1000, 1)
+		assert.Equal(t, PHASE_CLIMB, phase())
+		setRate(-1100, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_DESCENT, phase())
+	})
+
+	t.Run("Test approach", func(t *testing.T) {
+		adb.SetPosition(0x7C6DD8, -31.9, 115.9, 2500, wait(FLIGHT_PHASE_MIN_SECONDS))
+		assert.Equal(t, PHASE_APPROACH, phase())
+
+		// level segment of an approach
+		setRate(0, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_APPROACH, phase())
+
+		// go around
+		setRate(1500, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_CLIMB, phase())
+		setRate(-800, FLIGHT_PHASE_MIN_SECONDS)
+		assert.Equal(t, PHASE_APPROACH, phase())
+	})
+
+	t.Run("Test landed", func(t *testing.T) {
+		adb.SetAirGround(0x7C6DD8, readsb_protobuf.AircraftMeta_AG_GROUND, wait(30))
+		e := nextPhaseEvent(t)
+		assert.Equal(t, EVENT_LANDED, e.Type)
+		assert.Equal(t, PHASE_LANDING, e.Aircraft.FlightPhase)
+
+		adb.SetGs(0x7C6DD8, 60, wait(10))
+		assert.Equal(t, PHASE_LANDING, phase())
+		adb.SetGs(0x7C6DD8, 20, wait(10))
+		assert.Equal(t, PHASE_TAXI, phase())
+	})
+
+	t.Run("Test vertical rate from history", func(t *testing.T) {
+		adb.SetAirGround(0x7C79CA, readsb_protobuf.AircraftMeta_AG_AIRBORNE, wait(1))
+		for alt := 10000; alt <= 11000; alt += 200 {
+			adb.SetPosition(0x7C79CA, -31.9, 115.9, alt, wait(FLIGHT_PHASE_MIN_SECONDS))
+		}
+		assert.Equal(t, PHASE_CLIMB, adb.GetAircraft()[0x7C79CA].FlightPhase)
+	})
+}
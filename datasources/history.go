@@ -0,0 +1,154 @@
+package datasources
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	HISTORY_MAX_AGE_SECONDS      = 3600    // points older than this (relative to the newest point) are dropped
+	HISTORY_MAX_POINTS           = 1000    // tracks longer than this are decimated, then trimmed
+	HISTORY_DECIMATE_TOLERANCE_M = 25      // metres, points closer than this to the decimated track are dropped
+	HISTORY_TRIM_TO_PERCENT      = 90      // when decimation isn't enough, the oldest points are dropped down to this % of max points
+	EARTH_RADIUS_METRES          = 6371000 // mean radius
+)
+
+// AircraftHistoryLocation is a point on an aircraft's track
+type AircraftHistoryLocation struct {
+	Time        time.Time
+	Lat         float64
+	Long        float64
+	Alt         int
+	GroundSpeed int
+	Track       int
+}
+
+// HistoryRetention controls how much track history the AircraftDB keeps for each aircraft
+type HistoryRetention struct {
+	MaxAge            time.Duration // points older than this, relative to the newest point, are dropped (0 = no limit)
+	MaxPoints         int           // tracks longer than this are decimated, then trimmed (0 = no limit)
+	DecimateTolerance float64       // metres, see decimateHistory (0 = don't decimate)
+}
+
+func DefaultHistoryRetention() HistoryRetention {
+	// Returns the default HistoryRetention
+	return HistoryRetention{
+		MaxAge:            time.Second * HISTORY_MAX_AGE_SECONDS,
+		MaxPoints:         HISTORY_MAX_POINTS,
+		DecimateTolerance: HISTORY_DECIMATE_TOLERANCE_M,
+	}
+}
+
+func insertHistory(history []AircraftHistoryLocation, ahl AircraftHistoryLocation) []AircraftHistoryLocation {
+	// inserts ahl into history, keeping history in time order
+	// points usually arrive in order, so check the end first
+	if len(history) == 0 || !ahl.Time.Before(history[len(history)-1].Time) {
+		return append(history, ahl)
+	}
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].Time.After(ahl.Time)
+	})
+	history = append(history, AircraftHistoryLocation{})
+	copy(history[i+1:], history[i:])
+	history[i] = ahl
+	return history
+}
+
+func (r HistoryRetention) apply(history []AircraftHistoryLocation) []AircraftHistoryLocation {
+	// returns history with the retention policy applied
+
+	// by age
+	if r.MaxAge > 0 && len(history) > 0 {
+		oldest := history[len(history)-1].Time.Add(-r.MaxAge)
+		i := sort.Search(len(history), func(i int) bool {
+			return !history[i].Time.Before(oldest)
+		})
+		history = history[i:]
+	}
+
+	// by number of points
+	if r.MaxPoints > 0 && len(history) > r.MaxPoints {
+		if r.DecimateTolerance > 0 {
+			history = decimateHistory(history, r.DecimateTolerance)
+		}
+		// if decimation didn't get below HISTORY_TRIM_TO_PERCENT of MaxPoints, drop the oldest points
+		// otherwise a track decimation only just got under MaxPoints would be decimated again for every new point
+		keep := r.MaxPoints * HISTORY_TRIM_TO_PERCENT / 100
+		if keep < 1 {
+			keep = 1
+		}
+		if len(history) > keep {
+			history = history[len(history)-keep:]
+		}
+	}
+	return history
+}
+
+func (ahl AircraftHistoryLocation) localXY(origin AircraftHistoryLocation) (x, y float64) {
+	// returns the position of ahl in metres east & north of origin (equirectangular approximation)
+	x = (ahl.Long - origin.Long) * math.Pi / 180 * EARTH_RADIUS_METRES * math.Cos(origin.Lat*math.Pi/180)
+	y = (ahl.Lat - origin.Lat) * math.Pi / 180 * EARTH_RADIUS_METRES
+	return x, y
+}
+
+func distanceToSegment(px, py, ax, ay, bx, by float64) float64 {
+	// returns the distance from point p to the line segment a-b
+	dx, dy := bx-ax, by-ay
+	if dx == 0 && dy == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+	t := ((px-ax)*dx + (py-ay)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+func decimateHistory(history []AircraftHistoryLocation, tolerance float64) []AircraftHistoryLocation {
+	// returns history simplified with the Douglas-Peucker algorithm
+	// points within tolerance metres of the simplified track are dropped, the first and last points are always kept
+	if len(history) < 3 {
+		return history
+	}
+
+	// project to metres around the first point
+	xs := make([]float64, len(history))
+	ys := make([]float64, len(history))
+	for i, ahl := range history {
+		xs[i], ys[i] = ahl.localXY(history[0])
+	}
+
+	keep := make([]bool, len(history))
+	keep[0] = true
+	keep[len(history)-1] = true
+
+	// iterative, so long tracks can't blow the stack
+	type span struct{ first, last int }
+	stack := []span{{0, len(history) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		// find the point furthest from the line between the ends of the span
+		furthest, furthestDist := -1, tolerance
+		for i := s.first + 1; i < s.last; i++ {
+			d := distanceToSegment(xs[i], ys[i], xs[s.first], ys[s.first], xs[s.last], ys[s.last])
+			if d > furthestDist {
+				furthest, furthestDist = i, d
+			}
+		}
+
+		// if it's outside the tolerance, keep it & simplify either side of it
+		if furthest >= 0 {
+			keep[furthest] = true
+			stack = append(stack, span{s.first, furthest}, span{furthest, s.last})
+		}
+	}
+
+	output := make([]AircraftHistoryLocation, 0, len(history))
+	for i, ahl := range history {
+		if keep[i] {
+			output = append(output, ahl)
+		}
+	}
+	return output
+}
@@ -0,0 +1,119 @@
+package datasources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func straightTrack(start time.Time, n int) []AircraftHistoryLocation {
+	// returns n points heading north, one per second, ~111m apart
+	history := make([]AircraftHistoryLocation, 0, n)
+	for i := 0; i < n; i++ {
+		history = append(history, AircraftHistoryLocation{
+			Time: start.Add(time.Second * time.Duration(i)),
+			Lat:  -32 + float64(i)*0.001,
+			Long: 115.9,
+		})
+	}
+	return history
+}
+
+func TestInsertHistory(t *testing.T) {
+
+	start := time.Now()
+	var history []AircraftHistoryLocation
+	for _, offset := range []int{0, 2, 1, 3, -1} {
+		history = insertHistory(history, AircraftHistoryLocation{
+			Time: start.Add(time.Second * time.Duration(offset)),
+			Alt:  offset,
+		})
+	}
+
+	require.Len(t, history, 5)
+	for i, ahl := range history {
+		assert.Equal(t, i-1, ahl.Alt)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+
+	start := time.Now()
+
+	t.Run("Test max age", func(t *testing.T) {
+		r := HistoryRetention{MaxAge: time.Second * 10}
+		history := r.apply(straightTrack(start, 30))
+		require.Len(t, history, 11)
+		assert.Equal(t, start.Add(time.Second*19), history[0].Time)
+		assert.Equal(t, start.Add(time.Second*29), history[10].Time)
+	})
+
+	t.Run("Test max points decimates", func(t *testing.T) {
+		r := HistoryRetention{MaxPoints: 10, DecimateTolerance: 25}
+		history := r.apply(straightTrack(start, 30))
+		require.Len(t, history, 2)
+		assert.Equal(t, start, history[0].Time)
+		assert.Equal(t, start.Add(time.Second*29), history[1].Time)
+	})
+
+	t.Run("Test max points trims oldest", func(t *testing.T) {
+		r := HistoryRetention{MaxPoints: 10}
+		history := r.apply(straightTrack(start, 30))
+		require.Len(t, history, 10*HISTORY_TRIM_TO_PERCENT/100)
+		assert.Equal(t, start.Add(time.Second*29), history[len(history)-1].Time)
+	})
+
+	t.Run("Test decimated track isn't decimated again for each new point", func(t *testing.T) {
+		// a zigzag, so decimation can only drop the first point's straight neighbour
+		history := straightTrack(start, 101)
+		for i := 3; i < len(history); i += 2 {
+			history[i].Long += 0.01
+		}
+		r := HistoryRetention{MaxPoints: 100, DecimateTolerance: 25}
+		history = r.apply(history)
+		require.Len(t, history, 100*HISTORY_TRIM_TO_PERCENT/100)
+		assert.Equal(t, start.Add(time.Second*100), history[len(history)-1].Time)
+
+		// room for new points before the limit is reached again
+		history = r.apply(insertHistory(history, AircraftHistoryLocation{Time: start.Add(time.Second * 101), Lat: -31.8, Long: 115.9}))
+		assert.Len(t, history, 100*HISTORY_TRIM_TO_PERCENT/100+1)
+	})
+
+	t.Run("Test no limits", func(t *testing.T) {
+		assert.Len(t, HistoryRetention{}.apply(straightTrack(start, 30)), 30)
+	})
+}
+
+func TestDecimateHistory(t *testing.T) {
+
+	start := time.Now()
+
+	t.Run("Test straight line", func(t *testing.T) {
+		history := decimateHistory(straightTrack(start, 100), 25)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("Test corner is kept", func(t *testing.T) {
+		// north, then east
+		history := straightTrack(start, 10)
+		for i := 1; i < 10; i++ {
+			history = append(history, AircraftHistoryLocation{
+				Time: start.Add(time.Second * time.Duration(9+i)),
+				Lat:  history[9].Lat,
+				Long: history[9].Long + float64(i)*0.001,
+			})
+		}
+		decimated := decimateHistory(history, 25)
+		require.Len(t, decimated, 3)
+		assert.Equal(t, history[9], decimated[1])
+	})
+
+	t.Run("Test small deviation is dropped", func(t *testing.T) {
+		history := straightTrack(start, 3)
+		history[1].Long += 0.0001 // ~9m
+		assert.Len(t, decimateHistory(history, 25), 2)
+		assert.Len(t, decimateHistory(history, 5), 3)
+	})
+}
@@ -0,0 +1,71 @@
+package datasources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestICAOCountry(t *testing.T) {
+
+	t.Run("Test lookup", func(t *testing.T) {
+		for _, test := range []struct {
+			icao     int
+			country  string
+			code     string
+			ok       bool
+			military bool
+		}{
+			{0x7C1465, "Australia", "AU", true, false},
+			{0x7D1234, "Australia", "AU", true, true},
+			{0xA12345, "United States", "US", true, false},
+			{0xAE1234, "United States", "US", true, true},
+			{0x400000, "United Kingdom", "GB", true, true},
+			{0x406A9B, "United Kingdom", "GB", true, false},
+			{0x3C6444, "Germany", "DE", true, false},
+			{0xC80000, "New Zealand", "NZ", true, false},
+			{0x000000, "", "", false, false},
+			{0xFFFFFF, "", "", false, false},
+			{0x7C1465 | NON_ICAO_ADDRESS_FLAG, "", "", false, false},
+			{0xAE1234 | NON_ICAO_ADDRESS_FLAG, "", "", false, false},
+		} {
+			country, code, ok := ICAOCountry(test.icao)
+			assert.Equal(t, test.country, country, "%X", test.icao)
+			assert.Equal(t, test.code, code, "%X", test.icao)
+			assert.Equal(t, test.ok, ok, "%X", test.icao)
+			assert.Equal(t, test.military, ICAOMilitary(test.icao), "%X", test.icao)
+		}
+	})
+
+	t.Run("Test ranges sorted", func(t *testing.T) {
+		for name, ranges := range map[string][]icaoRange{"country": icaoCountryRanges, "military": icaoMilitaryRanges} {
+			for i, r := range ranges {
+				assert.LessOrEqual(t, r.start, r.end, "%s %X", name, r.start)
+				if i > 0 {
+					assert.Greater(t, r.start, ranges[i-1].end, "%s %X overlaps %X", name, r.start, ranges[i-1].start)
+				}
+			}
+		}
+	})
+
+	t.Run("Test military blocks within country", func(t *testing.T) {
+		for _, r := range icaoMilitaryRanges {
+			for _, icao := range []int{r.start, r.end} {
+				_, code, ok := ICAOCountry(icao)
+				assert.True(t, ok, "%X", icao)
+				assert.Equal(t, r.code, code, "%X", icao)
+			}
+		}
+	})
+
+	t.Run("Test aircraft", func(t *testing.T) {
+		adb := NewAircraftDB(60)
+		fs := FieldSource{Source: "test", Time: time.Now()}
+		adb.SetLastSeen(0xAE1234, fs)
+		a := adb.GetAircraft()[0xAE1234]
+		assert.Equal(t, "United States", a.Country)
+		assert.Equal(t, "US", a.CountryCode)
+		assert.True(t, a.Military)
+	})
+}
@@ -0,0 +1,179 @@
+//go:build !js
+
+// nats-server does not build for webassembly
+
+package datasources
+
+import (
+	"context"
+	"net"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"strings"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLocationUpdates = `{"Icao":"7C79CA","Lat":-32.125122,"Lon":115.879999,"Heading":111.2,"Velocity":104.9,"Altitude":1300,"OnGround":false,"HasAltitude":true,"HasLocation":true,"HasHeading":true,"HasVelocity":true,"CallSign":"HARR89"}
+{"Icao":"7C6DD8","Lat":-31.940310,"Lon":115.966700,"Altitude":0,"OnGround":true,"HasLocation":true,"CallSign":"QFA793"}
+{"Icao":"7C1465","Lat":-31.9,"Lon":115.9,"HasLocation":true}
+not json`
+
+const testLocationRemoved = `{"Icao":"7C1465","Removed":true}`
+
+func TestDecodeLocationUpdate(t *testing.T) {
+	update, icao, err := decodeLocationUpdate([]byte(`{"Icao":"7C79CA","HasLocation":true,"Lat":-32.1,"Lon":115.8,"CallSign":"HARR89"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 0x7C79CA, icao)
+	assert.True(t, update.HasLocation)
+	assert.Equal(t, -32.1, update.Lat)
+	assert.Equal(t, "HARR89", *update.CallSign)
+
+	_, _, err = decodeLocationUpdate([]byte(`{"Icao":"ZZZ"}`))
+	assert.Error(t, err)
+	_, _, err = decodeLocationUpdate([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestMessageBusSourceNATS(t *testing.T) {
+
+	// in-process NATS server on a random port
+	ns, err := natsserver.NewServer(&natsserver.Options{Host: "127.0.0.1", Port: natsserver.RANDOM_PORT, NoSigs: true, NoLog: true})
+	require.NoError(t, err)
+	go ns.Start()
+	defer ns.Shutdown()
+	require.True(t, ns.ReadyForConnections(time.Second*5))
+
+	adb := NewAircraftDB(60)
+	src := NewMessageBusSource(MessageBusConfig{URL: ns.ClientURL(), RoutingKey: "location-updates.test"}, adb)
+	assert.Implements(t, (*DataSource)(nil), src)
+	assert.Contains(t, src.Name(), ns.ClientURL())
+
+	require.NoError(t, src.Start(context.Background()))
+	defer src.Stop()
+
+	// publish until the source has subscribed & processed the updates
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	require.Eventually(t, func() bool {
+		for _, line := range strings.Split(testLocationUpdates, "\n") {
+			require.NoError(t, nc.Publish("location-updates.test", []byte(line)))
+		}
+		require.NoError(t, nc.Flush())
+		return src.Health().Status == DATASOURCE_CONNECTED
+	}, time.Second*5, time.Millisecond*100)
+
+	output := adb.GetAircraft()
+
+	t.Run("Test airborne aircraft", func(t *testing.T) {
+		require.Contains(t, output, 0x7C79CA)
+		a := output[0x7C79CA]
+		assert.Equal(t, "HARR89", a.Callsign)
+		assert.Equal(t, -32.125122, a.Lat)
+		assert.Equal(t, 115.879999, a.Long)
+		assert.Equal(t, 1300, a.AltBaro)
+		assert.Equal(t, 111, a.Track)
+		assert.Equal(t, 104, a.GroundSpeed)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_AIRBORNE, a.AirGround)
+	})
+
+	t.Run("Test aircraft on ground", func(t *testing.T) {
+		require.Contains(t, output, 0x7C6DD8)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_GROUND, output[0x7C6DD8].AirGround)
+	})
+
+	t.Run("Test removed aircraft", func(t *testing.T) {
+		require.Contains(t, output, 0x7C1465)
+		require.NoError(t, nc.Publish("location-updates.test", []byte(testLocationRemoved)))
+		require.NoError(t, nc.Flush())
+		require.Eventually(t, func() bool {
+			_, ok := adb.GetAircraft()[0x7C1465]
+			return !ok
+		}, time.Second*5, time.Millisecond*50)
+		assert.Contains(t, adb.GetAircraft(), 0x7C79CA)
+	})
+
+	t.Run("Test failure reporting when the server goes away", func(t *testing.T) {
+		ns.Shutdown()
+		require.Eventually(t, func() bool {
+			return src.Health().Status == DATASOURCE_FAILED
+		}, time.Second*5, time.Millisecond*50)
+	})
+}
+
+func TestMessageBusSourceAMQP(t *testing.T) {
+
+	// fake AMQP broker that accepts the connection then hangs up during the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	src := NewMessageBusSource(MessageBusConfig{URL: "amqp://guest:secret@" + listener.Addr().String() + "/"}, NewAircraftDB(60))
+	assert.NotContains(t, src.Name(), "secret")
+	require.NoError(t, src.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return src.Health().Status == DATASOURCE_FAILED
+	}, time.Second*5, time.Millisecond*50)
+	assert.Error(t, src.Health().LastError)
+	require.NoError(t, src.Stop())
+}
+
+func TestMessageBusSourceAMQPConsume(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	src := NewMessageBusSource(MessageBusConfig{URL: "amqp://localhost/"}, adb)
+
+	deliver := func(lines ...string) int {
+		// consumes a delivery of each line, as the AMQP channel would
+		deliveries := make(chan amqp.Delivery, len(lines))
+		for i, line := range lines {
+			deliveries <- amqp.Delivery{Body: []byte(line), RoutingKey: "location-updates.test", DeliveryTag: uint64(i + 1)}
+		}
+		close(deliveries)
+		return src.consumeAMQP(deliveries)
+	}
+
+	// 7C1465's updates have no altitude, so it should keep the one from another source
+	adb.SetPosition(0x7C1465, -31.8, 115.8, 5000, FieldSource{Source: "readsb", Time: time.Now().Add(-time.Second)})
+
+	lines := strings.Split(testLocationUpdates, "\n")
+	assert.Equal(t, len(lines), deliver(lines...))
+	require.Contains(t, adb.GetAircraft(), 0x7C1465)
+	assert.Equal(t, -31.9, adb.GetAircraft()[0x7C1465].Lat)
+	assert.Equal(t, 5000, adb.GetAircraft()[0x7C1465].AltBaro)
+	assert.Equal(t, 1, deliver(testLocationRemoved))
+
+	output := adb.GetAircraft()
+	require.Contains(t, output, 0x7C79CA)
+	assert.Equal(t, "HARR89", output[0x7C79CA].Callsign)
+	assert.Equal(t, -32.125122, output[0x7C79CA].Lat)
+	assert.Equal(t, readsb_protobuf.AircraftMeta_AG_AIRBORNE, output[0x7C79CA].AirGround)
+	require.Contains(t, output, 0x7C6DD8)
+	assert.Equal(t, readsb_protobuf.AircraftMeta_AG_GROUND, output[0x7C6DD8].AirGround)
+	assert.NotContains(t, output, 0x7C1465)
+	assert.Positive(t, src.Health().Updates)
+}
+
+func TestMessageBusSourceBadURL(t *testing.T) {
+	src := NewMessageBusSource(MessageBusConfig{URL: "http://localhost/"}, NewAircraftDB(60))
+	require.NoError(t, src.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return src.Health().LastError != nil
+	}, time.Second*5, time.Millisecond*50)
+	assert.ErrorIs(t, src.Health().LastError, ErrMessageBusScheme)
+}
@@ -0,0 +1,144 @@
+package datasources
+
+import (
+	"math"
+	"pw_slippymap/datasources/modes"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"time"
+)
+
+// modeSDecoder decodes raw Mode S messages and updates an AircraftDB, shared by the raw message data sources
+type modeSDecoder struct {
+	source  string // name of the data source, for the AircraftDB
+	adb     *AircraftDB
+	tracker *modes.Tracker
+
+	// partial state per aircraft, position messages don't always carry an altitude
+	aircraft   map[int]*modeSAircraft
+	lastForget time.Time
+}
+
+// modeSAircraft holds the last known values for an aircraft, so partial messages can be merged
+type modeSAircraft struct {
+	altitude       int
+	lastMessageRxd time.Time
+}
+
+func newModeSDecoder(source string, adb *AircraftDB) *modeSDecoder {
+	// Returns a modeSDecoder that updates adb on behalf of the data source named source
+	return &modeSDecoder{
+		source:   source,
+		adb:      adb,
+		tracker:  modes.NewTracker(),
+		aircraft: make(map[int]*modeSAircraft),
+	}
+}
+
+func (d *modeSDecoder) decode(frame []byte, now time.Time) error {
+	// decodes a raw Mode S message and applies it to the AircraftDB
+	msg, err := d.tracker.Decode(frame, now)
+	if err != nil {
+		return err
+	}
+	d.apply(msg, now)
+
+	if now.Sub(d.lastForget) > time.Second {
+		d.forgetIdle(now)
+		d.lastForget = now
+	}
+	return nil
+}
+
+func (d *modeSDecoder) apply(msg *modes.Message, now time.Time) {
+	// merges msg with what we already know about the aircraft, then updates the AircraftDB
+
+	icao := int(msg.ICAO)
+	if msg.NonICAO {
+		icao |= NON_ICAO_ADDRESS_FLAG
+	}
+
+	a, ok := d.aircraft[icao]
+	if !ok {
+		a = &modeSAircraft{}
+		d.aircraft[icao] = a
+	}
+	a.lastMessageRxd = now
+	fs := FieldSource{Source: d.source, Time: now}
+
+	switch msg.Type {
+	case modes.MSG_IDENTIFICATION:
+		d.adb.SetCallsign(icao, msg.Callsign, fs)
+		d.adb.SetCategory(icao, msg.Category, fs)
+
+	case modes.MSG_AIRBORNE_POSITION:
+		if msg.HasAltitude && !msg.GNSSAltitude {
+			a.altitude = msg.Altitude
+		}
+		if msg.HasPosition {
+			d.adb.SetPosition(icao, msg.Lat, msg.Lon, a.altitude, fs)
+			d.adb.SetAirGround(icao, readsb_protobuf.AircraftMeta_AG_AIRBORNE, fs)
+		}
+
+	case modes.MSG_SURFACE_POSITION:
+		a.altitude = 0
+		if msg.HasGroundSpeed {
+			d.adb.SetGs(icao, int(math.Round(msg.GroundSpeed)), fs)
+		}
+		if msg.HasTrack {
+			d.adb.SetTrack(icao, int(math.Round(msg.Track)), fs)
+		}
+		if msg.HasPosition {
+			d.adb.SetPosition(icao, msg.Lat, msg.Lon, a.altitude, fs)
+		}
+		d.adb.SetAirGround(icao, readsb_protobuf.AircraftMeta_AG_GROUND, fs)
+
+	case modes.MSG_AIRBORNE_VELOCITY:
+		if msg.HasGroundSpeed {
+			d.adb.SetGs(icao, int(math.Round(msg.GroundSpeed)), fs)
+		}
+		if msg.HasTrack {
+			d.adb.SetTrack(icao, int(math.Round(msg.Track)), fs)
+		}
+		if meta, fields := velocityMeta(msg); fields != 0 {
+			d.adb.MergeMeta(icao, meta, fields, fs)
+		}
+	}
+
+	d.adb.SetLastSeen(icao, fs)
+}
+
+func velocityMeta(msg *modes.Message) (meta AircraftMeta, fields MetaFields) {
+	// returns the vertical rate, airspeed & heading in an airborne velocity message, & which of them it has
+	if msg.HasVerticalRate {
+		if msg.VerticalRateGNSS {
+			meta.GeomRate = msg.VerticalRate
+			fields |= META_GEOM_RATE
+		} else {
+			meta.BaroRate = msg.VerticalRate
+			fields |= META_BARO_RATE
+		}
+	}
+	if msg.HasAirspeed {
+		if msg.AirspeedIsTrue {
+			meta.Tas = msg.Airspeed
+			fields |= META_TAS
+		} else {
+			meta.Ias = msg.Airspeed
+			fields |= META_IAS
+		}
+	}
+	if msg.HasHeading {
+		meta.MagHeading = int(math.Round(msg.Heading))
+		fields |= META_MAG_HEADING
+	}
+	return meta, fields
+}
+
+func (d *modeSDecoder) forgetIdle(now time.Time) {
+	// forgets partial state for aircraft we haven't heard from
+	for icao, a := range d.aircraft {
+		if now.Sub(a.lastMessageRxd) > time.Second*FORGET_AIRCRAFT_AFTER_SECONDS {
+			delete(d.aircraft, icao)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package modes
+
+// Compact Position Reporting
+// ref: https://mode-s.org/decode/content/ads-b/3-airborne-position.html
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	CPR_NZ  = 15         // number of latitude zones between the equator and a pole
+	CPR_MAX = 1 << 17    // CPR coordinates are 17 bits
+	CPR_AIR = 360.0      // airborne positions cover 360 degrees
+	CPR_SFC = 360.0 / 4  // surface positions cover 90 degrees
+	NL_LAT0 = 87.0       // latitude above which NL is 1
+	NL_MAX  = 4 * CPR_NZ // NL at the equator
+)
+
+var (
+	ErrCPRZoneMismatch = errors.New("even and odd positions are in different longitude zones")
+)
+
+func cprMod(a, b float64) float64 {
+	// modulo that is always positive
+	r := math.Mod(a, b)
+	if r < 0 {
+		r += b
+	}
+	return r
+}
+
+func NL(lat float64) int {
+	// returns the number of longitude zones at latitude lat
+	lat = math.Abs(lat)
+	if lat == 0 {
+		return NL_MAX - 1
+	}
+	if lat == NL_LAT0 {
+		return 2
+	}
+	if lat > NL_LAT0 {
+		return 1
+	}
+	a := 1 - math.Cos(math.Pi/(2*CPR_NZ))
+	b := math.Pow(math.Cos(math.Pi/180*lat), 2)
+	return int(math.Floor(2 * math.Pi / math.Acos(1-a/b)))
+}
+
+func cprDlat(odd bool, span float64) float64 {
+	// returns the size of a latitude zone
+	if odd {
+		return span / (NL_MAX - 1)
+	}
+	return span / NL_MAX
+}
+
+func cprDlon(lat float64, odd bool, span float64) float64 {
+	// returns the size of a longitude zone at latitude lat
+	n := NL(lat)
+	if odd {
+		n -= 1
+	}
+	if n < 1 {
+		n = 1
+	}
+	return span / float64(n)
+}
+
+func cprGlobal(evenLat, evenLon, oddLat, oddLon uint32, oddIsNewest bool, span float64) (lat, lon float64, err error) {
+	// decodes an even/odd pair of CPR positions, span is CPR_AIR or CPR_SFC
+	// for surface positions, the result is one of four possible positions (see DecodeCPRSurfaceGlobal)
+
+	yz0 := float64(evenLat) / CPR_MAX
+	yz1 := float64(oddLat) / CPR_MAX
+	xz0 := float64(evenLon) / CPR_MAX
+	xz1 := float64(oddLon) / CPR_MAX
+
+	// latitude index
+	j := math.Floor(59*yz0 - 60*yz1 + 0.5)
+
+	lat0 := cprDlat(false, span) * (cprMod(j, NL_MAX) + yz0)
+	lat1 := cprDlat(true, span) * (cprMod(j, NL_MAX-1) + yz1)
+	if span == CPR_AIR {
+		if lat0 >= 270 {
+			lat0 -= 360
+		}
+		if lat1 >= 270 {
+			lat1 -= 360
+		}
+	}
+
+	// both positions must be in the same longitude zone
+	if NL(lat0) != NL(lat1) {
+		return 0, 0, ErrCPRZoneMismatch
+	}
+
+	xz := xz0
+	lat = lat0
+	if oddIsNewest {
+		xz = xz1
+		lat = lat1
+	}
+
+	nl := NL(lat)
+	m := math.Floor(xz0*float64(nl-1) - xz1*float64(nl) + 0.5)
+	ni := nl
+	if oddIsNewest {
+		ni = nl - 1
+	}
+	if ni < 1 {
+		ni = 1
+	}
+	lon = (span / float64(ni)) * (cprMod(m, float64(ni)) + xz)
+	if lon >= 180 {
+		lon -= 360
+	}
+
+	return lat, lon, nil
+}
+
+func DecodeCPRAirborneGlobal(evenLat, evenLon, oddLat, oddLon uint32, oddIsNewest bool) (lat, lon float64, err error) {
+	// decodes an even/odd pair of airborne CPR positions, returning the position of the newest
+	return cprGlobal(evenLat, evenLon, oddLat, oddLon, oddIsNewest, CPR_AIR)
+}
+
+func DecodeCPRSurfaceGlobal(evenLat, evenLon, oddLat, oddLon uint32, oddIsNewest bool, refLat, refLon float64) (lat, lon float64, err error) {
+	// decodes an even/odd pair of surface CPR positions, returning the position of the newest
+	// surface positions are ambiguous, so the solution closest to refLat/refLon is chosen
+
+	lat, lon, err = cprGlobal(evenLat, evenLon, oddLat, oddLon, oddIsNewest, CPR_SFC)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// latitude is either northern or southern hemisphere
+	if math.Abs(refLat-(lat-90)) < math.Abs(refLat-lat) {
+		lat -= 90
+	}
+
+	// longitude is one of four 90 degree quadrants
+	best := lon
+	for _, offset := range []float64{-270, -180, -90, 90, 180, 270} {
+		candidate := lon + offset
+		if candidate < -180 || candidate >= 180 {
+			continue
+		}
+		if math.Abs(refLon-candidate) < math.Abs(refLon-best) {
+			best = candidate
+		}
+	}
+
+	return lat, best, nil
+}
+
+func DecodeCPRLocal(cprLat, cprLon uint32, odd, surface bool, refLat, refLon float64) (lat, lon float64) {
+	// decodes a single CPR position relative to a reference position
+	// the reference must be within 180NM (airborne) or 45NM (surface) of the actual position
+
+	span := CPR_AIR
+	if surface {
+		span = CPR_SFC
+	}
+
+	yz := float64(cprLat) / CPR_MAX
+	xz := float64(cprLon) / CPR_MAX
+
+	dlat := cprDlat(odd, span)
+	j := math.Floor(refLat/dlat) + math.Floor(0.5+cprMod(refLat, dlat)/dlat-yz)
+	lat = dlat * (j + yz)
+
+	dlon := cprDlon(lat, odd, span)
+	m := math.Floor(refLon/dlon) + math.Floor(0.5+cprMod(refLon, dlon)/dlon-xz)
+	lon = dlon * (m + xz)
+
+	return lat, lon
+}
@@ -0,0 +1,43 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNL(t *testing.T) {
+	assert.Equal(t, 59, NL(0))
+	assert.Equal(t, 36, NL(52.2572))
+	assert.Equal(t, 36, NL(-52.2572))
+	assert.Equal(t, 2, NL(87))
+	assert.Equal(t, 1, NL(88))
+}
+
+func TestDecodeCPRAirborneGlobal(t *testing.T) {
+	// even is newest
+	lat, lon, err := DecodeCPRAirborneGlobal(93000, 51372, 74158, 50194, false)
+	require.NoError(t, err)
+	assert.InDelta(t, 52.25720, lat, 0.0001)
+	assert.InDelta(t, 3.91937, lon, 0.0001)
+
+	// odd is newest
+	lat, lon, err = DecodeCPRAirborneGlobal(93000, 51372, 74158, 50194, true)
+	require.NoError(t, err)
+	assert.InDelta(t, 52.26578, lat, 0.0001)
+	assert.InDelta(t, 3.93891, lon, 0.0001)
+}
+
+func TestDecodeCPRSurfaceGlobal(t *testing.T) {
+	lat, lon, err := DecodeCPRSurfaceGlobal(115609, 116941, 39199, 110269, false, 51.990, 4.375)
+	require.NoError(t, err)
+	assert.InDelta(t, 52.32304, lat, 0.0001)
+	assert.InDelta(t, 4.73047, lon, 0.0001)
+}
+
+func TestDecodeCPRLocal(t *testing.T) {
+	lat, lon := DecodeCPRLocal(93000, 51372, false, false, 52.258, 3.918)
+	assert.InDelta(t, 52.25720, lat, 0.0001)
+	assert.InDelta(t, 3.91937, lon, 0.0001)
+}
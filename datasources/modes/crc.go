@@ -0,0 +1,37 @@
+package modes
+
+const (
+	CRC_POLYNOMIAL = 0xFFF409 // Mode S CRC-24 generator polynomial
+)
+
+var crcTable [256]uint32
+
+func init() {
+	// pre-compute the CRC table
+	for i := 0; i < 256; i++ {
+		c := uint32(i) << 16
+		for j := 0; j < 8; j++ {
+			if c&0x800000 != 0 {
+				c = (c << 1) ^ CRC_POLYNOMIAL
+			} else {
+				c <<= 1
+			}
+		}
+		crcTable[i] = c & 0xFFFFFF
+	}
+}
+
+func Checksum(data []byte) uint32 {
+	// returns the Mode S CRC-24 of data
+	var c uint32
+	for _, b := range data {
+		c = ((c << 8) ^ crcTable[((c>>16)^uint32(b))&0xFF]) & 0xFFFFFF
+	}
+	return c
+}
+
+func parity(msg []byte) uint32 {
+	// returns the parity (last 24 bits) of a message
+	n := len(msg)
+	return uint32(msg[n-3])<<16 | uint32(msg[n-2])<<8 | uint32(msg[n-1])
+}
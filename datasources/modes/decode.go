@@ -0,0 +1,380 @@
+package modes
+
+// Mode S / ADS-B extended squitter (DF17/DF18) decoder
+// ref: https://mode-s.org/decode/
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	MODES_LONG_MSG_BYTES  = 14 // 112 bit messages
+	MODES_SHORT_MSG_BYTES = 7  // 56 bit messages
+
+	DF_EXTENDED_SQUITTER    = 17 // ADS-B from a Mode S transponder
+	DF_EXTENDED_SQUITTER_NT = 18 // ADS-B from a non-transponder device (or TIS-B / ADS-R)
+)
+
+// MessageType identifies the contents of an extended squitter
+type MessageType int
+
+const (
+	MSG_UNKNOWN MessageType = iota
+	MSG_IDENTIFICATION
+	MSG_SURFACE_POSITION
+	MSG_AIRBORNE_POSITION
+	MSG_AIRBORNE_VELOCITY
+)
+
+var (
+	ErrUnsupportedDF   = errors.New("unsupported downlink format")
+	ErrBadCRC          = errors.New("CRC check failed")
+	ErrMessageTooShort = errors.New("message too short")
+)
+
+// Message is a decoded extended squitter.
+// Only the fields relevant to the Type are populated.
+type Message struct {
+	DF       int         // downlink format
+	ICAO     uint32      // 24-bit address
+	NonICAO  bool        // true if ICAO is not an ICAO 24-bit address (eg: TIS-B track file)
+	TypeCode int         // ADS-B type code
+	Type     MessageType // what the message contains
+
+	// identification
+	Callsign string
+	Category int // emitter category (eg: 0xA3), same as readsb
+
+	// position
+	Altitude     int  // feet
+	HasAltitude  bool // Altitude is valid
+	GNSSAltitude bool // Altitude is GNSS height rather than barometric
+	CPRLat       uint32
+	CPRLon       uint32
+	CPROdd       bool
+	Surface      bool // position is a surface position
+
+	// resolved position, set by Tracker
+	Lat         float64
+	Lon         float64
+	HasPosition bool
+
+	// velocity & surface movement
+	GroundSpeed      float64 // knots
+	HasGroundSpeed   bool
+	Track            float64 // degrees
+	HasTrack         bool
+	Heading          float64 // degrees (airspeed velocity messages only)
+	HasHeading       bool
+	Airspeed         int  // knots
+	AirspeedIsTrue   bool // Airspeed is TAS (otherwise IAS)
+	HasAirspeed      bool
+	VerticalRate     int // feet/minute
+	HasVerticalRate  bool
+	VerticalRateGNSS bool // VerticalRate is from GNSS rather than barometric
+}
+
+func getBits(msg []byte, first, last int) uint64 {
+	// returns bits first to last (inclusive, 1-indexed, as-per the Mode S specs) of msg
+	var v uint64
+	for i := first; i <= last; i++ {
+		b := (msg[(i-1)/8] >> (7 - uint((i-1)%8))) & 1
+		v = (v << 1) | uint64(b)
+	}
+	return v
+}
+
+func Decode(msg []byte) (*Message, error) {
+	// decodes an extended squitter (DF17/DF18)
+
+	if len(msg) < 1 {
+		return nil, ErrMessageTooShort
+	}
+
+	df := int(getBits(msg, 1, 5))
+	if df != DF_EXTENDED_SQUITTER && df != DF_EXTENDED_SQUITTER_NT {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedDF, df)
+	}
+	if len(msg) < MODES_LONG_MSG_BYTES {
+		return nil, ErrMessageTooShort
+	}
+	msg = msg[:MODES_LONG_MSG_BYTES]
+
+	if Checksum(msg[:MODES_LONG_MSG_BYTES-3]) != parity(msg) {
+		return nil, ErrBadCRC
+	}
+
+	m := &Message{
+		DF:       df,
+		ICAO:     uint32(getBits(msg, 9, 32)),
+		TypeCode: int(getBits(msg, 33, 37)),
+	}
+
+	// DF18 control field: 1 and 5 are non-ICAO addresses, 2 (fine TIS-B) depends on the IMF bit
+	if df == DF_EXTENDED_SQUITTER_NT {
+		switch getBits(msg, 6, 8) {
+		case 0, 6:
+			// ADS-B / ADS-R with ICAO address
+		case 1, 5:
+			m.NonICAO = true
+		case 2:
+			m.NonICAO = getBits(msg, 40, 40) == 1
+		default:
+			return m, nil
+		}
+	}
+
+	switch {
+	case m.TypeCode >= 1 && m.TypeCode <= 4:
+		decodeIdentification(msg, m)
+	case m.TypeCode >= 5 && m.TypeCode <= 8:
+		decodeSurfacePosition(msg, m)
+	case m.TypeCode >= 9 && m.TypeCode <= 18, m.TypeCode >= 20 && m.TypeCode <= 22:
+		decodeAirbornePosition(msg, m)
+	case m.TypeCode == 19:
+		decodeVelocity(msg, m)
+	}
+
+	return m, nil
+}
+
+const callsignChars = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+func decodeIdentification(msg []byte, m *Message) {
+	m.Type = MSG_IDENTIFICATION
+
+	// emitter category: TC4 is set A, TC3 is set B, TC2 is set C, TC1 is set D
+	m.Category = ((0xE-m.TypeCode)<<4 | int(getBits(msg, 38, 40)))
+
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		c := getBits(msg, 41+(i*6), 46+(i*6))
+		sb.WriteByte(callsignChars[c])
+	}
+	m.Callsign = sb.String()
+}
+
+func decodeCPR(msg []byte, m *Message) {
+	m.CPROdd = getBits(msg, 54, 54) == 1
+	m.CPRLat = uint32(getBits(msg, 55, 71))
+	m.CPRLon = uint32(getBits(msg, 72, 88))
+}
+
+func decodeAirbornePosition(msg []byte, m *Message) {
+	m.Type = MSG_AIRBORNE_POSITION
+	decodeCPR(msg, m)
+
+	ac12 := int(getBits(msg, 41, 52))
+	if m.TypeCode >= 20 {
+		// GNSS height in metres
+		if ac12 != 0 {
+			m.Altitude = int(math.Round(float64(ac12) * 3.28084))
+			m.HasAltitude = true
+			m.GNSSAltitude = true
+		}
+		return
+	}
+	alt, ok := decodeAC12(ac12)
+	if ok {
+		m.Altitude = alt
+		m.HasAltitude = true
+	}
+}
+
+func decodeSurfacePosition(msg []byte, m *Message) {
+	m.Type = MSG_SURFACE_POSITION
+	m.Surface = true
+	decodeCPR(msg, m)
+
+	m.GroundSpeed, m.HasGroundSpeed = decodeMovement(int(getBits(msg, 38, 44)))
+	if getBits(msg, 45, 45) == 1 {
+		m.Track = float64(getBits(msg, 46, 52)) * 360 / 128
+		m.HasTrack = true
+	}
+}
+
+func decodeMovement(mov int) (speed float64, ok bool) {
+	// decodes the surface position movement field to knots
+	switch {
+	case mov == 1:
+		return 0, true
+	case mov >= 2 && mov <= 8:
+		return 0.125 + float64(mov-2)*0.125, true
+	case mov >= 9 && mov <= 12:
+		return 1 + float64(mov-9)*0.25, true
+	case mov >= 13 && mov <= 38:
+		return 2 + float64(mov-13)*0.5, true
+	case mov >= 39 && mov <= 93:
+		return 15 + float64(mov-39), true
+	case mov >= 94 && mov <= 108:
+		return 70 + float64(mov-94)*2, true
+	case mov >= 109 && mov <= 123:
+		return 100 + float64(mov-109)*5, true
+	case mov == 124:
+		return 175, true
+	default:
+		return 0, false
+	}
+}
+
+func decodeVelocity(msg []byte, m *Message) {
+	m.Type = MSG_AIRBORNE_VELOCITY
+
+	subtype := getBits(msg, 38, 40)
+	switch subtype {
+	case 1, 2:
+		// ground speed
+		vew := int(getBits(msg, 47, 56))
+		vns := int(getBits(msg, 58, 67))
+		if vew != 0 && vns != 0 {
+			multiplier := 1.0
+			if subtype == 2 {
+				multiplier = 4 // supersonic
+			}
+			ew := float64(vew-1) * multiplier
+			ns := float64(vns-1) * multiplier
+			if getBits(msg, 46, 46) == 1 {
+				ew = -ew
+			}
+			if getBits(msg, 57, 57) == 1 {
+				ns = -ns
+			}
+			m.GroundSpeed = math.Sqrt(ew*ew + ns*ns)
+			m.HasGroundSpeed = true
+			m.Track = cprMod(math.Atan2(ew, ns)*180/math.Pi, 360)
+			m.HasTrack = true
+		}
+	case 3, 4:
+		// airspeed & heading
+		if getBits(msg, 46, 46) == 1 {
+			m.Heading = float64(getBits(msg, 47, 56)) * 360 / 1024
+			m.HasHeading = true
+		}
+		as := int(getBits(msg, 58, 67))
+		if as != 0 {
+			m.Airspeed = as - 1
+			if subtype == 4 {
+				m.Airspeed *= 4 // supersonic
+			}
+			m.AirspeedIsTrue = getBits(msg, 57, 57) == 1
+			m.HasAirspeed = true
+		}
+	default:
+		return
+	}
+
+	vr := int(getBits(msg, 70, 78))
+	if vr != 0 {
+		m.VerticalRate = (vr - 1) * 64
+		if getBits(msg, 69, 69) == 1 {
+			m.VerticalRate = -m.VerticalRate
+		}
+		m.HasVerticalRate = true
+		m.VerticalRateGNSS = getBits(msg, 68, 68) == 0
+	}
+}
+
+func decodeAC12(ac12 int) (altitude int, ok bool) {
+	// decodes the 12 bit altitude field in airborne position messages
+	if ac12 == 0 {
+		return 0, false
+	}
+
+	// Q bit set: 25ft increments
+	if ac12&0x10 != 0 {
+		n := ((ac12 & 0x0FE0) >> 1) | (ac12 & 0x000F)
+		return n*25 - 1000, true
+	}
+
+	// Q bit clear: 100ft increments, gillham coded
+	n13 := ((ac12 & 0x0FC0) << 1) | (ac12 & 0x003F)
+	n := modeAToModeC(decodeID13(n13))
+	if n < -12 {
+		return 0, false
+	}
+	return n * 100, true
+}
+
+func decodeID13(id13 int) int {
+	// re-orders the bits of a 13 bit identity/altitude field into "hex gillham" (as-per dump1090)
+	var hexGillham int
+	bitmap := []struct{ from, to int }{
+		{0x1000, 0x0010}, // C1
+		{0x0800, 0x1000}, // A1
+		{0x0400, 0x0020}, // C2
+		{0x0200, 0x2000}, // A2
+		{0x0100, 0x0040}, // C4
+		{0x0080, 0x4000}, // A4
+		{0x0020, 0x0100}, // B1
+		{0x0010, 0x0001}, // D1
+		{0x0008, 0x0200}, // B2
+		{0x0004, 0x0002}, // D2
+		{0x0002, 0x0400}, // B4
+		{0x0001, 0x0004}, // D4
+	}
+	for _, b := range bitmap {
+		if id13&b.from != 0 {
+			hexGillham |= b.to
+		}
+	}
+	return hexGillham
+}
+
+func modeAToModeC(modeA int) int {
+	// converts a "hex gillham" code to altitude in 100ft increments (as-per dump1090)
+	// returns < -12 if invalid
+
+	const invalid = -9999
+
+	if modeA&0xFFFF8889 != 0 || modeA&0x000000F0 == 0 {
+		return invalid
+	}
+
+	var fiveHundreds, oneHundreds int
+
+	if modeA&0x0010 != 0 {
+		oneHundreds ^= 0x007 // C1
+	}
+	if modeA&0x0020 != 0 {
+		oneHundreds ^= 0x003 // C2
+	}
+	if modeA&0x0040 != 0 {
+		oneHundreds ^= 0x001 // C4
+	}
+
+	// remove 7s from oneHundreds (make 7->5, and 5->7)
+	if oneHundreds&5 == 5 {
+		oneHundreds ^= 2
+	}
+
+	// only 1 to 5 are valid
+	if oneHundreds > 5 {
+		return invalid
+	}
+
+	fiveHundredsBits := []struct{ bit, xor int }{
+		{0x0002, 0x0FF}, // D2
+		{0x0004, 0x07F}, // D4
+		{0x1000, 0x03F}, // A1
+		{0x2000, 0x01F}, // A2
+		{0x4000, 0x00F}, // A4
+		{0x0100, 0x007}, // B1
+		{0x0200, 0x003}, // B2
+		{0x0400, 0x001}, // B4
+	}
+	for _, b := range fiveHundredsBits {
+		if modeA&b.bit != 0 {
+			fiveHundreds ^= b.xor
+		}
+	}
+
+	// correct order of oneHundreds
+	if fiveHundreds&1 != 0 {
+		oneHundreds = 6 - oneHundreds
+	}
+
+	return (fiveHundreds * 5) + oneHundreds - 13
+}
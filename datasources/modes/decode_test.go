@@ -0,0 +1,136 @@
+package modes
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// test vectors from https://mode-s.org/decode/
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecode(t *testing.T) {
+
+	t.Run("Test identification", func(t *testing.T) {
+		m, err := Decode(mustHex(t, "8D4840D6202CC371C32CE0576098"))
+		require.NoError(t, err)
+		assert.Equal(t, DF_EXTENDED_SQUITTER, m.DF)
+		assert.Equal(t, uint32(0x4840D6), m.ICAO)
+		assert.Equal(t, MSG_IDENTIFICATION, m.Type)
+		assert.Equal(t, "KLM1023 ", m.Callsign)
+		assert.Equal(t, 0xA0, m.Category)
+	})
+
+	t.Run("Test airborne position", func(t *testing.T) {
+		m, err := Decode(mustHex(t, "8D40621D58C382D690C8AC2863A7"))
+		require.NoError(t, err)
+		assert.Equal(t, uint32(0x40621D), m.ICAO)
+		assert.Equal(t, MSG_AIRBORNE_POSITION, m.Type)
+		assert.True(t, m.HasAltitude)
+		assert.Equal(t, 38000, m.Altitude)
+		assert.False(t, m.CPROdd)
+		assert.Equal(t, uint32(93000), m.CPRLat)
+		assert.Equal(t, uint32(51372), m.CPRLon)
+		assert.False(t, m.HasPosition)
+
+		m, err = Decode(mustHex(t, "8D40621D58C386435CC412692AD6"))
+		require.NoError(t, err)
+		assert.True(t, m.CPROdd)
+		assert.Equal(t, uint32(74158), m.CPRLat)
+		assert.Equal(t, uint32(50194), m.CPRLon)
+	})
+
+	t.Run("Test ground speed velocity", func(t *testing.T) {
+		m, err := Decode(mustHex(t, "8D485020994409940838175B284F"))
+		require.NoError(t, err)
+		assert.Equal(t, MSG_AIRBORNE_VELOCITY, m.Type)
+		assert.True(t, m.HasGroundSpeed)
+		assert.InDelta(t, 159.20, m.GroundSpeed, 0.01)
+		assert.True(t, m.HasTrack)
+		assert.InDelta(t, 182.88, m.Track, 0.01)
+		assert.True(t, m.HasVerticalRate)
+		assert.Equal(t, -832, m.VerticalRate)
+		assert.True(t, m.VerticalRateGNSS)
+	})
+
+	t.Run("Test airspeed velocity", func(t *testing.T) {
+		m, err := Decode(mustHex(t, "8DA05F219B06B6AF189400CBC33F"))
+		require.NoError(t, err)
+		assert.Equal(t, MSG_AIRBORNE_VELOCITY, m.Type)
+		assert.False(t, m.HasGroundSpeed)
+		assert.True(t, m.HasHeading)
+		assert.InDelta(t, 243.98, m.Heading, 0.01)
+		assert.True(t, m.HasAirspeed)
+		assert.True(t, m.AirspeedIsTrue)
+		assert.Equal(t, 375, m.Airspeed)
+		assert.Equal(t, -2304, m.VerticalRate)
+		assert.False(t, m.VerticalRateGNSS)
+	})
+
+	t.Run("Test surface position", func(t *testing.T) {
+		m, err := Decode(mustHex(t, "8C4841753AAB238733C8CD4020B1"))
+		require.NoError(t, err)
+		assert.Equal(t, MSG_SURFACE_POSITION, m.Type)
+		assert.True(t, m.Surface)
+		assert.False(t, m.CPROdd)
+		assert.True(t, m.HasGroundSpeed)
+		assert.Equal(t, 18.0, m.GroundSpeed)
+		assert.True(t, m.HasTrack)
+		assert.Equal(t, 140.625, m.Track)
+	})
+
+	t.Run("Test bad CRC", func(t *testing.T) {
+		msg := mustHex(t, "8D4840D6202CC371C32CE0576098")
+		msg[5] ^= 0x01
+		_, err := Decode(msg)
+		assert.ErrorIs(t, err, ErrBadCRC)
+	})
+
+	t.Run("Test unsupported downlink format", func(t *testing.T) {
+		// DF11 all-call reply
+		_, err := Decode(mustHex(t, "5D4840D6C0A21B"))
+		assert.ErrorIs(t, err, ErrUnsupportedDF)
+	})
+
+	t.Run("Test short message", func(t *testing.T) {
+		_, err := Decode(mustHex(t, "8D4840D6202CC3"))
+		assert.ErrorIs(t, err, ErrMessageTooShort)
+		_, err = Decode(nil)
+		assert.ErrorIs(t, err, ErrMessageTooShort)
+	})
+}
+
+func TestDecodeAC12(t *testing.T) {
+	// Q bit set
+	alt, ok := decodeAC12(0xC38)
+	assert.True(t, ok)
+	assert.Equal(t, 38000, alt)
+
+	// no altitude
+	_, ok = decodeAC12(0)
+	assert.False(t, ok)
+}
+
+func TestDecodeMovement(t *testing.T) {
+	speed, ok := decodeMovement(0)
+	assert.False(t, ok)
+	assert.Equal(t, 0.0, speed)
+
+	speed, ok = decodeMovement(1)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, speed)
+
+	speed, ok = decodeMovement(42)
+	assert.True(t, ok)
+	assert.Equal(t, 18.0, speed)
+
+	speed, ok = decodeMovement(124)
+	assert.True(t, ok)
+	assert.Equal(t, 175.0, speed)
+}
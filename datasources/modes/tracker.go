@@ -0,0 +1,188 @@
+package modes
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	CPR_AIRBORNE_PAIR_SECONDS = 10 // max time between even & odd airborne positions for global decoding
+	CPR_SURFACE_PAIR_SECONDS  = 50 // max time between even & odd surface positions for global decoding
+	CPR_LOCAL_MAX_SECONDS     = 60 // max age of last position to use as the reference for local decoding
+	TRACKER_FORGET_SECONDS    = 60 // forget aircraft state after this long
+)
+
+// Tracker holds per-aircraft CPR state so positions can be resolved from consecutive messages
+type Tracker struct {
+	aircraft map[uint32]*trackedAircraft
+	mutex    sync.Mutex
+
+	// receiver location, used to resolve surface positions
+	refLat, refLon float64
+	hasRef         bool
+
+	// last airborne position of any aircraft, used to resolve surface positions if there's no receiver location
+	lastAirborneLat, lastAirborneLon float64
+	hasLastAirborne                  bool
+
+	lastForget time.Time
+}
+
+// cprFrame is a received CPR position
+type cprFrame struct {
+	lat, lon uint32
+	surface  bool
+	received time.Time
+}
+
+// trackedAircraft holds the CPR state for an aircraft
+type trackedAircraft struct {
+	even, odd *cprFrame
+	lat, lon  float64
+	hasPos    bool
+	posTime   time.Time
+	lastSeen  time.Time
+}
+
+func NewTracker() *Tracker {
+	// Returns a new Tracker
+	return &Tracker{
+		aircraft: make(map[uint32]*trackedAircraft),
+	}
+}
+
+func (t *Tracker) SetReference(lat, lon float64) {
+	// sets the receiver location, required to resolve surface positions when the aircraft
+	// has no previous position
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.refLat = lat
+	t.refLon = lon
+	t.hasRef = true
+}
+
+func (t *Tracker) Decode(frame []byte, now time.Time) (*Message, error) {
+	// decodes frame, resolving the position of position messages where possible
+	msg, err := Decode(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	a, ok := t.aircraft[msg.ICAO]
+	if !ok {
+		a = &trackedAircraft{}
+		t.aircraft[msg.ICAO] = a
+	}
+	a.lastSeen = now
+
+	if msg.Type == MSG_AIRBORNE_POSITION || msg.Type == MSG_SURFACE_POSITION {
+		t.resolvePosition(a, msg, now)
+	}
+
+	t.forget(now)
+
+	return msg, nil
+}
+
+func (t *Tracker) resolvePosition(a *trackedAircraft, msg *Message, now time.Time) {
+	// resolves the position of msg, using global decoding if we have a recent even/odd pair,
+	// otherwise local decoding relative to the last known position
+
+	f := &cprFrame{lat: msg.CPRLat, lon: msg.CPRLon, surface: msg.Surface, received: now}
+	if msg.CPROdd {
+		a.odd = f
+	} else {
+		a.even = f
+	}
+
+	pairWindow := time.Second * CPR_AIRBORNE_PAIR_SECONDS
+	if msg.Surface {
+		pairWindow = time.Second * CPR_SURFACE_PAIR_SECONDS
+	}
+
+	// try local decoding relative to the last position first, it works with a single frame
+	if a.hasPos && now.Sub(a.posTime) < time.Second*CPR_LOCAL_MAX_SECONDS {
+		lat, lon := DecodeCPRLocal(msg.CPRLat, msg.CPRLon, msg.CPROdd, msg.Surface, a.lat, a.lon)
+		t.setPosition(a, msg, lat, lon, now)
+		return
+	}
+
+	// global decoding needs both frames, of the same kind, close together
+	if a.even == nil || a.odd == nil || a.even.surface != a.odd.surface {
+		return
+	}
+	gap := a.even.received.Sub(a.odd.received)
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap > pairWindow {
+		return
+	}
+
+	var (
+		lat, lon float64
+		err      error
+	)
+	if msg.Surface {
+		refLat, refLon, ok := t.surfaceReference(a)
+		if !ok {
+			return
+		}
+		lat, lon, err = DecodeCPRSurfaceGlobal(a.even.lat, a.even.lon, a.odd.lat, a.odd.lon, msg.CPROdd, refLat, refLon)
+	} else {
+		lat, lon, err = DecodeCPRAirborneGlobal(a.even.lat, a.even.lon, a.odd.lat, a.odd.lon, msg.CPROdd)
+	}
+	if err != nil {
+		return
+	}
+	t.setPosition(a, msg, lat, lon, now)
+}
+
+func (t *Tracker) surfaceReference(a *trackedAircraft) (lat, lon float64, ok bool) {
+	// returns the reference position for decoding surface positions
+	if a.hasPos {
+		return a.lat, a.lon, true
+	}
+	if t.hasRef {
+		return t.refLat, t.refLon, true
+	}
+	if t.hasLastAirborne {
+		return t.lastAirborneLat, t.lastAirborneLon, true
+	}
+	return 0, 0, false
+}
+
+func (t *Tracker) setPosition(a *trackedAircraft, msg *Message, lat, lon float64, now time.Time) {
+	// records a resolved position against the aircraft & message
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return
+	}
+	a.lat = lat
+	a.lon = lon
+	a.hasPos = true
+	a.posTime = now
+	msg.Lat = lat
+	msg.Lon = lon
+	msg.HasPosition = true
+	if !msg.Surface {
+		t.lastAirborneLat = lat
+		t.lastAirborneLon = lon
+		t.hasLastAirborne = true
+	}
+}
+
+func (t *Tracker) forget(now time.Time) {
+	// forgets aircraft we haven't heard from, at most once per second
+	if now.Sub(t.lastForget) < time.Second {
+		return
+	}
+	t.lastForget = now
+	for icao, a := range t.aircraft {
+		if now.Sub(a.lastSeen) > time.Second*TRACKER_FORGET_SECONDS {
+			delete(t.aircraft, icao)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package modes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+
+	now := time.Now()
+
+	t.Run("Test airborne global then local decoding", func(t *testing.T) {
+		tr := NewTracker()
+
+		// a single frame can't be resolved
+		m, err := tr.Decode(mustHex(t, "8D40621D58C386435CC412692AD6"), now)
+		require.NoError(t, err)
+		assert.False(t, m.HasPosition)
+
+		// even/odd pair
+		m, err = tr.Decode(mustHex(t, "8D40621D58C382D690C8AC2863A7"), now.Add(time.Second))
+		require.NoError(t, err)
+		require.True(t, m.HasPosition)
+		assert.InDelta(t, 52.25720, m.Lat, 0.0001)
+		assert.InDelta(t, 3.91937, m.Lon, 0.0001)
+		assert.Equal(t, 38000, m.Altitude)
+
+		// subsequent frames resolve on their own
+		m, err = tr.Decode(mustHex(t, "8D40621D58C386435CC412692AD6"), now.Add(time.Second*2))
+		require.NoError(t, err)
+		require.True(t, m.HasPosition)
+		assert.InDelta(t, 52.26578, m.Lat, 0.0001)
+		assert.InDelta(t, 3.93891, m.Lon, 0.0001)
+	})
+
+	t.Run("Test stale pair is not decoded", func(t *testing.T) {
+		tr := NewTracker()
+		_, err := tr.Decode(mustHex(t, "8D40621D58C386435CC412692AD6"), now)
+		require.NoError(t, err)
+		m, err := tr.Decode(mustHex(t, "8D40621D58C382D690C8AC2863A7"), now.Add(time.Second*(CPR_AIRBORNE_PAIR_SECONDS+1)))
+		require.NoError(t, err)
+		assert.False(t, m.HasPosition)
+	})
+
+	t.Run("Test surface decoding needs a reference", func(t *testing.T) {
+		tr := NewTracker()
+		_, err := tr.Decode(mustHex(t, "8C4841753A8A35323FAEBDAC702D"), now)
+		require.NoError(t, err)
+		m, err := tr.Decode(mustHex(t, "8C4841753AAB238733C8CD4020B1"), now.Add(time.Second))
+		require.NoError(t, err)
+		assert.False(t, m.HasPosition)
+
+		tr.SetReference(51.990, 4.375)
+		m, err = tr.Decode(mustHex(t, "8C4841753AAB238733C8CD4020B1"), now.Add(time.Second*2))
+		require.NoError(t, err)
+		require.True(t, m.HasPosition)
+		assert.InDelta(t, 52.32304, m.Lat, 0.0001)
+		assert.InDelta(t, 4.73047, m.Lon, 0.0001)
+	})
+
+	t.Run("Test surface decoding uses nearby airborne aircraft as the reference", func(t *testing.T) {
+		tr := NewTracker()
+		_, err := tr.Decode(mustHex(t, "8D40621D58C386435CC412692AD6"), now)
+		require.NoError(t, err)
+		_, err = tr.Decode(mustHex(t, "8D40621D58C382D690C8AC2863A7"), now.Add(time.Second))
+		require.NoError(t, err)
+
+		_, err = tr.Decode(mustHex(t, "8C4841753A8A35323FAEBDAC702D"), now)
+		require.NoError(t, err)
+		m, err := tr.Decode(mustHex(t, "8C4841753AAB238733C8CD4020B1"), now.Add(time.Second))
+		require.NoError(t, err)
+		require.True(t, m.HasPosition)
+		assert.InDelta(t, 52.32304, m.Lat, 0.0001)
+		assert.InDelta(t, 4.73047, m.Lon, 0.0001)
+	})
+
+	t.Run("Test decode error", func(t *testing.T) {
+		tr := NewTracker()
+		_, err := tr.Decode(mustHex(t, "5D4840D6C0A21B"), now)
+		assert.Error(t, err)
+	})
+}
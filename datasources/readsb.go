@@ -1,13 +1,19 @@
 package datasources
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"path"
 	"pw_slippymap/datasources/readsb_protobuf"
+	"pw_slippymap/datasources/recording"
+	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -16,141 +22,571 @@ import (
 const (
 	ERROR_SECONDS_BACKOFF   = 5
 	READSB_UPDATE_FREQUENCY = 500 // milliseconds
+	READSB_HTTP_TIMEOUT     = 10  // seconds
 )
 
-func readsbProtobufHistory(readsburl string, adb *AircraftDB) {
-	// Updates the AircraftDB adb from history.pb located at readsburl/data/history.pb
+// ReadsbSource is a DataSource that polls the web interface of readsb (or dump1090-fa, tar1090 etc)
+type ReadsbSource struct {
+	*dataSourceRunner
 
-	i := 0
-	finished := false
-	for finished == false {
+	readsburl  string
+	adb        *AircraftDB
+	httpClient *http.Client
 
-		// build the URL to data/history_i.pb
-		urlHistoryPb, err := url.Parse(readsburl)
+	logPrefix string                                                      // prefix for log messages
+	fileExt   string                                                      // file extension of the data files (eg: "pb" for aircraft.pb)
+	decode    func(data []byte) (*readsb_protobuf.AircraftsUpdate, error) // decodes a data file
+
+	recorder *recording.Recorder // if set, every update received is recorded
+}
+
+// compile-time check that ReadsbSource is a DataSource
+var _ DataSource = &ReadsbSource{}
+
+func NewReadsbProtobufSource(readsburl string, adb *AircraftDB) *ReadsbSource {
+	// Returns a DataSource that updates the AircraftDB adb from the readsb-protobuf web interface at readsburl
+	src := &ReadsbSource{
+		readsburl:  readsburl,
+		adb:        adb,
+		httpClient: &http.Client{Timeout: time.Second * READSB_HTTP_TIMEOUT},
+		logPrefix:  "datasources.ReadsbProtobuf",
+		fileExt:    "pb",
+		decode:     decodeReadsbProtobuf,
+	}
+	src.dataSourceRunner = newDataSourceRunner(fmt.Sprintf("readsb-protobuf %s", readsburl), src.run)
+	return src
+}
+
+func NewReadsbJSONSource(readsburl string, adb *AircraftDB) *ReadsbSource {
+	// Returns a DataSource that updates the AircraftDB adb from the aircraft.json served by readsb/dump1090 at readsburl
+	src := &ReadsbSource{
+		readsburl:  readsburl,
+		adb:        adb,
+		httpClient: &http.Client{Timeout: time.Second * READSB_HTTP_TIMEOUT},
+		logPrefix:  "datasources.ReadsbJSON",
+		fileExt:    "json",
+		decode:     decodeReadsbJSON,
+	}
+	src.dataSourceRunner = newDataSourceRunner(fmt.Sprintf("readsb-json %s", readsburl), src.run)
+	return src
+}
+
+func readsbDataURL(readsburl, dataPath string) (string, error) {
+	// returns the URL to dataPath (eg: "data/aircraft.pb") under readsburl
+	u, err := url.Parse(readsburl)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, dataPath)
+	return u.String(), nil
+}
+
+func httpGetBody(ctx context.Context, client *http.Client, u string) (body []byte, statusCode int, err error) {
+	// GETs u, returning the response body and HTTP status code
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+func decodeReadsbProtobuf(pbData []byte) (*readsb_protobuf.AircraftsUpdate, error) {
+	// unmarshalls a readsb-protobuf aircraft.pb or history_N.pb
+	aircraftUpdate := &readsb_protobuf.AircraftsUpdate{}
+	err := proto.Unmarshal(pbData, aircraftUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling protobuf data: %w", err)
+	}
+	return aircraftUpdate, nil
+}
+
+func (src *ReadsbSource) fetch(ctx context.Context, dataPath string) (*readsb_protobuf.AircraftsUpdate, int, error) {
+	// downloads & decodes dataPath (eg: "data/aircraft.pb") from readsb
+
+	u, err := readsbDataURL(src.readsburl, dataPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, statusCode, err := httpGetBody(ctx, src.httpClient, u)
+	if err != nil {
+		return nil, statusCode, fmt.Errorf("HTTP error: %w", err)
+	}
+	if statusCode != http.StatusOK {
+		return nil, statusCode, fmt.Errorf("HTTP status was %d, expected %d", statusCode, http.StatusOK)
+	}
+
+	aircraftUpdate, err := src.decode(data)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return aircraftUpdate, statusCode, nil
+}
+
+func (src *ReadsbSource) loadHistory(ctx context.Context) {
+	// Updates the AircraftDB from history_N.pb/json located at readsburl/data/, stopping at the first missing file
+
+	for i := 0; ctx.Err() == nil; i++ {
+		historyFile := fmt.Sprintf("history_%d.%s", i, src.fileExt)
+		aircraftUpdate, statusCode, err := src.fetch(ctx, path.Join("data", historyFile))
+		if statusCode == http.StatusNotFound {
+			return
+		}
 		if err != nil {
-			log.Fatal(err)
+			log.Printf("%s: Error reading %s: %s", src.logPrefix, historyFile, err)
+			return
 		}
-		historyPath := fmt.Sprintf("data/history_%d.pb", i)
-		urlHistoryPb.Path = path.Join(urlHistoryPb.Path, historyPath)
 
-		// Get the data
-		resp, err := http.Get(urlHistoryPb.String())
+		// Add history
+		// history entries have no time of their own, so use the time of the history file
+		historyTime := time.Now()
+		if aircraftUpdate.GetNow() != 0 {
+			historyTime = time.Unix(int64(aircraftUpdate.GetNow()), 0)
+		}
+		for _, v := range aircraftUpdate.GetHistory() {
+			src.adb.AddHistory(int(v.Addr), AircraftHistoryLocation{
+				Time: historyTime,
+				Lat:  v.Lat,
+				Long: v.Lon,
+				Alt:  int(v.AltBaro),
+			})
+		}
+	}
+}
+
+func (src *ReadsbSource) RecordTo(rec *recording.Recorder) {
+	// records every AircraftsUpdate received to rec, must be called before Start
+	src.recorder = rec
+}
+
+func (src *ReadsbSource) run(ctx context.Context) {
+	// Updates the AircraftDB from aircraft.pb/json located at readsburl/data/ until ctx is cancelled
+
+	// readsb history
+	log.Printf("%s: Reading history", src.logPrefix)
+	src.loadHistory(ctx)
+
+	log.Printf("%s: Reading live data", src.logPrefix)
+	for {
 
-		// If we have any error downloading the history, then bail
+		aircraftUpdate, _, err := src.fetch(ctx, path.Join("data", "aircraft."+src.fileExt))
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
-			break
+			log.Printf("%s: %s", src.logPrefix, err)
+			src.markFailed(err)
+			if !sleepContext(ctx, time.Second*ERROR_SECONDS_BACKOFF) {
+				return
+			}
+			continue
 		}
 
-		// Close body at end
-		defer resp.Body.Close()
-
-		// Check server response
-		switch resp.StatusCode {
-		case http.StatusOK:
-			// pass
-		case http.StatusNotFound:
-			finished = true
-		default:
-			log.Fatal(fmt.Sprintf("datasources.ReadsbProtobufHistory: HTTP status was %d, expected %d", resp.StatusCode, http.StatusOK))
+		// Record update
+		if src.recorder != nil {
+			if err := src.recorder.Write(time.Now(), aircraftUpdate); err != nil {
+				log.Printf("%s: Error recording update: %s", src.logPrefix, err)
+			}
 		}
 
-		// bail if finished
-		if finished {
-			break
+		// Update aircraft DB
+		updateFromReadsbProtobuf(src.adb, aircraftUpdate, src.Name())
+		src.markUpdated()
+
+		// Wait until next update
+		if !sleepContext(ctx, time.Millisecond*READSB_UPDATE_FREQUENCY) {
+			return
 		}
+	}
+}
 
-		// Read response body
-		pbData, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Println("datasources.ReadsbProtobufHistory: Error reading HTTP response body: ", err)
-			time.Sleep(time.Second * ERROR_SECONDS_BACKOFF)
-			continue
+func updateFromReadsbProtobuf(adb *AircraftDB, aircraftUpdate *readsb_protobuf.AircraftsUpdate, source string) {
+	// Updates the AircraftDB adb with the aircraft in aircraftUpdate, received from the data source named source
+	now := time.Unix(int64(aircraftUpdate.GetNow()), 0)
+	for _, a := range aircraftUpdate.GetAircraft() {
+		icao := int(a.GetAddr())
+
+		// when readsb last heard from the aircraft, and when it last got a position
+		fs := FieldSource{Source: source, Time: now}
+		if a.GetSeen() != 0 {
+			fs.Time = time.UnixMilli(int64(a.GetSeen()))
 		}
+		posFs := FieldSource{Source: source, Time: now.Add(-time.Second * time.Duration(a.GetSeenPos()))}
 
-		// Attempt to unmarshall
-		aircraftUpdate := &readsb_protobuf.AircraftsUpdate{}
-		err = proto.Unmarshal(pbData, aircraftUpdate)
-		if err != nil {
-			log.Println("datasources.ReadsbProtobufHistory: Error unmarshalling protobuf data: ", err)
-			time.Sleep(time.Second & ERROR_SECONDS_BACKOFF)
-			continue
+		if a.GetFlight() != "" {
+			adb.SetCallsign(icao, a.GetFlight(), fs)
+		}
+		// track & speed first, so they are recorded in the position history
+		// only when this receiver has them, so a receiver that only hears Mode S doesn't overwrite another's
+		if readsbHasField(a, a.GetValidSource().GetTrack(), a.GetTrack() != 0) {
+			adb.SetTrack(icao, int(a.GetTrack()), fs)
+		}
+		if readsbHasField(a, a.GetValidSource().GetGs(), a.GetGs() != 0) {
+			adb.SetGs(icao, int(a.GetGs()), fs)
+		}
+		if a.GetLat() != 0 || a.GetLon() != 0 {
+			adb.SetPosition(icao, a.GetLat(), a.GetLon(), int(a.GetAltBaro()), posFs)
 		}
+		if a.GetCategory() != 0 {
+			adb.SetCategory(icao, int(a.GetCategory()), fs)
+		}
+		if a.GetAirGround() != readsb_protobuf.AircraftMeta_AG_INVALID {
+			adb.SetAirGround(icao, a.GetAirGround(), fs)
+		}
+		if a.GetSquawk() != 0 {
+			adb.SetSquawk(icao, int(a.GetSquawk()), fs)
+		}
+		adb.MergeMeta(icao, aircraftMetaFromProtobuf(a), readsbMetaFields(a), fs)
+		adb.SetLastSeen(icao, fs)
+	}
+}
 
-		// Add history
-		for _, v := range aircraftUpdate.GetHistory() {
-			adb.AddHistory(int(v.Addr), v.Lat, v.Lon, int(v.AltBaro))
+func readsbHasField(a *readsb_protobuf.AircraftMeta, validSource uint32, nonZero bool) bool {
+	// returns whether readsb has a value for a field of a, from the field's valid_source if readsb sent them,
+	// otherwise from whether the value is non-zero (eg: aircraft.json omits fields it doesn't have)
+	if a.GetValidSource() != nil {
+		return validSource != 0
+	}
+	return nonZero
+}
+
+func readsbMetaFields(a *readsb_protobuf.AircraftMeta) (fields MetaFields) {
+	// returns the fields of aircraftMetaFromProtobuf(a) that readsb has values for
+	vs := a.GetValidSource()
+	has := func(field MetaFields, validSource uint32, nonZero bool) {
+		if readsbHasField(a, validSource, nonZero) {
+			fields |= field
 		}
+	}
+	has(META_ALT_GEOM, vs.GetAltGeom(), a.GetAltGeom() != 0)
+	has(META_BARO_RATE, vs.GetBaroRate(), a.GetBaroRate() != 0)
+	has(META_GEOM_RATE, vs.GetGeomRate(), a.GetGeomRate() != 0)
+	has(META_IAS, vs.GetIas(), a.GetIas() != 0)
+	has(META_TAS, vs.GetTas(), a.GetTas() != 0)
+	has(META_MACH, vs.GetMach(), a.GetMach() != 0)
+	has(META_MAG_HEADING, vs.GetMagHeading(), a.GetMagHeading() != 0)
+	has(META_TRUE_HEADING, vs.GetTrueHeading(), a.GetTrueHeading() != 0)
+	has(META_TRACK_RATE, vs.GetTrackRate(), a.GetTrackRate() != 0)
+	has(META_ROLL, vs.GetRoll(), a.GetRoll() != 0)
+	has(META_NAV_QNH, vs.GetNavQnh(), a.GetNavQnh() != 0)
+	has(META_NAV_ALTITUDE_MCP, vs.GetNavAltitudeMcp(), a.GetNavAltitudeMcp() != 0)
+	has(META_NAV_ALTITUDE_FMS, vs.GetNavAltitudeFms(), a.GetNavAltitudeFms() != 0)
+	has(META_NAV_HEADING, vs.GetNavHeading(), a.GetNavHeading() != 0)
+	has(META_NAV_MODES, vs.GetNavModes(), a.GetNavModes() != nil)
+	has(META_NIC, vs.GetNic(), a.GetNic() != 0)
+	has(META_RC, vs.GetRc(), a.GetRc() != 0)
+	has(META_NIC_BARO, vs.GetNicBaro(), a.GetNicBaro() != 0)
+	has(META_NAC_P, vs.GetNacP(), a.GetNacP() != 0)
+	has(META_NAC_V, vs.GetNacV(), a.GetNacV() != 0)
+	has(META_SIL, vs.GetSil(), a.GetSil() != 0)
+	has(META_SIL_TYPE, vs.GetSilType(), a.GetSilType() != readsb_protobuf.AircraftMeta_SIL_INVALID)
+	has(META_WIND_SPEED|META_WIND_DIRECTION, vs.GetWind(), a.GetWindSpeed() != 0)
+
+	// aircraft.json doesn't say when an emergency has ended, but only receivers hearing ADS-B have the version
+	has(META_EMERGENCY, vs.GetEmergency(), a.GetEmergency() != readsb_protobuf.AircraftMeta_EMERGENCY_NONE || a.GetVersion() != 0)
 
-		// Increment index
-		i += 1
+	// the flight status bits come in the same surveillance replies as the squawk
+	has(META_ALERT|META_SPI, vs.GetSquawk(), a.GetSquawk() != 0)
+
+	// readsb has no valid_source for these, & the signal ones are always this receiver's
+	if a.GetVersion() != 0 {
+		fields |= META_VERSION
 	}
+	return fields | META_RSSI | META_DISTANCE | META_MESSAGES
 }
 
-func ReadsbProtobufAircraft(readsburl string, adb *AircraftDB) {
-	// Updates the AircraftDB adb from aircraft.pb located at readsburl/data/aircraft.pb
+func aircraftMetaFromProtobuf(a *readsb_protobuf.AircraftMeta) AircraftMeta {
+	// returns the fields of a that Aircraft doesn't otherwise hold
+	nm := a.GetNavModes()
+	return AircraftMeta{
+		AltGeom:        int(a.GetAltGeom()),
+		BaroRate:       int(a.GetBaroRate()),
+		GeomRate:       int(a.GetGeomRate()),
+		Ias:            int(a.GetIas()),
+		Tas:            int(a.GetTas()),
+		Mach:           a.GetMach(),
+		MagHeading:     int(a.GetMagHeading()),
+		TrueHeading:    int(a.GetTrueHeading()),
+		TrackRate:      a.GetTrackRate(),
+		Roll:           a.GetRoll(),
+		NavQnh:         a.GetNavQnh(),
+		NavAltitudeMcp: int(a.GetNavAltitudeMcp()),
+		NavAltitudeFms: int(a.GetNavAltitudeFms()),
+		NavHeading:     int(a.GetNavHeading()),
+		NavModes: NavModes{
+			Autopilot: nm.GetAutopilot(),
+			Vnav:      nm.GetVnav(),
+			Althold:   nm.GetAlthold(),
+			Approach:  nm.GetApproach(),
+			Lnav:      nm.GetLnav(),
+			Tcas:      nm.GetTcas(),
+		},
+		Emergency:     a.GetEmergency(),
+		Nic:           int(a.GetNic()),
+		NicBaro:       int(a.GetNicBaro()),
+		Rc:            int(a.GetRc()),
+		NacP:          int(a.GetNacP()),
+		NacV:          int(a.GetNacV()),
+		Sil:           int(a.GetSil()),
+		SilType:       a.GetSilType(),
+		Version:       int(a.GetVersion()),
+		Rssi:          a.GetRssi(),
+		Distance:      int(a.GetDistance()),
+		Messages:      a.GetMessages(),
+		WindSpeed:     int(a.GetWindSpeed()),
+		WindDirection: int(a.GetWindDirection()),
+		Alert:         a.GetAlert(),
+		Spi:           a.GetSpi(),
+	}
+}
 
-	// readsb history
-	log.Println("Reading readsb-protobuf history")
-	readsbProtobufHistory(readsburl, adb)
+// readsbJSONAircraft is an aircraft entry in readsb/dump1090 aircraft.json (or history_N.json)
+// ref: https://github.com/wiedehopf/readsb/blob/dev/README-json.md
+type readsbJSONAircraft struct {
+	Hex            string          `json:"hex"`
+	Type           string          `json:"type"`
+	Flight         string          `json:"flight"`
+	AltBaro        json.RawMessage `json:"alt_baro"` // either altitude in feet, or "ground"
+	AltGeom        int32           `json:"alt_geom"`
+	Gs             float64         `json:"gs"`
+	Ias            uint32          `json:"ias"`
+	Tas            uint32          `json:"tas"`
+	Mach           float32         `json:"mach"`
+	Track          float64         `json:"track"`
+	TrackRate      float32         `json:"track_rate"`
+	Roll           float32         `json:"roll"`
+	MagHeading     float64         `json:"mag_heading"`
+	TrueHeading    float64         `json:"true_heading"`
+	BaroRate       int32           `json:"baro_rate"`
+	GeomRate       int32           `json:"geom_rate"`
+	Squawk         string          `json:"squawk"`
+	Emergency      string          `json:"emergency"`
+	Category       string          `json:"category"`
+	NavQnh         float32         `json:"nav_qnh"`
+	NavAltitudeMcp int32           `json:"nav_altitude_mcp"`
+	NavAltitudeFms int32           `json:"nav_altitude_fms"`
+	NavHeading     float64         `json:"nav_heading"`
+	NavModes       []string        `json:"nav_modes"`
+	Lat            *float64        `json:"lat"`
+	Lon            *float64        `json:"lon"`
+	Nic            uint32          `json:"nic"`
+	Rc             uint32          `json:"rc"`
+	SeenPos        float64         `json:"seen_pos"`
+	Version        int32           `json:"version"`
+	NicBaro        uint32          `json:"nic_baro"`
+	NacP           uint32          `json:"nac_p"`
+	NacV           uint32          `json:"nac_v"`
+	Sil            uint32          `json:"sil"`
+	SilType        string          `json:"sil_type"`
+	Gva            uint32          `json:"gva"`
+	Sda            uint32          `json:"sda"`
+	Alert          int             `json:"alert"`
+	Spi            int             `json:"spi"`
+	Messages       uint64          `json:"messages"`
+	Seen           float64         `json:"seen"`
+	Rssi           float32         `json:"rssi"`
+	RDst           float64         `json:"r_dst"` // distance from the receiver, nautical miles
+	Ws             uint32          `json:"ws"`
+	Wd             uint32          `json:"wd"`
+}
+
+// readsbJSONUpdate is the top level of readsb/dump1090 aircraft.json (or history_N.json)
+type readsbJSONUpdate struct {
+	Now      float64              `json:"now"` // seconds since Unix epoch
+	Messages uint64               `json:"messages"`
+	Aircraft []readsbJSONAircraft `json:"aircraft"`
+}
+
+var (
+	// maps readsb JSON "emergency" values to protobuf
+	readsbJSONEmergency = map[string]readsb_protobuf.AircraftMeta_Emergency{
+		"none":      readsb_protobuf.AircraftMeta_EMERGENCY_NONE,
+		"general":   readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL,
+		"lifeguard": readsb_protobuf.AircraftMeta_EMERGENCY_LIFEGUARD,
+		"minfuel":   readsb_protobuf.AircraftMeta_EMERGENCY_MINFUEL,
+		"nordo":     readsb_protobuf.AircraftMeta_EMERGENCY_NORDO,
+		"unlawful":  readsb_protobuf.AircraftMeta_EMERGENCY_UNLAWFUL,
+		"downed":    readsb_protobuf.AircraftMeta_EMERGENCY_DOWNED,
+		"reserved":  readsb_protobuf.AircraftMeta_EMERGENCY_RESERVED,
+	}
+
+	// maps readsb JSON "sil_type" values to protobuf
+	readsbJSONSilType = map[string]readsb_protobuf.AircraftMeta_SilType{
+		"unknown":   readsb_protobuf.AircraftMeta_SIL_UNKNOWN,
+		"persample": readsb_protobuf.AircraftMeta_SIL_PER_SAMPLE,
+		"perhour":   readsb_protobuf.AircraftMeta_SIL_PER_HOUR,
+	}
+)
 
-	// build the URL to data/aircraft.pb
-	urlAircraftPb, err := url.Parse(readsburl)
+const (
+	NON_ICAO_ADDRESS_FLAG = 1 << 24 // set on addresses that are not ICAO 24-bit addresses (readsb prefixes these with '~')
+)
+
+func parseReadsbJSONHex(hex string) (uint32, error) {
+	// parses the "hex" field, eg: "7c79ca" or "~2e0a3f"
+	var flag uint32
+	if strings.HasPrefix(hex, "~") {
+		flag = NON_ICAO_ADDRESS_FLAG
+		hex = hex[1:]
+	}
+	addr, err := strconv.ParseUint(hex, 16, 24)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
-	urlAircraftPb.Path = path.Join(urlAircraftPb.Path, "data/aircraft.pb")
+	return uint32(addr) | flag, nil
+}
 
-	log.Println("Reading readsb-protobuf live data")
-	// Infinite loop pulling data from readsb-protobuf
-	for {
+func (a *readsbJSONAircraft) toProtobuf() (*readsb_protobuf.AircraftMeta, error) {
+	// converts an aircraft.json aircraft into the equivalent readsb-protobuf AircraftMeta
 
-		// Get the data
-		resp, err := http.Get(urlAircraftPb.String())
-		if err != nil {
-			log.Println("datasources.ReadsbProtobuf: HTTP error:", err)
-			time.Sleep(time.Second * ERROR_SECONDS_BACKOFF)
-			continue
+	addr, err := parseReadsbJSONHex(a.Hex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q: %w", a.Hex, err)
+	}
+
+	am := &readsb_protobuf.AircraftMeta{
+		Addr:           addr,
+		Flight:         a.Flight,
+		AltGeom:        a.AltGeom,
+		Gs:             uint32(math.Round(a.Gs)),
+		Ias:            a.Ias,
+		Tas:            a.Tas,
+		Mach:           a.Mach,
+		Track:          int32(math.Round(a.Track)),
+		TrackRate:      a.TrackRate,
+		Roll:           a.Roll,
+		MagHeading:     int32(math.Round(a.MagHeading)),
+		TrueHeading:    int32(math.Round(a.TrueHeading)),
+		BaroRate:       a.BaroRate,
+		GeomRate:       a.GeomRate,
+		Emergency:      readsbJSONEmergency[a.Emergency],
+		NavQnh:         a.NavQnh,
+		NavAltitudeMcp: a.NavAltitudeMcp,
+		NavAltitudeFms: a.NavAltitudeFms,
+		NavHeading:     int32(math.Round(a.NavHeading)),
+		Nic:            a.Nic,
+		Rc:             a.Rc,
+		SeenPos:        uint32(a.SeenPos),
+		Version:        a.Version,
+		NicBaro:        a.NicBaro,
+		NacP:           a.NacP,
+		NacV:           a.NacV,
+		Sil:            a.Sil,
+		SilType:        readsbJSONSilType[a.SilType],
+		Gva:            a.Gva,
+		Sda:            a.Sda,
+		Alert:          a.Alert != 0,
+		Spi:            a.Spi != 0,
+		Messages:       a.Messages,
+		Rssi:           a.Rssi,
+		Distance:       uint32(math.Round(a.RDst * 1852)),
+		WindSpeed:      a.Ws,
+		WindDirection:  a.Wd,
+	}
+
+	// position
+	if a.Lat != nil && a.Lon != nil {
+		am.Lat = *a.Lat
+		am.Lon = *a.Lon
+	}
+
+	// altitude, or "ground"
+	if len(a.AltBaro) > 0 {
+		var altBaro int32
+		if string(a.AltBaro) == `"ground"` {
+			am.AirGround = readsb_protobuf.AircraftMeta_AG_GROUND
+		} else if err := json.Unmarshal(a.AltBaro, &altBaro); err == nil {
+			am.AltBaro = altBaro
+			am.AirGround = readsb_protobuf.AircraftMeta_AG_AIRBORNE
 		}
-		defer resp.Body.Close()
+	}
 
-		// Check server response
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("datasources.ReadsbProtobuf: HTTP status was %d, expected %d", resp.StatusCode, http.StatusOK)
-			time.Sleep(time.Second * ERROR_SECONDS_BACKOFF)
-			continue
+	// squawk is 4 octal digits, stored by readsb-protobuf as if they were hex
+	if a.Squawk != "" {
+		squawk, err := strconv.ParseUint(a.Squawk, 16, 16)
+		if err == nil {
+			am.Squawk = uint32(squawk)
 		}
+	}
 
-		// Read response body
-		pbData, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Println("datasources.ReadsbProtobuf: Error reading HTTP response body: ", err)
-			time.Sleep(time.Second * ERROR_SECONDS_BACKOFF)
-			continue
+	// category (eg: "A3")
+	if a.Category != "" {
+		category, err := strconv.ParseUint(a.Category, 16, 8)
+		if err == nil {
+			am.Category = uint32(category)
 		}
+	}
+
+	// nav modes
+	if len(a.NavModes) > 0 {
+		am.NavModes = &readsb_protobuf.AircraftMeta_NavModes{}
+		for _, m := range a.NavModes {
+			switch m {
+			case "autopilot":
+				am.NavModes.Autopilot = true
+			case "vnav":
+				am.NavModes.Vnav = true
+			case "althold":
+				am.NavModes.Althold = true
+			case "approach":
+				am.NavModes.Approach = true
+			case "lnav":
+				am.NavModes.Lnav = true
+			case "tcas":
+				am.NavModes.Tcas = true
+			}
+		}
+	}
+
+	return am, nil
+}
+
+func decodeReadsbJSON(jsonData []byte) (*readsb_protobuf.AircraftsUpdate, error) {
+	// decodes a readsb/dump1090 aircraft.json or history_N.json into the equivalent readsb-protobuf AircraftsUpdate
+	// aircraft with a position are also added to the history
+
+	var update readsbJSONUpdate
+	err := json.Unmarshal(jsonData, &update)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON data: %w", err)
+	}
+
+	aircraftUpdate := &readsb_protobuf.AircraftsUpdate{
+		Now:      uint64(update.Now),
+		Messages: update.Messages,
+	}
 
-		// Attempt to unmarshall
-		aircraftUpdate := &readsb_protobuf.AircraftsUpdate{}
-		err = proto.Unmarshal(pbData, aircraftUpdate)
+	for _, a := range update.Aircraft {
+		am, err := a.toProtobuf()
 		if err != nil {
-			log.Println("datasources.ReadsbProtobuf: Error unmarshalling protobuf data: ", err)
-			time.Sleep(time.Second & ERROR_SECONDS_BACKOFF)
+			log.Printf("datasources.ReadsbJSON: Skipping aircraft: %s", err)
 			continue
 		}
-
-		// Update aircraft DB
-		for _, a := range aircraftUpdate.GetAircraft() {
-			icao := int(a.GetAddr())
-			adb.SetCallsign(icao, a.GetFlight())
-			adb.SetPosition(icao, a.GetLat(), a.GetLon(), int(a.GetAltBaro()))
-			adb.SetTrack(icao, int(a.GetTrack()))
-			adb.SetCategory(icao, int(a.GetCategory()))
-			adb.SetGs(icao, int(a.GetGs()))
-			adb.SetAirGround(icao, a.GetAirGround())
-			adb.SetLastSeen(icao)
+		if update.Now != 0 {
+			// readsb JSON has seconds before now, protobuf has milliseconds since the unix epoch
+			am.Seen = uint64(math.Round((update.Now - a.Seen) * 1000))
 		}
+		aircraftUpdate.Aircraft = append(aircraftUpdate.Aircraft, am)
 
-		// Wait until next update
-		time.Sleep(time.Millisecond * READSB_UPDATE_FREQUENCY)
+		if a.Lat != nil && a.Lon != nil {
+			aircraftUpdate.History = append(aircraftUpdate.History, &readsb_protobuf.AircraftHistory{
+				Addr:    am.Addr,
+				AltBaro: am.AltBaro,
+				Lat:     am.Lat,
+				Lon:     am.Lon,
+			})
+		}
 	}
 
+	return aircraftUpdate, nil
 }
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
 	_ "embed"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/akamensky/argparse"
 )
@@ -18,9 +22,16 @@ var readsbDBVersionJSONBlob []byte
 //go:embed aircrafts.json
 var readsbAircraftsJSONBlob []byte // format: {"icao":["registration","type","flags"],...}
 
+//go:embed types.json
+var readsbTypesJSONBlob []byte // format: {"type":["description","type class, eg: L2J","wake category"],...}
+
+//go:embed operators.json
+var readsbOperatorsJSONBlob []byte // format: {"icao prefix":["name","country","telephony callsign"],...}
+
 type runtimeConfiguration struct {
-	outputFile *string
-	goPackage  *string
+	outputFile     *string
+	goPackage      *string
+	aircraftDBFile *string
 }
 
 func processCommandLine() runtimeConfiguration {
@@ -33,6 +44,7 @@ func processCommandLine() runtimeConfiguration {
 
 	output.outputFile = parser.String("o", "outputfile", &argparse.Options{Required: true, Help: "Output file. Eg: './data.go'"})
 	output.goPackage = parser.String("p", "package", &argparse.Options{Required: true, Help: "Output file. Eg: './data.go'"})
+	output.aircraftDBFile = parser.String("d", "aircraftdbfile", &argparse.Options{Required: true, Help: "Aircraft database output file. Eg: './aircraftdb.gz'"})
 
 	// Parse input
 	err := parser.Parse(os.Args)
@@ -52,6 +64,73 @@ func check(e error) {
 	}
 }
 
+func sortedKeys(m map[string]interface{}) []string {
+	// returns the keys of m in order, so the output doesn't change between runs unless the data does
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func entryStrings(file, k string, v interface{}, n int) []string {
+	// returns the first n strings of a JSON entry in the format: "key":["string","string",...]
+	if reflect.TypeOf(v).Kind() != reflect.Slice {
+		log.Fatalf("%s: JSON data for %s not type of slice", file, k)
+	}
+	vr := reflect.ValueOf(v)
+	if vr.Len() < n {
+		log.Fatalf("%s: JSON data for %s has %d values, expected %d", file, k, vr.Len(), n)
+	}
+	output := make([]string, n)
+	for i := range output {
+		s, ok := vr.Index(i).Interface().(string)
+		if !ok {
+			log.Fatalf("%s: JSON data for %s value %d not type of string", file, k, i)
+		}
+		output[i] = s
+	}
+	return output
+}
+
+func writeAircraftDB(filePath string, version int, aircraftData map[string]interface{}) {
+	// writes the aircraft in aircraftData to a gzipped file, one "ICAO<TAB>registration<TAB>type" line per
+	// aircraft sorted by ICAO, after a "version<TAB>N" line
+
+	// sort by icao
+	icaos := make([]int, 0, len(aircraftData))
+	byICAO := make(map[int][]string, len(aircraftData))
+	for k, v := range aircraftData {
+		icao64, err := strconv.ParseInt(k, 16, 64)
+		check(err)
+		icao := int(icao64)
+		icaos = append(icaos, icao)
+		byICAO[icao] = entryStrings("aircraft.json", k, v, 2)
+	}
+	sort.Ints(icaos)
+
+	f, err := os.Create(filePath)
+	check(err)
+	defer f.Close()
+	gz, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	check(err)
+	w := bufio.NewWriter(gz)
+
+	_, err = fmt.Fprintf(w, "version\t%d\n", version)
+	check(err)
+	clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	for _, icao := range icaos {
+		v := byICAO[icao]
+		_, err = fmt.Fprintf(w, "%06X\t%s\t%s\n", icao, clean.Replace(v[0]), clean.Replace(v[1]))
+		check(err)
+	}
+
+	check(w.Flush())
+	check(gz.Close())
+	log.Printf("Wrote %d aircraft to %s", len(icaos), filePath)
+}
+
 func main() {
 
 	conf := processCommandLine()
@@ -79,57 +158,65 @@ func main() {
 	_, err = f.WriteString(dbVersionTxt)
 	check(err)
 
-	// write readsbAircraft struct
-	_, err = f.WriteString(`type readsbAircraftEntry struct {
-		registration string
-		aircraftType string
-		// flags        int
+	// write aircraft.json as a gzipped aircraft database, loaded at runtime (see ../readsbdb.go)
+	log.Println("Processing aircraft.json")
+	aircraftData := make(map[string]interface{})
+	err = json.Unmarshal(readsbAircraftsJSONBlob, &aircraftData)
+	check(err)
+	writeAircraftDB(*conf.aircraftDBFile, int(dbVersion["version"].(float64)), aircraftData)
+
+	// write readsbTypeEntry struct
+	_, err = f.WriteString(`type readsbTypeEntry struct {
+		description  string
+		typeClass    string
+		wakeCategory string
 	}`)
 	check(err)
 	_, err = f.WriteString("\n\n")
 	check(err)
 
 	// write var
-	_, err = f.WriteString("var readsbAircraft = map[int]readsbAircraftEntry{\n")
+	_, err = f.WriteString("var readsbTypes = map[string]readsbTypeEntry{\n")
 	check(err)
 
-	// read aircraft.json
-	log.Println("Processing aircraft.json")
-	aircraftData := make(map[string]interface{})
-	err = json.Unmarshal(readsbAircraftsJSONBlob, &aircraftData)
+	// read types.json
+	log.Println("Processing types.json")
+	typeData := make(map[string]interface{})
+	err = json.Unmarshal(readsbTypesJSONBlob, &typeData)
 	check(err)
 
-	for k, v := range aircraftData {
-		// get icao
-		icao64, err := strconv.ParseInt(k, 16, 64)
+	for _, k := range sortedKeys(typeData) {
+		v := entryStrings("types.json", k, typeData[k], 3)
+		_, err = f.WriteString(fmt.Sprintf("\t%q: {description: %q, typeClass: %q, wakeCategory: %q},\n", k, v[0], v[1], v[2]))
 		check(err)
+	}
 
-		icao := int(icao64)
-
-		// sanity checks
-		if reflect.TypeOf(v).Kind() != reflect.Slice {
-			log.Fatal("aircraft.json: JSON data not type of slice")
-		}
-
-		// get registration & aircraft type
-		vr := reflect.ValueOf(v)
-		registration := vr.Index(0).Elem().String()
-		aircraftType := vr.Index(1).Elem().String()
+	_, err = f.WriteString("}\n\n")
+	check(err)
 
-		// write contents of var
-		icaoTxt := fmt.Sprintf("\t0x%X: {\n", icao)
-		_, err = f.WriteString(icaoTxt)
-		check(err)
+	// write readsbOperatorEntry struct
+	_, err = f.WriteString(`type readsbOperatorEntry struct {
+		name      string
+		country   string
+		telephony string
+	}`)
+	check(err)
+	_, err = f.WriteString("\n\n")
+	check(err)
 
-		regTxt := fmt.Sprintf("\t\tregistration: \"%s\",\n", registration)
-		_, err = f.WriteString(regTxt)
-		check(err)
+	// write var
+	_, err = f.WriteString("var readsbOperators = map[string]readsbOperatorEntry{\n")
+	check(err)
 
-		actTxt := fmt.Sprintf("\t\taircraftType: \"%s\",\n", aircraftType)
-		_, err = f.WriteString(actTxt)
-		check(err)
+	// read operators.json
+	log.Println("Processing operators.json")
+	operatorData := make(map[string]interface{})
+	err = json.Unmarshal(readsbOperatorsJSONBlob, &operatorData)
+	check(err)
 
-		_, err = f.WriteString("\t},\n")
+	for _, k := range sortedKeys(operatorData) {
+		v := entryStrings("operators.json", k, operatorData[k], 3)
+		_, err = f.WriteString(fmt.Sprintf("\t%q: {name: %q, country: %q, telephony: %q},\n", k, v[0], v[1], v[2]))
 		check(err)
 	}
 
@@ -1,19 +1,254 @@
 package datasources
 
 import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"pw_slippymap/datasources/recording"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReadsbProtobuf(t *testing.T) {
 
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
 	assert.IsType(t, int(1), GetReadsbDBVersion())
 
 	adb := NewAircraftDB(2)
 	assert.IsType(t, &AircraftDB{}, adb)
 
-	// TODO: serve test data & test ReadsbProtobuf
-	// ReadsbProtobuf("file://./readsb_protobuf/testdata/aircraft.pb", adb)
+	// serve test data
+	pbData, err := ioutil.ReadFile(filepath.Join("readsb_protobuf", "testdata", "aircraft.pb"))
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/aircraft.pb", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pbData)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	src := NewReadsbProtobufSource(server.URL, adb)
+	assert.Implements(t, (*DataSource)(nil), src)
+	assert.Contains(t, src.Name(), server.URL)
+	assert.Equal(t, DATASOURCE_STOPPED, src.Health().Status)
+
+	t.Run("Test Start", func(t *testing.T) {
+		require.NoError(t, src.Start(context.Background()))
+		require.ErrorIs(t, src.Start(context.Background()), ErrDataSourceRunning)
+		require.Eventually(t, func() bool {
+			return src.Health().Status == DATASOURCE_CONNECTED
+		}, time.Second*5, time.Millisecond*50)
+
+		output := adb.GetAircraft()
+		require.Contains(t, output, 0x7C79CA)
+		assert.Equal(t, "HARR89  ", output[0x7C79CA].Callsign)
+		assert.Equal(t, -32.1251220703125, output[0x7C79CA].Lat)
+		assert.Equal(t, 115.87999877929688, output[0x7C79CA].Long)
+		assert.Equal(t, 1300, output[0x7C79CA].AltBaro)
+		assert.Equal(t, 111, output[0x7C79CA].Track)
+		assert.Equal(t, 0x3000, output[0x7C79CA].Squawk)
+		assert.Equal(t, 975, output[0x7C79CA].Meta.AltGeom)
+		assert.Equal(t, 128, output[0x7C79CA].Meta.GeomRate)
+		assert.Equal(t, 24096, output[0x7C79CA].Meta.NavAltitudeMcp)
+		assert.Equal(t, 25692, output[0x7C79CA].Meta.Distance)
+		assert.Equal(t, 9, output[0x7C79CA].Meta.NacP)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_SIL_PER_HOUR, output[0x7C79CA].Meta.SilType)
+	})
+
+	t.Run("Test Stop", func(t *testing.T) {
+		require.NoError(t, src.Stop())
+		assert.Equal(t, DATASOURCE_STOPPED, src.Health().Status)
+		require.ErrorIs(t, src.Stop(), ErrDataSourceNotRunning)
+	})
+
+	t.Run("Test failure reporting", func(t *testing.T) {
+		failing := NewReadsbProtobufSource(server.URL+"/missing", adb)
+		require.NoError(t, failing.Start(context.Background()))
+		require.Eventually(t, func() bool {
+			return failing.Health().Status == DATASOURCE_FAILED
+		}, time.Second*5, time.Millisecond*50)
+		assert.Error(t, failing.Health().LastError)
+		require.NoError(t, failing.Stop())
+	})
+}
+
+func TestReadsbRecordTo(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	// serve test data
+	server := httptest.NewServer(http.FileServer(http.Dir(filepath.Join("testdata", "readsb_json"))))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "test.pwrec")
+	rec, err := recording.Create(path)
+	require.NoError(t, err)
+
+	src := NewReadsbJSONSource(server.URL, NewAircraftDB(2))
+	src.RecordTo(rec)
+	require.NoError(t, src.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return src.Health().Updates >= 2
+	}, time.Second*5, time.Millisecond*50)
+	require.NoError(t, src.Stop())
+	require.NoError(t, rec.Close())
+
+	records, err := recording.ReadFile(path)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(records), 2)
+	assert.Equal(t, uint64(1650938678), records[0].Update.GetNow())
+	assert.Len(t, records[0].Update.GetAircraft(), 3)
+	assert.False(t, records[1].Time.Before(records[0].Time))
+}
+
+func TestUpdateFromReadsbProtobuf(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	now := time.Now().Truncate(time.Second)
+	update := func(source string, at time.Time, a *readsb_protobuf.AircraftMeta) {
+		a.Addr = 0x7C79CA
+		a.Seen = uint64(at.UnixMilli())
+		updateFromReadsbProtobuf(adb, &readsb_protobuf.AircraftsUpdate{Now: uint64(at.Unix()), Aircraft: []*readsb_protobuf.AircraftMeta{a}}, source)
+	}
+
+	// rx1 has velocity, rx2 (newer) only hears Mode S
+	update("rx1", now, &readsb_protobuf.AircraftMeta{Track: 90, Gs: 300, AirGround: readsb_protobuf.AircraftMeta_AG_AIRBORNE})
+	update("rx2", now.Add(time.Second), &readsb_protobuf.AircraftMeta{AltBaro: 10000, Squawk: 0x1200})
+
+	t.Run("Test source without velocity doesn't overwrite", func(t *testing.T) {
+		a := adb.GetAircraft()[0x7C79CA]
+		assert.Equal(t, 90, a.Track)
+		assert.Equal(t, 300, a.GroundSpeed)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_AIRBORNE, a.AirGround)
+		assert.Equal(t, "rx1", a.TrackSource.Source)
+		assert.Equal(t, 0x1200, a.Squawk)
+		assert.Equal(t, []string{"rx1", "rx2"}, a.Receivers)
+	})
+
+	t.Run("Test valid source", func(t *testing.T) {
+		// a track of 0 is due north when readsb says the track is valid
+		update("rx2", now.Add(time.Second*2), &readsb_protobuf.AircraftMeta{
+			Track:       0,
+			ValidSource: &readsb_protobuf.AircraftMeta_ValidSource{Track: 1},
+		})
+		a := adb.GetAircraft()[0x7C79CA]
+		assert.Equal(t, 0, a.Track)
+		assert.Equal(t, "rx2", a.TrackSource.Source)
+		assert.Equal(t, 300, a.GroundSpeed)
+	})
+}
+
+func TestReadsbJSON(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	adb := NewAircraftDB(2)
+
+	// serve test data
+	server := httptest.NewServer(http.FileServer(http.Dir(filepath.Join("testdata", "readsb_json"))))
+	defer server.Close()
+
+	src := NewReadsbJSONSource(server.URL, adb)
+	assert.Implements(t, (*DataSource)(nil), src)
+	assert.Contains(t, src.Name(), server.URL)
+
+	require.NoError(t, src.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return src.Health().Status == DATASOURCE_CONNECTED
+	}, time.Second*5, time.Millisecond*50)
+	require.NoError(t, src.Stop())
+
+	output := adb.GetAircraft()
+
+	t.Run("Test airborne aircraft", func(t *testing.T) {
+		require.Contains(t, output, 0x7C79CA)
+		a := output[0x7C79CA]
+		assert.Equal(t, "HARR89  ", a.Callsign)
+		assert.Equal(t, -32.125122, a.Lat)
+		assert.Equal(t, 115.879999, a.Long)
+		assert.Equal(t, 1300, a.AltBaro)
+		assert.Equal(t, 111, a.Track)
+		assert.Equal(t, 104, a.GroundSpeed)
+		assert.Equal(t, 0xA1, a.Category)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_AIRBORNE, a.AirGround)
+		assert.Equal(t, 0x3000, a.Squawk)
+		assert.Equal(t, 975, a.Meta.AltGeom)
+		assert.Equal(t, -64, a.Meta.BaroRate)
+		assert.Equal(t, 24096, a.Meta.NavAltitudeMcp)
+		assert.Equal(t, 3, a.Meta.Sil)
+		assert.Equal(t, float32(-24.7), a.Meta.Rssi)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_NONE, a.Meta.Emergency)
+	})
+
+	t.Run("Test aircraft on ground", func(t *testing.T) {
+		require.Contains(t, output, 0x7C6DD8)
+		a := output[0x7C6DD8]
+		assert.Equal(t, "QFA793  ", a.Callsign)
+		assert.Equal(t, 0, a.AltBaro)
+		assert.Equal(t, 0xA5, a.Category)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_GROUND, a.AirGround)
+	})
+
+	t.Run("Test non-ICAO address", func(t *testing.T) {
+		assert.Contains(t, output, 0x2E0A3F|NON_ICAO_ADDRESS_FLAG)
+		assert.NotContains(t, output, 0x2E0A3F)
+	})
+
+	t.Run("Test history", func(t *testing.T) {
+		adb.Mutex.Lock()
+		defer adb.Mutex.Unlock()
+		require.GreaterOrEqual(t, len(adb.Aircraft[0x7C79CA].History), 2)
+		assert.Equal(t, -32.115122, adb.Aircraft[0x7C79CA].History[0].Lat)
+		assert.Equal(t, 1250, adb.Aircraft[0x7C79CA].History[1].Alt)
+		assert.False(t, adb.Aircraft[0x7C79CA].History[0].Time.IsZero())
+		assert.False(t, adb.Aircraft[0x7C79CA].History[1].Time.Before(adb.Aircraft[0x7C79CA].History[0].Time))
+	})
+}
+
+func TestDecodeReadsbJSON(t *testing.T) {
+
+	jsonData, err := ioutil.ReadFile(filepath.Join("testdata", "readsb_json", "data", "aircraft.json"))
+	require.NoError(t, err)
+
+	aircraftUpdate, err := decodeReadsbJSON(jsonData)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1650938678), aircraftUpdate.GetNow())
+	assert.Equal(t, uint64(4189), aircraftUpdate.GetMessages())
+	require.Len(t, aircraftUpdate.GetAircraft(), 3)
+	assert.Len(t, aircraftUpdate.GetHistory(), 2)
+
+	a := aircraftUpdate.GetAircraft()[0]
+	assert.Equal(t, uint32(0x3000), a.GetSquawk())
+	assert.Equal(t, int32(975), a.GetAltGeom())
+	assert.Equal(t, int32(-64), a.GetBaroRate())
+	assert.Equal(t, readsb_protobuf.AircraftMeta_SIL_PER_HOUR, a.GetSilType())
+	assert.Nil(t, a.GetNavModes())
+
+	a = aircraftUpdate.GetAircraft()[1]
+	assert.Equal(t, uint32(0x7700), a.GetSquawk())
+	assert.Equal(t, readsb_protobuf.AircraftMeta_EMERGENCY_GENERAL, a.GetEmergency())
+	assert.True(t, a.GetNavModes().GetAutopilot())
+	assert.True(t, a.GetNavModes().GetTcas())
+	assert.False(t, a.GetNavModes().GetVnav())
 
+	_, err = decodeReadsbJSON([]byte("not json"))
+	assert.Error(t, err)
 }
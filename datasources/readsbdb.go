@@ -0,0 +1,267 @@
+package datasources
+
+// The readsb aircraft database (registration & type by ICAO address) is too big to compile in, so it's loaded
+// at runtime from a gzipped file made by readsb_json/readsbjson2gostruct.go. The file is text:
+//
+//	version<TAB>298
+//	7C1465<TAB>VH-VYK<TAB>B738
+//	...
+//
+// with one line per aircraft, sorted by ICAO address.
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"pw_slippymap/localdata"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	READSB_DB_URL              = "https://raw.githubusercontent.com/plane-watch/pw-slippymap/main/datasources/readsb_json/aircraftdb.gz"
+	READSB_DB_FILE             = "aircraftdb.gz" // in $HOME/.plane.watch
+	READSB_DB_DOWNLOAD_TIMEOUT = 300             // seconds
+)
+
+var (
+	ErrReadsbDBFormat = errors.New("not a readsb aircraft database")
+)
+
+// readsbAircraftEntry is an aircraft in the readsb database
+type readsbAircraftEntry struct {
+	registration string
+	aircraftType string
+}
+
+// ReadsbDB is the readsb aircraft database, indexed by ICAO address
+type ReadsbDB struct {
+	version int
+	icaos   []int // sorted
+	entries []readsbAircraftEntry
+}
+
+func ParseReadsbDB(r io.Reader) (*ReadsbDB, error) {
+	// Returns the readsb aircraft database read from the gzipped file r
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrReadsbDBFormat, err)
+	}
+	defer gz.Close()
+
+	db := &ReadsbDB{}
+	scanner := bufio.NewScanner(gz)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+
+		// header
+		if line == 1 {
+			if len(fields) != 2 || fields[0] != "version" {
+				return nil, fmt.Errorf("%w: missing version", ErrReadsbDBFormat)
+			}
+			db.version, err = strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid version: %s", ErrReadsbDBFormat, err)
+			}
+			continue
+		}
+
+		// aircraft
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: line %d has %d fields, expected 3", ErrReadsbDBFormat, line, len(fields))
+		}
+		icao, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid ICAO: %s", ErrReadsbDBFormat, line, err)
+		}
+		db.icaos = append(db.icaos, int(icao))
+		db.entries = append(db.entries, readsbAircraftEntry{registration: fields[1], aircraftType: fields[2]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if line == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrReadsbDBFormat)
+	}
+
+	// should already be sorted, but lookups depend on it
+	if !sort.IntsAreSorted(db.icaos) {
+		sort.Sort(db)
+	}
+	return db, nil
+}
+
+// sort.Interface, sorting by ICAO
+func (db *ReadsbDB) Len() int           { return len(db.icaos) }
+func (db *ReadsbDB) Less(i, j int) bool { return db.icaos[i] < db.icaos[j] }
+func (db *ReadsbDB) Swap(i, j int) {
+	db.icaos[i], db.icaos[j] = db.icaos[j], db.icaos[i]
+	db.entries[i], db.entries[j] = db.entries[j], db.entries[i]
+}
+
+func (db *ReadsbDB) Version() int {
+	// Returns the version of the readsb database the file was made from
+	return db.version
+}
+
+func (db *ReadsbDB) lookup(icao int) (entry readsbAircraftEntry, ok bool) {
+	// returns the database entry for icao
+	if db == nil {
+		return entry, false
+	}
+	i := sort.SearchInts(db.icaos, icao)
+	if i == len(db.icaos) || db.icaos[i] != icao {
+		return entry, false
+	}
+	return db.entries[i], true
+}
+
+func (db *ReadsbDB) Lookup(icao int) (registration, aircraftType string, ok bool) {
+	// Returns the registration & ICAO type designator (eg: "B738") of the aircraft icao
+	entry, ok := db.lookup(icao)
+	return entry.registration, entry.aircraftType, ok
+}
+
+func LoadReadsbDB(filePath string) (*ReadsbDB, error) {
+	// Returns the readsb aircraft database in the file at filePath
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := ParseReadsbDB(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return db, nil
+}
+
+func fetchReadsbDB(ctx context.Context, u string) (db *ReadsbDB, body []byte, err error) {
+	// returns the readsb aircraft database downloaded from the URL u, and the file as downloaded
+	client := &http.Client{Timeout: time.Second * READSB_DB_DOWNLOAD_TIMEOUT}
+	body, statusCode, err := httpGetBody(ctx, client, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s: HTTP status was %d, expected %d", u, statusCode, http.StatusOK)
+	}
+	db, err = ParseReadsbDB(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", u, err)
+	}
+	return db, body, nil
+}
+
+func FetchReadsbDB(ctx context.Context, u string) (*ReadsbDB, error) {
+	// Returns the readsb aircraft database downloaded from the URL u
+	db, _, err := fetchReadsbDB(ctx, u)
+	return db, err
+}
+
+func downloadReadsbDB(ctx context.Context, u, filePath string) (*ReadsbDB, error) {
+	// downloads the readsb aircraft database from the URL u to filePath, and returns it
+	// the file is only replaced if the download is a valid database
+	db, body, err := fetchReadsbDB(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := filePath + ".tmp"
+	err = os.WriteFile(tmpPath, body, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(tmpPath, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func localReadsbDB(ctx context.Context, filePath, u string) (*ReadsbDB, error) {
+	// returns the readsb aircraft database in the file at filePath, first downloading it from the URL u if
+	// the file is missing or out of date, falling back to the out of date file if the download fails
+	db, err := LoadReadsbDB(filePath)
+	if err == nil && db.Version() < GetReadsbDBVersion() {
+		log.Printf("datasources.ReadsbDB: %s is version %d, expected %d, updating", filePath, db.Version(), GetReadsbDBVersion())
+		err = errors.New("out of date")
+	}
+	if err == nil {
+		return db, nil
+	}
+
+	log.Printf("datasources.ReadsbDB: Downloading %s to %s", u, filePath)
+	downloaded, err := downloadReadsbDB(ctx, u, filePath)
+	if err != nil && db != nil {
+		// out of date is better than nothing
+		log.Printf("datasources.ReadsbDB: Could not update: %s", err)
+		return db, nil
+	}
+	return downloaded, err
+}
+
+func isURL(location string) bool {
+	// returns true if location is a http or https URL
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// If we are running in WASM/JS, the database is fetched over HTTP each time (the browser caches it).
+// If running in desktop app mode, the database is kept in $HOME/.plane.watch, and downloaded if it is
+// missing or older than the compiled-in type & operator tables (see GetReadsbDBVersion).
+// location overrides this, and can be a file path or URL.
+func ReadsbDBForOS(ctx context.Context, location string) (*ReadsbDB, error) {
+	var (
+		db  *ReadsbDB
+		err error
+	)
+
+	switch {
+	case isURL(location):
+		db, err = FetchReadsbDB(ctx, location)
+
+	case location != "":
+		db, err = LoadReadsbDB(location)
+
+	case runtime.GOOS == "js":
+		db, err = FetchReadsbDB(ctx, READSB_DB_URL)
+
+	default:
+		// try to get user home dir
+		var userHomeDir string
+		userHomeDir, err = os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+
+		// create directory structure $HOME/.plane.watch if it doesn't exist
+		pathRoot := path.Join(userHomeDir, ".plane.watch")
+		err = localdata.MakeDirIfNotExist(pathRoot, 0700)
+		if err != nil {
+			return nil, err
+		}
+
+		// use the local copy, unless it is missing or out of date
+		db, err = localReadsbDB(ctx, path.Join(pathRoot, READSB_DB_FILE), READSB_DB_URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if db.Version() != GetReadsbDBVersion() {
+		log.Printf("datasources.ReadsbDB: Aircraft database is version %d, type & operator tables are version %d", db.Version(), GetReadsbDBVersion())
+	}
+	return db, nil
+}
@@ -0,0 +1,192 @@
+package datasources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	// returns data gzipped
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func testReadsbDB(t *testing.T, data string) *ReadsbDB {
+	// returns a readsb aircraft database containing data
+	db, err := ParseReadsbDB(bytes.NewReader(gzipBytes(t, data)))
+	require.NoError(t, err)
+	return db
+}
+
+func TestParseReadsbDB(t *testing.T) {
+
+	t.Run("Test lookup", func(t *testing.T) {
+		db := testReadsbDB(t, "version\t298\n7C0001\tVH-AAA\tA320\n7C1465\tVH-VYK\tB738\n7C6DD8\tVH-EBA\tA332\n")
+		assert.Equal(t, 298, db.Version())
+
+		registration, aircraftType, ok := db.Lookup(0x7C1465)
+		assert.True(t, ok)
+		assert.Equal(t, "VH-VYK", registration)
+		assert.Equal(t, "B738", aircraftType)
+
+		_, _, ok = db.Lookup(0x7C1466)
+		assert.False(t, ok)
+		_, _, ok = db.Lookup(0xFFFFFF)
+		assert.False(t, ok)
+	})
+
+	t.Run("Test unsorted", func(t *testing.T) {
+		db := testReadsbDB(t, "version\t298\n7C6DD8\tVH-EBA\tA332\n7C0001\tVH-AAA\tA320\n")
+		registration, _, ok := db.Lookup(0x7C6DD8)
+		assert.True(t, ok)
+		assert.Equal(t, "VH-EBA", registration)
+	})
+
+	t.Run("Test nil database", func(t *testing.T) {
+		var db *ReadsbDB
+		_, _, ok := db.Lookup(0x7C1465)
+		assert.False(t, ok)
+	})
+
+	t.Run("Test invalid", func(t *testing.T) {
+		for _, data := range []string{
+			"",
+			"7C1465\tVH-VYK\tB738\n",
+			"version\tnew\n",
+			"version\t298\n7C1465\tVH-VYK\n",
+			"version\t298\nnot hex\tVH-VYK\tB738\n",
+		} {
+			_, err := ParseReadsbDB(bytes.NewReader(gzipBytes(t, data)))
+			assert.ErrorIs(t, err, ErrReadsbDBFormat, data)
+		}
+
+		// not gzipped
+		_, err := ParseReadsbDB(bytes.NewReader([]byte("version\t298\n")))
+		assert.ErrorIs(t, err, ErrReadsbDBFormat)
+	})
+}
+
+func TestReadsbDBForOS(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	data := gzipBytes(t, "version\t298\n7C1465\tVH-VYK\tB738\n")
+
+	t.Run("Test file", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), READSB_DB_FILE)
+		require.NoError(t, os.WriteFile(filePath, data, 0600))
+		db, err := ReadsbDBForOS(context.Background(), filePath)
+		require.NoError(t, err)
+		_, _, ok := db.Lookup(0x7C1465)
+		assert.True(t, ok)
+
+		_, err = ReadsbDBForOS(context.Background(), filepath.Join(t.TempDir(), "missing.gz"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Test URL", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/"+READSB_DB_FILE {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		}))
+		defer server.Close()
+
+		db, err := ReadsbDBForOS(context.Background(), server.URL+"/"+READSB_DB_FILE)
+		require.NoError(t, err)
+		assert.Equal(t, 298, db.Version())
+
+		_, err = ReadsbDBForOS(context.Background(), server.URL+"/missing.gz")
+		assert.Error(t, err)
+	})
+
+	t.Run("Test download", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		}))
+		defer server.Close()
+
+		filePath := filepath.Join(t.TempDir(), READSB_DB_FILE)
+		db, err := downloadReadsbDB(context.Background(), server.URL, filePath)
+		require.NoError(t, err)
+		assert.Equal(t, 298, db.Version())
+
+		// saved for next time
+		db, err = LoadReadsbDB(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, 298, db.Version())
+	})
+
+	t.Run("Test local copy", func(t *testing.T) {
+		current := gzipBytes(t, fmt.Sprintf("version\t%d\n7C1465\tVH-VYK\tB738\n", GetReadsbDBVersion()))
+		outOfDate := gzipBytes(t, fmt.Sprintf("version\t%d\n7C1465\tVH-VYK\tB737\n", GetReadsbDBVersion()-1))
+		var downloads int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&downloads, 1)
+			if r.URL.Path != "/"+READSB_DB_FILE {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(current)
+		}))
+		defer server.Close()
+		u := server.URL + "/" + READSB_DB_FILE
+
+		// missing, downloaded
+		filePath := filepath.Join(t.TempDir(), READSB_DB_FILE)
+		db, err := localReadsbDB(context.Background(), filePath, u)
+		require.NoError(t, err)
+		assert.Equal(t, GetReadsbDBVersion(), db.Version())
+		assert.FileExists(t, filePath)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+		// up to date, not downloaded
+		_, err = localReadsbDB(context.Background(), filePath, u)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+		// out of date, downloaded
+		require.NoError(t, os.WriteFile(filePath, outOfDate, 0600))
+		db, err = localReadsbDB(context.Background(), filePath, u)
+		require.NoError(t, err)
+		assert.Equal(t, GetReadsbDBVersion(), db.Version())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&downloads))
+
+		// out of date & the download fails, so the out of date copy is used & kept
+		require.NoError(t, os.WriteFile(filePath, outOfDate, 0600))
+		db, err = localReadsbDB(context.Background(), filePath, server.URL+"/missing.gz")
+		require.NoError(t, err)
+		assert.Equal(t, GetReadsbDBVersion()-1, db.Version())
+		_, aircraftType, ok := db.Lookup(0x7C1465)
+		assert.True(t, ok)
+		assert.Equal(t, "B737", aircraftType)
+		db, err = LoadReadsbDB(filePath)
+		require.NoError(t, err)
+		assert.Equal(t, GetReadsbDBVersion()-1, db.Version())
+
+		// missing & the download fails
+		_, err = localReadsbDB(context.Background(), filepath.Join(t.TempDir(), READSB_DB_FILE), server.URL+"/missing.gz")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,186 @@
+package recording
+
+// Records readsb AircraftsUpdate messages to a file, and reads them back.
+//
+// File format:
+//   - RECORDING_MAGIC
+//   - repeated records of:
+//     - uvarint: time the update was received, milliseconds since the unix epoch
+//     - uvarint: length of the update
+//     - the update, as a serialised readsb_protobuf.AircraftsUpdate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	RECORDING_MAGIC       = "pwrec1\n"
+	MAX_RECORD_SIZE_BYTES = 64 * 1024 * 1024 // sanity check when reading
+)
+
+var (
+	ErrNotRecording   = errors.New("not a recording file")
+	ErrRecordTooLarge = errors.New("record too large")
+)
+
+// Record is a recorded AircraftsUpdate
+type Record struct {
+	Time   time.Time // when the update was received
+	Update *readsb_protobuf.AircraftsUpdate
+}
+
+// Recorder writes AircraftsUpdate messages, safe for concurrent use
+type Recorder struct {
+	w      *bufio.Writer
+	closer io.Closer
+	mutex  sync.Mutex
+}
+
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	// Returns a Recorder that writes to w
+	rec := &Recorder{w: bufio.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		rec.closer = c
+	}
+	if _, err := rec.w.WriteString(RECORDING_MAGIC); err != nil {
+		return nil, err
+	}
+	return rec, rec.w.Flush()
+}
+
+func Create(path string) (*Recorder, error) {
+	// Returns a Recorder that writes to a new file at path
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := NewRecorder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (rec *Recorder) Write(t time.Time, update *readsb_protobuf.AircraftsUpdate) error {
+	// writes update, received at time t
+	data, err := proto.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	var header [2 * binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(t.UnixMilli()))
+	n += binary.PutUvarint(header[n:], uint64(len(data)))
+
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if _, err := rec.w.Write(header[:n]); err != nil {
+		return err
+	}
+	if _, err := rec.w.Write(data); err != nil {
+		return err
+	}
+	// flush each record, so a recording is usable if the app exits uncleanly
+	return rec.w.Flush()
+}
+
+func (rec *Recorder) Close() error {
+	// flushes & closes the underlying writer (if it can be closed)
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if err := rec.w.Flush(); err != nil {
+		return err
+	}
+	if rec.closer != nil {
+		return rec.closer.Close()
+	}
+	return nil
+}
+
+// Reader reads recorded AircraftsUpdate messages
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	// Returns a Reader that reads from r
+	rdr := &Reader{r: bufio.NewReader(r)}
+	magic := make([]byte, len(RECORDING_MAGIC))
+	if _, err := io.ReadFull(rdr.r, magic); err != nil || string(magic) != RECORDING_MAGIC {
+		return nil, ErrNotRecording
+	}
+	return rdr, nil
+}
+
+func (rdr *Reader) Read() (*Record, error) {
+	// returns the next record, or io.EOF at the end of the recording
+	// a truncated final record returns io.ErrUnexpectedEOF
+
+	ms, err := binary.ReadUvarint(rdr.r)
+	if err != nil {
+		return nil, err
+	}
+	size, err := binary.ReadUvarint(rdr.r)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size > MAX_RECORD_SIZE_BYTES {
+		return nil, fmt.Errorf("%w: %d bytes", ErrRecordTooLarge, size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rdr.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	update := &readsb_protobuf.AircraftsUpdate{}
+	if err := proto.Unmarshal(data, update); err != nil {
+		return nil, err
+	}
+	return &Record{Time: time.UnixMilli(int64(ms)), Update: update}, nil
+}
+
+func ReadFile(path string) ([]Record, error) {
+	// returns all records in the recording at path
+	// if the recording was truncated (eg: the app crashed while recording), the complete records are returned
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rdr, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for {
+		r, err := rdr.Read()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, *r)
+	}
+}
@@ -0,0 +1,96 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUpdate(now uint64, icao uint32) *readsb_protobuf.AircraftsUpdate {
+	return &readsb_protobuf.AircraftsUpdate{
+		Now: now,
+		Aircraft: []*readsb_protobuf.AircraftMeta{
+			{Addr: icao, Flight: "TEST01  ", Lat: -31.9523, Lon: 115.8613},
+		},
+	}
+}
+
+func TestRecordAndRead(t *testing.T) {
+
+	t0 := time.UnixMilli(1650938678123)
+
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf)
+	require.NoError(t, err)
+	require.NoError(t, rec.Write(t0, testUpdate(1650938678, 0x7C79CA)))
+	require.NoError(t, rec.Write(t0.Add(time.Millisecond*500), testUpdate(1650938679, 0x7C6DD8)))
+	require.NoError(t, rec.Close())
+
+	t.Run("Test read", func(t *testing.T) {
+		rdr, err := NewReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+
+		r, err := rdr.Read()
+		require.NoError(t, err)
+		assert.True(t, t0.Equal(r.Time))
+		assert.Equal(t, uint64(1650938678), r.Update.GetNow())
+		require.Len(t, r.Update.GetAircraft(), 1)
+		assert.Equal(t, uint32(0x7C79CA), r.Update.GetAircraft()[0].GetAddr())
+		assert.Equal(t, "TEST01  ", r.Update.GetAircraft()[0].GetFlight())
+
+		r, err = rdr.Read()
+		require.NoError(t, err)
+		assert.Equal(t, time.Millisecond*500, r.Time.Sub(t0))
+		assert.Equal(t, uint32(0x7C6DD8), r.Update.GetAircraft()[0].GetAddr())
+
+		_, err = rdr.Read()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("Test truncated", func(t *testing.T) {
+		rdr, err := NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-5]))
+		require.NoError(t, err)
+		_, err = rdr.Read()
+		require.NoError(t, err)
+		_, err = rdr.Read()
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("Test not a recording", func(t *testing.T) {
+		_, err := NewReader(bytes.NewReader([]byte("hello world")))
+		assert.ErrorIs(t, err, ErrNotRecording)
+		_, err = NewReader(bytes.NewReader(nil))
+		assert.ErrorIs(t, err, ErrNotRecording)
+	})
+}
+
+func TestReadFile(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	path := filepath.Join(t.TempDir(), "test.pwrec")
+	rec, err := Create(path)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, rec.Write(time.Now(), testUpdate(uint64(i), 0x7C79CA)))
+	}
+	require.NoError(t, rec.Close())
+
+	records, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, records, 10)
+	assert.Equal(t, uint64(9), records[9].Update.GetNow())
+
+	_, err = ReadFile(filepath.Join(t.TempDir(), "missing.pwrec"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+package datasources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"pw_slippymap/datasources/recording"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	REPLAY_TICK_MILLISECONDS = 50 // how often replay applies due updates
+)
+
+var ErrEmptyRecording = errors.New("recording contains no updates")
+
+// ReplaySource is a DataSource that plays back a recording made by ReadsbSource.RecordTo.
+// Playback speed can be changed, and playback can be paused and seeked while running.
+type ReplaySource struct {
+	*dataSourceRunner
+
+	path    string
+	adb     *AircraftDB
+	records []recording.Record
+
+	// playback state
+	playMutex sync.Mutex
+	position  time.Duration // playback position, relative to the first record
+	speed     float64       // playback speed multiplier
+	paused    bool
+	seeked    bool // position was changed by Seek, the AircraftDB needs rebuilding
+	next      int  // index of the next record to apply
+}
+
+// compile-time check that ReplaySource is a DataSource
+var _ DataSource = &ReplaySource{}
+
+func NewReplaySource(path string, adb *AircraftDB) (*ReplaySource, error) {
+	// Returns a DataSource that plays back the recording at path into the AircraftDB adb, at 1x speed
+	records, err := recording.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrEmptyRecording
+	}
+	src := &ReplaySource{
+		path:    path,
+		adb:     adb,
+		records: records,
+		speed:   1,
+	}
+	src.dataSourceRunner = newDataSourceRunner(fmt.Sprintf("replay %s", path), src.run)
+	return src, nil
+}
+
+func (src *ReplaySource) offset(i int) time.Duration {
+	// returns the time of record i relative to the first record
+	return src.records[i].Time.Sub(src.records[0].Time)
+}
+
+func (src *ReplaySource) StartTime() time.Time {
+	// returns the time the recording started
+	return src.records[0].Time
+}
+
+func (src *ReplaySource) Duration() time.Duration {
+	// returns the length of the recording
+	return src.offset(len(src.records) - 1)
+}
+
+func (src *ReplaySource) Position() time.Duration {
+	// returns the current playback position, relative to the start of the recording
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	return src.position
+}
+
+func (src *ReplaySource) Speed() float64 {
+	// returns the playback speed multiplier
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	return src.speed
+}
+
+func (src *ReplaySource) SetSpeed(speed float64) {
+	// sets the playback speed multiplier, eg: 1, 2, 10
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	if speed > 0 {
+		src.speed = speed
+	}
+}
+
+func (src *ReplaySource) Paused() bool {
+	// returns true if playback is paused
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	return src.paused
+}
+
+func (src *ReplaySource) Pause() {
+	// pauses playback
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	src.paused = true
+}
+
+func (src *ReplaySource) Resume() {
+	// resumes playback, from the start if playback reached the end
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	src.paused = false
+	if src.position >= src.Duration() {
+		src.position = 0
+		src.seeked = true
+	}
+}
+
+func (src *ReplaySource) Seek(position time.Duration) {
+	// moves playback to position, relative to the start of the recording
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	if position < 0 {
+		position = 0
+	}
+	if position > src.Duration() {
+		position = src.Duration()
+	}
+	src.position = position
+	src.seeked = true
+}
+
+func (src *ReplaySource) advance(elapsed time.Duration) (due []recording.Record) {
+	// moves playback forward by elapsed (scaled by the playback speed), returning the records to apply
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+
+	if src.seeked {
+		// each update is a complete snapshot, so rebuild from the last record at or before the new position
+		src.seeked = false
+		src.adb.Clear()
+		src.next = sort.Search(len(src.records), func(i int) bool {
+			return src.offset(i) > src.position
+		})
+		if src.next > 0 {
+			src.next--
+		}
+	}
+
+	if !src.paused {
+		src.position += time.Duration(float64(elapsed) * src.speed)
+		if src.position >= src.Duration() {
+			src.position = src.Duration()
+			src.paused = true
+		}
+	}
+
+	for src.next < len(src.records) && src.offset(src.next) <= src.position {
+		due = append(due, src.records[src.next])
+		src.next++
+	}
+	return due
+}
+
+func (src *ReplaySource) pausedRecord() (r recording.Record, ok bool) {
+	// returns the last record applied, if playback is paused
+	src.playMutex.Lock()
+	defer src.playMutex.Unlock()
+	if !src.paused || src.seeked || src.next == 0 {
+		return r, false
+	}
+	return src.records[src.next-1], true
+}
+
+func (src *ReplaySource) keepAlive(r recording.Record) {
+	// marks the aircraft in r as seen, so they don't time out of the AircraftDB while playback is paused
+	fs := FieldSource{Source: src.Name(), Time: r.Time}
+	for _, a := range r.Update.GetAircraft() {
+		src.adb.SetLastSeen(int(a.GetAddr()), fs)
+	}
+}
+
+func (src *ReplaySource) run(ctx context.Context) {
+	// plays back the recording until ctx is cancelled
+
+	log.Printf("datasources.Replay: Playing %d updates, %s from %s", len(src.records), src.Duration(), src.StartTime().Format(time.RFC3339))
+
+	ticker := time.NewTicker(time.Millisecond * REPLAY_TICK_MILLISECONDS)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		now := time.Now()
+		for _, r := range src.advance(now.Sub(last)) {
+			updateFromReadsbProtobuf(src.adb, r.Update, src.Name())
+			src.markUpdated()
+		}
+		last = now
+
+		// the AircraftDB times aircraft out on the wall clock, so keep the paused moment's aircraft alive
+		if r, ok := src.pausedRecord(); ok {
+			src.keepAlive(r)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
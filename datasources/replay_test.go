@@ -0,0 +1,185 @@
+package datasources
+
+import (
+	"context"
+	"path/filepath"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"pw_slippymap/datasources/recording"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestRecording(t *testing.T) string {
+	// writes a recording of one aircraft moving north, one update per second for 10 seconds
+	path := filepath.Join(t.TempDir(), "test.pwrec")
+	rec, err := recording.Create(path)
+	require.NoError(t, err)
+	t0 := time.UnixMilli(1650938678000)
+	for i := 0; i <= 10; i++ {
+		update := &readsb_protobuf.AircraftsUpdate{
+			Now: uint64(t0.Unix()) + uint64(i),
+			Aircraft: []*readsb_protobuf.AircraftMeta{
+				{Addr: 0x7C79CA, Flight: "HARR89  ", Lat: -32 + float64(i)*0.01, Lon: 115.88, AltBaro: int32(1000 + i*100)},
+			},
+		}
+		require.NoError(t, rec.Write(t0.Add(time.Second*time.Duration(i)), update))
+	}
+	require.NoError(t, rec.Close())
+	return path
+}
+
+func TestReplaySource(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	adb := NewAircraftDB(60)
+	src, err := NewReplaySource(writeTestRecording(t), adb)
+	require.NoError(t, err)
+	assert.Implements(t, (*DataSource)(nil), src)
+	assert.Equal(t, time.Second*10, src.Duration())
+	assert.Equal(t, int64(1650938678), src.StartTime().Unix())
+
+	altitude := func() int {
+		return adb.GetAircraft()[0x7C79CA].AltBaro
+	}
+	apply := func(elapsed time.Duration) int {
+		due := src.advance(elapsed)
+		for _, r := range due {
+			updateFromReadsbProtobuf(adb, r.Update, src.Name())
+		}
+		return len(due)
+	}
+
+	t.Run("Test 1x", func(t *testing.T) {
+		assert.Equal(t, 1, apply(0))
+		assert.Equal(t, 1000, altitude())
+		assert.Equal(t, 0, apply(time.Millisecond*500))
+		assert.Equal(t, 1, apply(time.Millisecond*500))
+		assert.Equal(t, 1100, altitude())
+		assert.Equal(t, time.Second, src.Position())
+	})
+
+	t.Run("Test 2x", func(t *testing.T) {
+		src.SetSpeed(2)
+		assert.Equal(t, 2.0, src.Speed())
+		assert.Equal(t, 2, apply(time.Second))
+		assert.Equal(t, 1300, altitude())
+		assert.Equal(t, time.Second*3, src.Position())
+		src.SetSpeed(0)
+		assert.Equal(t, 2.0, src.Speed())
+		src.SetSpeed(1)
+	})
+
+	t.Run("Test pause", func(t *testing.T) {
+		src.Pause()
+		assert.True(t, src.Paused())
+		assert.Equal(t, 0, apply(time.Second*5))
+		assert.Equal(t, time.Second*3, src.Position())
+		src.Resume()
+		assert.False(t, src.Paused())
+		assert.Equal(t, 1, apply(time.Second))
+		assert.Equal(t, 1400, altitude())
+	})
+
+	t.Run("Test seek backwards", func(t *testing.T) {
+		src.Seek(time.Millisecond * 1500)
+		assert.Equal(t, 1, apply(0))
+		assert.Equal(t, 1100, altitude())
+		assert.Equal(t, time.Millisecond*1500, src.Position())
+	})
+
+	t.Run("Test seek forwards", func(t *testing.T) {
+		src.Seek(time.Second * 8)
+		assert.Equal(t, 1, apply(0))
+		assert.Equal(t, 1800, altitude())
+	})
+
+	t.Run("Test end of recording", func(t *testing.T) {
+		src.SetSpeed(10)
+		assert.Equal(t, 2, apply(time.Second))
+		assert.Equal(t, 2000, altitude())
+		assert.True(t, src.Paused())
+		assert.Equal(t, src.Duration(), src.Position())
+
+		// resuming at the end restarts playback
+		src.Resume()
+		assert.Equal(t, 1, apply(0))
+		assert.Equal(t, 1000, altitude())
+
+		src.Seek(-time.Second)
+		assert.Equal(t, time.Duration(0), src.Position())
+		src.Seek(time.Hour)
+		assert.Equal(t, src.Duration(), src.Position())
+	})
+
+	t.Run("Test run", func(t *testing.T) {
+		src.Seek(0)
+		src.Pause()
+		require.NoError(t, src.Start(context.Background()))
+		require.Eventually(t, func() bool {
+			return src.Health().Status == DATASOURCE_CONNECTED
+		}, time.Second*5, time.Millisecond*50)
+		require.NoError(t, src.Stop())
+		assert.Equal(t, 1000, altitude())
+	})
+}
+
+func TestReplaySourcePaused(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	// aircraft time out after a second
+	adb := NewAircraftDB(1)
+	src, err := NewReplaySource(writeTestRecording(t), adb)
+	require.NoError(t, err)
+	src.Seek(time.Second * 5)
+	src.Pause()
+	require.NoError(t, src.Start(context.Background()))
+	defer src.Stop()
+	require.Eventually(t, func() bool {
+		return src.Health().Status == DATASOURCE_CONNECTED
+	}, time.Second*5, time.Millisecond*50)
+
+	t.Run("Test paused longer than the idle timeout", func(t *testing.T) {
+		time.Sleep(time.Second * 4)
+		require.Contains(t, adb.GetAircraft(), 0x7C79CA)
+		assert.Equal(t, 1500, adb.GetAircraft()[0x7C79CA].AltBaro)
+	})
+
+	t.Run("Test paused at the end of the recording", func(t *testing.T) {
+		src.SetSpeed(10)
+		src.Resume()
+		require.Eventually(t, src.Paused, time.Second*5, time.Millisecond*50)
+		time.Sleep(time.Second * 4)
+		require.Contains(t, adb.GetAircraft(), 0x7C79CA)
+		assert.Equal(t, 2000, adb.GetAircraft()[0x7C79CA].AltBaro)
+	})
+}
+
+func TestReplaySourceErrors(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	_, err := NewReplaySource(filepath.Join(t.TempDir(), "missing.pwrec"), NewAircraftDB(60))
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "empty.pwrec")
+	rec, err := recording.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, rec.Close())
+	_, err = NewReplaySource(path, NewAircraftDB(60))
+	assert.ErrorIs(t, err, ErrEmptyRecording)
+}
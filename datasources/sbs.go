@@ -0,0 +1,264 @@
+package datasources
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SBSSource is a DataSource that reads BaseStation (SBS-1) format messages from TCP (eg: dump1090 port 30003)
+// ref: http://woodair.net/sbs/article/barebones42_socket_data.htm
+type SBSSource struct {
+	*dataSourceRunner
+
+	addr string // host:port to connect to
+	adb  *AircraftDB
+
+	// partial state per aircraft, SBS messages only carry some fields each
+	aircraft map[int]*sbsAircraft
+}
+
+// sbsAircraft holds the last known values for an aircraft, so partial messages can be merged
+type sbsAircraft struct {
+	altitude       int
+	altitudeKnown  bool
+	lastMessageRxd time.Time
+}
+
+// sbsMessage is a parsed SBS message. Fields not present in the message are nil.
+type sbsMessage struct {
+	transmissionType int
+	icao             int
+	callsign         *string
+	altitude         *int
+	groundSpeed      *int
+	track            *int
+	lat              *float64
+	long             *float64
+	verticalRate     *int
+	squawk           *int
+	alert            *bool
+	spi              *bool
+	onGround         *bool
+}
+
+// compile-time check that SBSSource is a DataSource
+var _ DataSource = &SBSSource{}
+
+var errSBSNotMSG = errors.New("not a MSG line")
+
+func NewSBSSource(addr string, adb *AircraftDB) *SBSSource {
+	// Returns a DataSource that updates the AircraftDB adb from BaseStation format messages read from addr (host:port)
+	src := &SBSSource{
+		addr:     addr,
+		adb:      adb,
+		aircraft: make(map[int]*sbsAircraft),
+	}
+	src.dataSourceRunner = newDataSourceRunner(fmt.Sprintf("sbs %s", addr), src.run)
+	return src
+}
+
+func parseSBSMessage(line string) (msg sbsMessage, err error) {
+	// parses a line of BaseStation format data, eg:
+	// MSG,3,1,1,4840D6,1,2008/11/28,23:48:18.611,2008/11/28,23:53:19.161,,37000,,,51.45735,-1.02826,,,0,0,0,0
+
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) < 11 {
+		return msg, fmt.Errorf("expected at least 11 fields, got %d", len(fields))
+	}
+	if fields[0] != "MSG" {
+		return msg, errSBSNotMSG
+	}
+
+	// pad out to 22 fields, some feeders omit trailing empty fields
+	for len(fields) < 22 {
+		fields = append(fields, "")
+	}
+
+	msg.transmissionType, err = strconv.Atoi(fields[1])
+	if err != nil || msg.transmissionType < 1 || msg.transmissionType > 8 {
+		return msg, fmt.Errorf("invalid transmission type: %q", fields[1])
+	}
+
+	icao, err := strconv.ParseUint(strings.TrimPrefix(fields[4], "~"), 16, 24)
+	if err != nil {
+		return msg, fmt.Errorf("invalid hex ident: %q", fields[4])
+	}
+	msg.icao = int(icao)
+	if strings.HasPrefix(fields[4], "~") {
+		msg.icao |= NON_ICAO_ADDRESS_FLAG
+	}
+
+	if fields[10] != "" {
+		callsign := fields[10]
+		msg.callsign = &callsign
+	}
+	msg.altitude = parseSBSInt(fields[11])
+	msg.groundSpeed = parseSBSInt(fields[12])
+	msg.track = parseSBSInt(fields[13])
+	msg.lat = parseSBSFloat(fields[14])
+	msg.long = parseSBSFloat(fields[15])
+	msg.verticalRate = parseSBSInt(fields[16])
+	if fields[17] != "" {
+		// squawk is 4 octal digits, stored as if they were hex (same as readsb-protobuf)
+		squawk, err := strconv.ParseUint(fields[17], 16, 16)
+		if err == nil {
+			s := int(squawk)
+			msg.squawk = &s
+		}
+	}
+	msg.alert = parseSBSBool(fields[18])
+	// fields[19] is the emergency flag, which is only set for squawk 7500, 7600 or 7700, so is already known from the squawk
+	msg.spi = parseSBSBool(fields[20])
+	msg.onGround = parseSBSBool(fields[21])
+
+	return msg, nil
+}
+
+func parseSBSInt(field string) *int {
+	// parses an integer field, some feeders send decimals (eg: ground speed "451.2")
+	if field == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return nil
+	}
+	i := int(f)
+	return &i
+}
+
+func parseSBSFloat(field string) *float64 {
+	// parses a decimal field
+	if field == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+func parseSBSBool(field string) *bool {
+	// parses a flag field, "-1" (or "1") is true, "0" is false
+	if field == "" {
+		return nil
+	}
+	b := field == "-1" || field == "1"
+	return &b
+}
+
+func (src *SBSSource) applyMessage(msg sbsMessage) {
+	// merges msg with what we already know about the aircraft, then updates the AircraftDB
+
+	a, ok := src.aircraft[msg.icao]
+	if !ok {
+		a = &sbsAircraft{}
+		src.aircraft[msg.icao] = a
+	}
+	a.lastMessageRxd = time.Now()
+	fs := FieldSource{Source: src.Name(), Time: a.lastMessageRxd}
+
+	if msg.callsign != nil {
+		src.adb.SetCallsign(msg.icao, *msg.callsign, fs)
+	}
+
+	if msg.altitude != nil {
+		a.altitude = *msg.altitude
+		a.altitudeKnown = true
+	}
+	if msg.groundSpeed != nil {
+		src.adb.SetGs(msg.icao, *msg.groundSpeed, fs)
+	}
+	if msg.track != nil {
+		src.adb.SetTrack(msg.icao, *msg.track, fs)
+	}
+
+	// only when this message has a position, altitude-only messages (eg: MSG,5 & MSG,7) are kept until then,
+	// as re-sending the last position with a newer time would make it look like a new report
+	if msg.lat != nil && msg.long != nil {
+		src.adb.SetPosition(msg.icao, *msg.lat, *msg.long, a.altitude, fs)
+	}
+	if msg.squawk != nil {
+		src.adb.SetSquawk(msg.icao, *msg.squawk, fs)
+	}
+
+	if meta, fields := msg.meta(); fields != 0 {
+		src.adb.MergeMeta(msg.icao, meta, fields, fs)
+	}
+
+	if msg.onGround != nil {
+		if *msg.onGround {
+			src.adb.SetAirGround(msg.icao, readsb_protobuf.AircraftMeta_AG_GROUND, fs)
+		} else if a.altitudeKnown {
+			src.adb.SetAirGround(msg.icao, readsb_protobuf.AircraftMeta_AG_AIRBORNE, fs)
+		}
+	}
+
+	src.adb.SetLastSeen(msg.icao, fs)
+}
+
+func (msg sbsMessage) meta() (meta AircraftMeta, fields MetaFields) {
+	// returns the vertical rate & flight status bits in msg, & which of them it has
+	if msg.verticalRate != nil {
+		meta.BaroRate = *msg.verticalRate
+		fields |= META_BARO_RATE
+	}
+	if msg.alert != nil {
+		meta.Alert = *msg.alert
+		fields |= META_ALERT
+	}
+	if msg.spi != nil {
+		meta.Spi = *msg.spi
+		fields |= META_SPI
+	}
+	return meta, fields
+}
+
+func (src *SBSSource) forgetIdle() {
+	// forgets partial state for aircraft we haven't heard from
+	for icao, a := range src.aircraft {
+		if time.Since(a.lastMessageRxd) > time.Second*FORGET_AIRCRAFT_AFTER_SECONDS {
+			delete(src.aircraft, icao)
+		}
+	}
+}
+
+func (src *SBSSource) readMessages(ctx context.Context, conn net.Conn) (numMessages int, err error) {
+	// reads & applies messages from conn until the connection is closed
+	// returns the number of messages applied
+
+	lastForget := time.Now()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		msg, err := parseSBSMessage(scanner.Text())
+		if err != nil {
+			if err != errSBSNotMSG {
+				log.Printf("datasources.SBS: Skipping line: %s", err)
+			}
+			continue
+		}
+		src.applyMessage(msg)
+		src.markUpdated()
+		numMessages++
+
+		if time.Since(lastForget) > time.Second {
+			src.forgetIdle()
+			lastForget = time.Now()
+		}
+	}
+	return numMessages, scanner.Err()
+}
+
+func (src *SBSSource) run(ctx context.Context) {
+	// connects to the SBS feed, reconnecting with exponential backoff, until ctx is cancelled
+	runTCPStream(ctx, src.dataSourceRunner, "datasources.SBS", src.addr, src.readMessages)
+}
@@ -0,0 +1,199 @@
+package datasources
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSBSMessage(t *testing.T) {
+
+	t.Run("Test airborne position", func(t *testing.T) {
+		msg, err := parseSBSMessage("MSG,3,1,1,4840D6,1,2008/11/28,23:48:18.611,2008/11/28,23:53:19.161,,37000,,,51.45735,-1.02826,,,0,0,0,0")
+		require.NoError(t, err)
+		assert.Equal(t, 3, msg.transmissionType)
+		assert.Equal(t, 0x4840D6, msg.icao)
+		assert.Nil(t, msg.callsign)
+		assert.Equal(t, 37000, *msg.altitude)
+		assert.Equal(t, 51.45735, *msg.lat)
+		assert.Equal(t, -1.02826, *msg.long)
+		assert.Nil(t, msg.groundSpeed)
+		assert.False(t, *msg.onGround)
+	})
+
+	t.Run("Test airborne velocity", func(t *testing.T) {
+		msg, err := parseSBSMessage("MSG,4,1,1,4840D6,1,2008/11/28,23:48:18.611,2008/11/28,23:53:19.161,,,420.7,179,,,-64,,,,,")
+		require.NoError(t, err)
+		assert.Equal(t, 420, *msg.groundSpeed)
+		assert.Equal(t, 179, *msg.track)
+		assert.Equal(t, -64, *msg.verticalRate)
+		assert.Nil(t, msg.onGround)
+	})
+
+	t.Run("Test surveillance ID", func(t *testing.T) {
+		msg, err := parseSBSMessage("MSG,6,1,1,7C6DD8,1,2022/04/26,02:04:38.715,2022/04/26,02:04:38.755,,12000,,,,,,7700,-1,-1,0,0")
+		require.NoError(t, err)
+		assert.Equal(t, 0x7700, *msg.squawk)
+		assert.True(t, *msg.alert)
+		assert.False(t, *msg.spi)
+	})
+
+	t.Run("Test errors", func(t *testing.T) {
+		_, err := parseSBSMessage("STA,,1,1,7C6DD8,1,2022/04/26,02:04:38.716,2022/04/26,02:04:38.756,RM")
+		assert.ErrorIs(t, err, errSBSNotMSG)
+		_, err = parseSBSMessage("MSG,3,1")
+		assert.Error(t, err)
+		_, err = parseSBSMessage("MSG,9,1,1,7C6DD8,1,2022/04/26,02:04:38.716,2022/04/26,02:04:38.756,")
+		assert.Error(t, err)
+		_, err = parseSBSMessage("MSG,3,1,1,ZZZZZZ,1,2022/04/26,02:04:38.716,2022/04/26,02:04:38.756,")
+		assert.Error(t, err)
+	})
+}
+
+func TestSBSSource(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	replay, err := ioutil.ReadFile(filepath.Join("testdata", "sbs", "basestation.txt"))
+	require.NoError(t, err)
+
+	// listen on a local port & replay the captured data to each connection, then disconnect
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	var connections int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&connections, 1)
+			conn.Write(replay)
+			conn.Close()
+		}
+	}()
+
+	adb := NewAircraftDB(60)
+	src := NewSBSSource(listener.Addr().String(), adb)
+	assert.Implements(t, (*DataSource)(nil), src)
+	assert.Contains(t, src.Name(), listener.Addr().String())
+
+	require.NoError(t, src.Start(context.Background()))
+
+	// the server disconnects us after each replay, so the source should reconnect
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&connections) >= 2
+	}, time.Second*10, time.Millisecond*50)
+	require.NoError(t, src.Stop())
+	assert.Positive(t, src.Health().Updates)
+	assert.Positive(t, src.Health().Errors)
+
+	output := adb.GetAircraft()
+
+	t.Run("Test merged airborne aircraft", func(t *testing.T) {
+		require.Contains(t, output, 0x7C6DD8)
+		a := output[0x7C6DD8]
+		assert.Equal(t, "QFA793  ", a.Callsign)
+		assert.Equal(t, -31.93920, a.Lat)
+		assert.Equal(t, 115.96401, a.Long)
+		assert.Equal(t, 11975, a.AltBaro)
+		assert.Equal(t, 312, a.GroundSpeed)
+		assert.Equal(t, 271, a.Track)
+		assert.Equal(t, 0x4632, a.Squawk)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_AIRBORNE, a.AirGround)
+	})
+
+	t.Run("Test surface aircraft", func(t *testing.T) {
+		require.Contains(t, output, 0x7C79CA)
+		a := output[0x7C79CA]
+		assert.Equal(t, -31.94031, a.Lat)
+		assert.Equal(t, 12, a.GroundSpeed)
+		assert.Equal(t, readsb_protobuf.AircraftMeta_AG_GROUND, a.AirGround)
+	})
+
+	t.Run("Test non-ICAO address", func(t *testing.T) {
+		assert.Contains(t, output, 0x2E0A3F|NON_ICAO_ADDRESS_FLAG)
+	})
+}
+
+func TestSBSApplyMessage(t *testing.T) {
+
+	adb := NewAircraftDB(60)
+	src := NewSBSSource("127.0.0.1:30003", adb)
+	apply := func(line string) {
+		msg, err := parseSBSMessage(line)
+		require.NoError(t, err)
+		src.applyMessage(msg)
+	}
+
+	adb.SetMeta(0x7C6DD8, AircraftMeta{NavModes: NavModes{Autopilot: true}}, FieldSource{Source: "readsb", Time: time.Now()})
+	apply("MSG,3,1,1,7C6DD8,1,2022/04/26,02:04:38.713,2022/04/26,02:04:38.753,,12025,,,-31.93890,115.96718,,,0,0,0,0")
+	positionSource := adb.GetAircraft()[0x7C6DD8].PositionSource
+
+	t.Run("Test altitude-only message doesn't re-report the position", func(t *testing.T) {
+		time.Sleep(time.Millisecond * 10)
+		apply("MSG,5,1,1,7C6DD8,1,2022/04/26,02:04:38.714,2022/04/26,02:04:38.754,,12000,,,,,,,0,,0,0")
+		a := adb.GetAircraft()[0x7C6DD8]
+		assert.Equal(t, positionSource, a.PositionSource)
+		assert.Equal(t, 12025, a.AltBaro)
+		assert.Len(t, adb.GetHistory(0x7C6DD8), 1)
+	})
+
+	t.Run("Test altitude is kept for the next position", func(t *testing.T) {
+		apply("MSG,2,1,1,7C6DD8,1,2022/04/26,02:04:38.715,2022/04/26,02:04:38.755,,,,,-31.93920,115.96401,,,0,0,0,0")
+		a := adb.GetAircraft()[0x7C6DD8]
+		assert.Equal(t, -31.93920, a.Lat)
+		assert.Equal(t, 12000, a.AltBaro)
+		assert.Len(t, adb.GetHistory(0x7C6DD8), 2)
+	})
+
+	t.Run("Test vertical rate & flight status", func(t *testing.T) {
+		apply("MSG,4,1,1,7C6DD8,1,2022/04/26,02:04:38.716,2022/04/26,02:04:38.756,,,312,271,,,-64,,,,,")
+		apply("MSG,6,1,1,7C6DD8,1,2022/04/26,02:04:38.717,2022/04/26,02:04:38.757,,12000,,,,,,7700,-1,-1,0,0")
+		a := adb.GetAircraft()[0x7C6DD8]
+		assert.Equal(t, -64, a.Meta.BaroRate)
+		assert.Equal(t, -64, a.Meta.VerticalRate())
+		assert.True(t, a.Meta.Alert)
+		assert.False(t, a.Meta.Spi)
+		assert.True(t, a.Meta.NavModes.Autopilot, "meta from other sources is kept")
+	})
+}
+
+func TestSBSSourceConnectionRefused(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	// get a free port, then close it so nothing is listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	src := NewSBSSource(addr, NewAircraftDB(60))
+	require.NoError(t, src.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return src.Health().Status == DATASOURCE_FAILED
+	}, time.Second*5, time.Millisecond*50)
+	assert.Error(t, src.Health().LastError)
+
+	// stopping should not wait for the backoff to finish
+	stopStart := time.Now()
+	require.NoError(t, src.Stop())
+	assert.Less(t, time.Since(stopStart), time.Second*RECONNECT_BACKOFF_MIN_SECONDS)
+}
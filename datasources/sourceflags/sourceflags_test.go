@@ -0,0 +1,122 @@
+package sourceflags
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"pw_slippymap/datasources"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"pw_slippymap/datasources/recording"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/akamensky/argparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, args ...string) *Flags {
+	// returns the data source options in args
+	parser := argparse.NewParser("test", "")
+	f := Add(parser)
+	require.NoError(t, parser.Parse(append([]string{"test"}, args...)))
+	return f
+}
+
+func TestDataSources(t *testing.T) {
+
+	adb := datasources.NewAircraftDB(60)
+
+	t.Run("Test none", func(t *testing.T) {
+		dataSources, replay, err := parse(t).DataSources(adb, nil)
+		require.NoError(t, err)
+		assert.Empty(t, dataSources)
+		assert.Nil(t, replay)
+	})
+
+	t.Run("Test each kind", func(t *testing.T) {
+		dataSources, replay, err := parse(t,
+			"--aircraftpburl", "http://1.2.3.4/",
+			"--aircraftjsonurl", "http://1.2.3.4/tar1090/",
+			"--sbsaddr", "1.2.3.4:30003",
+			"--beastaddr", "1.2.3.4:30005", "--beastaddr", "5.6.7.8:30005",
+			"--avraddr", "1.2.3.4:30002",
+			"--avrfile", "-",
+			"--messagebusurl", "nats://1.2.3.4:4222",
+		).DataSources(adb, nil)
+		require.NoError(t, err)
+		assert.Nil(t, replay)
+		var names []string
+		for _, ds := range dataSources {
+			names = append(names, ds.Name())
+		}
+		assert.Equal(t, []string{
+			"readsb-protobuf http://1.2.3.4/",
+			"readsb-json http://1.2.3.4/tar1090/",
+			"sbs 1.2.3.4:30003",
+			"beast 1.2.3.4:30005",
+			"beast 5.6.7.8:30005",
+			"avr 1.2.3.4:30002",
+			"avr stdin",
+			"messagebus nats://1.2.3.4:4222",
+		}, names)
+	})
+
+	t.Run("Test replay", func(t *testing.T) {
+
+		// skip tests if webassembly
+		if runtime.GOOS == "js" {
+			t.SkipNow()
+		}
+
+		path := filepath.Join(t.TempDir(), "test.pwrec")
+		rec, err := recording.Create(path)
+		require.NoError(t, err)
+		require.NoError(t, rec.Write(time.Now(), &readsb_protobuf.AircraftsUpdate{Now: uint64(time.Now().Unix())}))
+		require.NoError(t, rec.Close())
+
+		dataSources, replay, err := parse(t, "--replay", path).DataSources(adb, nil)
+		require.NoError(t, err)
+		require.NotNil(t, replay)
+		assert.Equal(t, []datasources.DataSource{replay}, dataSources)
+
+		_, _, err = parse(t, "--replay", filepath.Join(t.TempDir(), "missing.pwrec")).DataSources(adb, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadAircraftDB(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	adb := datasources.NewAircraftDB(60)
+	fs := datasources.FieldSource{Source: "test", Time: time.Now()}
+	adb.SetCallsign(0x7C1465, "VOZ123", fs)
+	adb.SetLastSeen(0x7C1465, fs)
+
+	t.Run("Test missing", func(t *testing.T) {
+		parse(t, "--aircraftdb", filepath.Join(t.TempDir(), "missing.gz")).LoadAircraftDB(context.Background(), adb)
+		assert.Empty(t, adb.GetAircraft()[0x7C1465].Registration)
+	})
+
+	t.Run("Test file", func(t *testing.T) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write([]byte("version\t298\n7C1465\tVH-VYK\tB738\n"))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		path := filepath.Join(t.TempDir(), datasources.READSB_DB_FILE)
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+
+		parse(t, "--aircraftdb", path).LoadAircraftDB(context.Background(), adb)
+		a := adb.GetAircraft()[0x7C1465]
+		assert.Equal(t, "VH-VYK", a.Registration)
+		assert.Equal(t, "B738", a.AircraftType)
+	})
+}
@@ -0,0 +1,48 @@
+package datasources
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	TCP_DIAL_TIMEOUT = 10 // seconds
+)
+
+// tcpStreamReader reads messages from conn until it is closed, returning the number of messages received
+type tcpStreamReader func(ctx context.Context, conn net.Conn) (numMessages int, err error)
+
+func closeOnDone(ctx context.Context, conn io.Closer) (stop func()) {
+	// closes conn if ctx is cancelled, to unblock any readers
+	// the returned function must be called once reading has finished
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func runTCPStream(ctx context.Context, runner *dataSourceRunner, logPrefix, addr string, read tcpStreamReader) {
+	// connects to addr and passes the connection to read, reconnecting with exponential backoff, until ctx is cancelled
+
+	dialer := net.Dialer{Timeout: time.Second * TCP_DIAL_TIMEOUT}
+
+	runWithReconnect(ctx, runner, logPrefix, addr, func(ctx context.Context) (int, error) {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		log.Printf("%s: Connected to %s", logPrefix, addr)
+		stop := closeOnDone(ctx, conn)
+		defer stop()
+		return read(ctx, conn)
+	})
+}
@@ -0,0 +1,131 @@
+package deadreckoning
+
+// Dead reckoning moves aircraft markers smoothly between position reports, by extrapolating each
+// aircraft's last reported position from its ground speed, track & track rate.
+//
+// Extrapolation is timed from when a report was first seen locally, rather than the report's own
+// timestamp, so receiver clock skew & replayed recordings don't throw markers forward. When a new report
+// arrives, the difference between where the marker was drawn & the new position is blended away over
+// SNAP_BACK_MILLIS, rather than the marker jumping.
+
+import (
+	"math"
+	"pw_slippymap/datasources"
+	"time"
+)
+
+const (
+	DEAD_RECKONING_MAX_AGE_SECONDS = 15   // positions aren't extrapolated further than this past the last report
+	SNAP_BACK_MILLIS               = 1000 // time to blend from the extrapolated position to a new report
+	SNAP_BACK_MAX_METRES           = 2000 // corrections bigger than this jump straight to the new report
+	METRES_PER_SECOND_PER_KNOT     = 1852 / 3600.0
+	DEGREES_TO_RADIANS             = math.Pi / 180
+)
+
+func Extrapolate(lat, long float64, groundSpeed, track int, trackRate float64, age time.Duration) (newLat, newLong float64) {
+	// Returns the position an aircraft at lat/long will be at after age, flying at groundSpeed (knots) on
+	// track (degrees), turning at trackRate (degrees/second)
+	t := age.Seconds()
+	if t <= 0 || groundSpeed <= 0 {
+		return lat, long
+	}
+	v := float64(groundSpeed) * METRES_PER_SECOND_PER_KNOT
+	heading := float64(track) * DEGREES_TO_RADIANS
+	turn := trackRate * DEGREES_TO_RADIANS
+
+	// metres east & north
+	var east, north float64
+	if math.Abs(turn) < 1e-6 {
+		// straight line
+		east = v * t * math.Sin(heading)
+		north = v * t * math.Cos(heading)
+	} else {
+		// arc of a constant rate turn
+		east = v / turn * (math.Cos(heading) - math.Cos(heading+turn*t))
+		north = v / turn * (math.Sin(heading+turn*t) - math.Sin(heading))
+	}
+
+	newLat = lat + (north/datasources.EARTH_RADIUS_METRES)/DEGREES_TO_RADIANS
+	newLong = long + (east/(datasources.EARTH_RADIUS_METRES*math.Cos(lat*DEGREES_TO_RADIANS)))/DEGREES_TO_RADIANS
+	return newLat, newLong
+}
+
+// aircraftState is what a Tracker remembers about an aircraft between frames
+type aircraftState struct {
+	reportTime  time.Time // PositionSource.Time of the last report
+	arrived     time.Time // when the last report was first seen
+	drawnLat    float64   // last position returned by Position
+	drawnLong   float64
+	offsetLat   float64 // correction being blended away, added to the extrapolated position
+	offsetLong  float64
+	offsetStart time.Time
+}
+
+// Tracker returns smoothly extrapolated positions for aircraft, call Position once per aircraft per frame
+type Tracker struct {
+	maxAge   time.Duration
+	aircraft map[int]*aircraftState
+}
+
+func NewTracker(maxAge time.Duration) *Tracker {
+	// Returns a Tracker that extrapolates positions up to maxAge past the last report, 0 to not extrapolate
+	return &Tracker{
+		maxAge:   maxAge,
+		aircraft: make(map[int]*aircraftState),
+	}
+}
+
+func (tr *Tracker) Position(icao int, a datasources.Aircraft, now time.Time) (lat, long float64, moving bool) {
+	// Returns where to draw aircraft icao at now, and whether it will have moved by the next frame
+	if tr.maxAge <= 0 {
+		return a.Lat, a.Long, false
+	}
+
+	s, ok := tr.aircraft[icao]
+	if !ok {
+		s = &aircraftState{reportTime: a.PositionSource.Time, arrived: now, drawnLat: a.Lat, drawnLong: a.Long}
+		tr.aircraft[icao] = s
+	}
+
+	// a new report: blend from where the marker was drawn
+	if !a.PositionSource.Time.Equal(s.reportTime) {
+		s.reportTime = a.PositionSource.Time
+		s.arrived = now
+		s.offsetLat = s.drawnLat - a.Lat
+		s.offsetLong = s.drawnLong - a.Long
+		s.offsetStart = now
+		north := s.offsetLat * DEGREES_TO_RADIANS * datasources.EARTH_RADIUS_METRES
+		east := s.offsetLong * DEGREES_TO_RADIANS * datasources.EARTH_RADIUS_METRES * math.Cos(a.Lat*DEGREES_TO_RADIANS)
+		if math.Hypot(north, east) > SNAP_BACK_MAX_METRES {
+			s.offsetLat, s.offsetLong = 0, 0
+		}
+	}
+
+	// extrapolate, up to maxAge
+	age := now.Sub(s.arrived)
+	if age > tr.maxAge {
+		age = tr.maxAge
+	}
+	lat, long = Extrapolate(a.Lat, a.Long, a.GroundSpeed, a.Track, float64(a.Meta.TrackRate), age)
+	moving = a.GroundSpeed > 0 && age < tr.maxAge
+
+	// blend away the correction
+	blend := 1 - float64(now.Sub(s.offsetStart))/float64(time.Millisecond*SNAP_BACK_MILLIS)
+	if blend > 0 && (s.offsetLat != 0 || s.offsetLong != 0) {
+		lat += s.offsetLat * blend
+		long += s.offsetLong * blend
+		moving = true
+	}
+
+	s.drawnLat, s.drawnLong = lat, long
+	return lat, long, moving
+}
+
+func (tr *Tracker) Prune(aircraft map[int]datasources.Aircraft) {
+	// Forgets aircraft that aren't in aircraft
+	for icao := range tr.aircraft {
+		if _, ok := aircraft[icao]; !ok {
+			delete(tr.aircraft, icao)
+		}
+	}
+}
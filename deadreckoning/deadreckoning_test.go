@@ -0,0 +1,113 @@
+package deadreckoning
+
+import (
+	"pw_slippymap/datasources"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtrapolate(t *testing.T) {
+
+	t.Run("Test straight", func(t *testing.T) {
+		// 360 kt north for 10 seconds is 1852 m, or 1/60 degree of latitude
+		lat, long := Extrapolate(-32.0, 115.9, 360, 0, 0, time.Second*10)
+		assert.InDelta(t, -32.0+1.0/60, lat, 0.0001)
+		assert.InDelta(t, 115.9, long, 0.0001)
+
+		// east, longitude degrees are shorter away from the equator
+		lat, long = Extrapolate(-60.0, 115.9, 360, 90, 0, time.Second*10)
+		assert.InDelta(t, -60.0, lat, 0.0001)
+		assert.InDelta(t, 115.9+2.0/60, long, 0.0001)
+	})
+
+	t.Run("Test turning", func(t *testing.T) {
+		// a 3 degree/second turn for 60 seconds is a semicircle: back where it started, displaced sideways
+		// by the turn diameter (2 * v / rate)
+		lat, long := Extrapolate(0, 0, 360, 0, 3, time.Second*60)
+		diameter := 2 * 360 * METRES_PER_SECOND_PER_KNOT / (3 * DEGREES_TO_RADIANS)
+		assert.InDelta(t, 0, lat, 0.0001)
+		assert.InDelta(t, diameter/datasources.EARTH_RADIUS_METRES/DEGREES_TO_RADIANS, long, 0.0001)
+	})
+
+	t.Run("Test stationary", func(t *testing.T) {
+		lat, long := Extrapolate(-32.0, 115.9, 0, 90, 0, time.Second*10)
+		assert.Equal(t, -32.0, lat)
+		assert.Equal(t, 115.9, long)
+	})
+}
+
+func TestTracker(t *testing.T) {
+
+	tr := NewTracker(time.Second * DEAD_RECKONING_MAX_AGE_SECONDS)
+	start := time.Now()
+	a := datasources.Aircraft{
+		Lat:            -32.0,
+		Long:           115.9,
+		Track:          0,
+		GroundSpeed:    360,
+		PositionSource: datasources.FieldSource{Source: "test", Time: start.Add(-time.Hour)}, // clock skew doesn't matter
+	}
+
+	t.Run("Test first report", func(t *testing.T) {
+		lat, long, moving := tr.Position(0x7C79CA, a, start)
+		assert.Equal(t, a.Lat, lat)
+		assert.Equal(t, a.Long, long)
+		assert.True(t, moving)
+	})
+
+	t.Run("Test extrapolated", func(t *testing.T) {
+		lat, _, moving := tr.Position(0x7C79CA, a, start.Add(time.Second*10))
+		assert.InDelta(t, -32.0+1.0/60, lat, 0.0001)
+		assert.True(t, moving)
+	})
+
+	t.Run("Test snaps back smoothly", func(t *testing.T) {
+		// new report 10s later, slightly behind where the marker was drawn
+		a.Lat = -32.0 + 0.9/60
+		a.PositionSource.Time = a.PositionSource.Time.Add(time.Second * 10)
+		now := start.Add(time.Second * 10)
+
+		// starts where the marker was
+		lat, _, _ := tr.Position(0x7C79CA, a, now)
+		assert.InDelta(t, -32.0+1.0/60, lat, 0.0001)
+
+		// half way through blending
+		lat, _, _ = tr.Position(0x7C79CA, a, now.Add(time.Millisecond*SNAP_BACK_MILLIS/2))
+		extrapolated, _ := Extrapolate(a.Lat, a.Long, a.GroundSpeed, a.Track, 0, time.Millisecond*SNAP_BACK_MILLIS/2)
+		assert.InDelta(t, extrapolated+(0.1/60)/2, lat, 0.0001)
+
+		// blended away
+		lat, _, _ = tr.Position(0x7C79CA, a, now.Add(time.Millisecond*SNAP_BACK_MILLIS))
+		extrapolated, _ = Extrapolate(a.Lat, a.Long, a.GroundSpeed, a.Track, 0, time.Millisecond*SNAP_BACK_MILLIS)
+		assert.InDelta(t, extrapolated, lat, 0.00001)
+	})
+
+	t.Run("Test capped at max age", func(t *testing.T) {
+		now := start.Add(time.Second * 10)
+		capped, _ := Extrapolate(a.Lat, a.Long, a.GroundSpeed, a.Track, 0, time.Second*DEAD_RECKONING_MAX_AGE_SECONDS)
+		lat, _, moving := tr.Position(0x7C79CA, a, now.Add(time.Minute))
+		assert.InDelta(t, capped, lat, 0.00001)
+		assert.False(t, moving)
+	})
+
+	t.Run("Test big corrections jump", func(t *testing.T) {
+		a.Lat = -33.0
+		a.PositionSource.Time = a.PositionSource.Time.Add(time.Second * 10)
+		lat, _, _ := tr.Position(0x7C79CA, a, start.Add(time.Minute*2))
+		assert.Equal(t, -33.0, lat)
+	})
+
+	t.Run("Test prune", func(t *testing.T) {
+		tr.Prune(map[int]datasources.Aircraft{})
+		assert.Empty(t, tr.aircraft)
+	})
+
+	t.Run("Test disabled", func(t *testing.T) {
+		lat, long, moving := NewTracker(0).Position(0x7C79CA, a, start.Add(time.Hour))
+		assert.Equal(t, a.Lat, lat)
+		assert.Equal(t, a.Long, long)
+		assert.False(t, moving)
+	})
+}
@@ -0,0 +1,278 @@
+package geofence
+
+// Geofences are areas, optionally limited to an altitude band, loaded from GeoJSON.
+//
+// Each GeoJSON Polygon or MultiPolygon feature is a fence. Optional feature properties:
+//   - name: shown in alerts (default: "fence N")
+//   - floor: lowest altitude in the fence, feet (default: no floor)
+//   - ceiling: highest altitude in the fence, feet (default: no ceiling)
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrNoFences        = errors.New("no Polygon or MultiPolygon features found")
+	ErrInvalidGeometry = errors.New("invalid geometry")
+)
+
+// Point is a position, in decimal degrees
+type Point struct {
+	Lat  float64
+	Long float64
+}
+
+// Polygon is an outer ring, followed by any holes
+// Rings are closed, the last point is the same as the first
+type Polygon [][]Point
+
+// Fence is an area to watch for aircraft entering & leaving
+type Fence struct {
+	Name     string
+	Polygons []Polygon
+	Floor    *int // feet, nil for no floor
+	Ceiling  *int // feet, nil for no ceiling
+
+	// bounding box, for quickly rejecting positions
+	minLat, maxLat, minLong, maxLong float64
+}
+
+// geoJSON is the subset of GeoJSON used by fences (FeatureCollection, Feature, or a bare geometry)
+// ref: https://datatracker.ietf.org/doc/html/rfc7946
+type geoJSON struct {
+	Type        string                 `json:"type"`
+	Features    []geoJSON              `json:"features"`
+	Geometry    *geoJSON               `json:"geometry"`
+	Properties  map[string]interface{} `json:"properties"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+}
+
+func Load(path string) ([]*Fence, error) {
+	// Returns the fences in the GeoJSON file at path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fences, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fences, nil
+}
+
+func Parse(data []byte) ([]*Fence, error) {
+	// Returns the fences in GeoJSON data
+	var g geoJSON
+	err := json.Unmarshal(data, &g)
+	if err != nil {
+		return nil, err
+	}
+	var fences []*Fence
+	err = g.collect(&fences)
+	if err != nil {
+		return nil, err
+	}
+	if len(fences) == 0 {
+		return nil, ErrNoFences
+	}
+	return fences, nil
+}
+
+func (g *geoJSON) collect(fences *[]*Fence) error {
+	// appends the fences in g to fences
+	switch g.Type {
+	case "FeatureCollection":
+		for i := range g.Features {
+			err := g.Features[i].collect(fences)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "Feature":
+		if g.Geometry == nil {
+			return nil
+		}
+		polygons, err := g.Geometry.polygons()
+		if err != nil || polygons == nil {
+			return err
+		}
+		fence, err := newFence(polygons, g.Properties, len(*fences)+1)
+		if err != nil {
+			return err
+		}
+		*fences = append(*fences, fence)
+		return nil
+
+	default:
+		polygons, err := g.polygons()
+		if err != nil || polygons == nil {
+			return err
+		}
+		fence, err := newFence(polygons, nil, len(*fences)+1)
+		if err != nil {
+			return err
+		}
+		*fences = append(*fences, fence)
+		return nil
+	}
+}
+
+func (g *geoJSON) polygons() ([]Polygon, error) {
+	// returns the polygons in a Polygon or MultiPolygon geometry, or nil for other geometry types
+	switch g.Type {
+	case "Polygon":
+		var coords [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidGeometry, err)
+		}
+		polygon, err := toPolygon(coords)
+		if err != nil {
+			return nil, err
+		}
+		return []Polygon{polygon}, nil
+
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidGeometry, err)
+		}
+		polygons := make([]Polygon, 0, len(coords))
+		for _, c := range coords {
+			polygon, err := toPolygon(c)
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, polygon)
+		}
+		return polygons, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+func toPolygon(coords [][][]float64) (Polygon, error) {
+	// converts GeoJSON polygon coordinates ([long, lat] positions) to a Polygon
+	if len(coords) == 0 {
+		return nil, fmt.Errorf("%w: polygon has no rings", ErrInvalidGeometry)
+	}
+	polygon := make(Polygon, 0, len(coords))
+	for _, ring := range coords {
+		if len(ring) < 4 {
+			return nil, fmt.Errorf("%w: ring has %d positions, at least 4 are needed", ErrInvalidGeometry, len(ring))
+		}
+		points := make([]Point, 0, len(ring)+1)
+		for _, position := range ring {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("%w: position has %d values", ErrInvalidGeometry, len(position))
+			}
+			points = append(points, Point{Lat: position[1], Long: position[0]})
+		}
+		// close the ring if it isn't already
+		if points[0] != points[len(points)-1] {
+			points = append(points, points[0])
+		}
+		polygon = append(polygon, points)
+	}
+	return polygon, nil
+}
+
+func newFence(polygons []Polygon, properties map[string]interface{}, n int) (*Fence, error) {
+	// returns a Fence made from polygons & GeoJSON feature properties, n is used for the default name
+	f := &Fence{
+		Name:     fmt.Sprintf("fence %d", n),
+		Polygons: polygons,
+	}
+	if name, ok := properties["name"].(string); ok && name != "" {
+		f.Name = name
+	}
+	for _, limit := range []struct {
+		property string
+		value    **int
+	}{
+		{"floor", &f.Floor},
+		{"ceiling", &f.Ceiling},
+	} {
+		v, ok := properties[limit.property]
+		if !ok || v == nil {
+			continue
+		}
+		feet, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("fence %q: %s must be a number of feet", f.Name, limit.property)
+		}
+		ft := int(feet)
+		*limit.value = &ft
+	}
+
+	// bounding box of the outer rings
+	first := true
+	for _, polygon := range polygons {
+		for _, p := range polygon[0] {
+			if first || p.Lat < f.minLat {
+				f.minLat = p.Lat
+			}
+			if first || p.Lat > f.maxLat {
+				f.maxLat = p.Lat
+			}
+			if first || p.Long < f.minLong {
+				f.minLong = p.Long
+			}
+			if first || p.Long > f.maxLong {
+				f.maxLong = p.Long
+			}
+			first = false
+		}
+	}
+	return f, nil
+}
+
+func ringContains(ring []Point, lat, long float64) bool {
+	// returns true if lat/long is inside ring (ray casting)
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > lat) != (b.Lat > lat) &&
+			long < (b.Long-a.Long)*(lat-a.Lat)/(b.Lat-a.Lat)+a.Long {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (p Polygon) Contains(lat, long float64) bool {
+	// returns true if lat/long is inside the outer ring, and not inside a hole
+	if !ringContains(p[0], lat, long) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, lat, long) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Fence) Contains(lat, long float64, alt int) bool {
+	// returns true if the position lat/long at altitude alt (feet) is inside the fence
+	if f.Floor != nil && alt < *f.Floor {
+		return false
+	}
+	if f.Ceiling != nil && alt > *f.Ceiling {
+		return false
+	}
+	if lat < f.minLat || lat > f.maxLat || long < f.minLong || long > f.maxLong {
+		return false
+	}
+	for _, polygon := range f.Polygons {
+		if polygon.Contains(lat, long) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package geofence
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	fences, err := Load(filepath.Join("testdata", "fences.geojson"))
+	require.NoError(t, err)
+	require.Len(t, fences, 2)
+
+	t.Run("Test polygon with altitude band", func(t *testing.T) {
+		f := fences[0]
+		assert.Equal(t, "Perth approach", f.Name)
+		require.NotNil(t, f.Floor)
+		require.NotNil(t, f.Ceiling)
+		assert.Equal(t, 0, *f.Floor)
+		assert.Equal(t, 5000, *f.Ceiling)
+		require.Len(t, f.Polygons, 1)
+
+		assert.True(t, f.Contains(-31.9, 116.0, 2500))
+		assert.False(t, f.Contains(-31.9, 116.0, 5001))
+		assert.False(t, f.Contains(-31.9, 116.0, -100))
+		assert.False(t, f.Contains(-31.9, 115.8, 2500))
+		assert.False(t, f.Contains(-32.1, 116.0, 2500))
+	})
+
+	t.Run("Test multipolygon with hole", func(t *testing.T) {
+		f := fences[1]
+		assert.Equal(t, "Rottnest", f.Name)
+		assert.Nil(t, f.Floor)
+		assert.Nil(t, f.Ceiling)
+		require.Len(t, f.Polygons, 2)
+
+		// unclosed ring is closed
+		assert.Len(t, f.Polygons[0][0], 5)
+
+		assert.True(t, f.Contains(-31.96, 115.42, 40000))
+		assert.False(t, f.Contains(-32.00, 115.50, 1000)) // in the hole
+		assert.True(t, f.Contains(-31.97, 115.72, 1000))  // second polygon
+		assert.False(t, f.Contains(-31.97, 115.65, 1000)) // between polygons
+	})
+
+	t.Run("Test missing file", func(t *testing.T) {
+		_, err := Load(filepath.Join("testdata", "missing.geojson"))
+		assert.Error(t, err)
+	})
+}
+
+func TestParse(t *testing.T) {
+
+	t.Run("Test bare geometry", func(t *testing.T) {
+		fences, err := Parse([]byte(`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]]}`))
+		require.NoError(t, err)
+		require.Len(t, fences, 1)
+		assert.Equal(t, "fence 1", fences[0].Name)
+		assert.True(t, fences[0].Contains(0.5, 0.5, 0))
+	})
+
+	t.Run("Test no fences", func(t *testing.T) {
+		_, err := Parse([]byte(`{"type": "FeatureCollection", "features": []}`))
+		assert.ErrorIs(t, err, ErrNoFences)
+	})
+
+	t.Run("Test invalid geometry", func(t *testing.T) {
+		_, err := Parse([]byte(`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`))
+		assert.ErrorIs(t, err, ErrInvalidGeometry)
+		_, err = Parse([]byte(`{"type": "Polygon", "coordinates": "nope"}`))
+		assert.ErrorIs(t, err, ErrInvalidGeometry)
+	})
+
+	t.Run("Test invalid altitude", func(t *testing.T) {
+		_, err := Parse([]byte(`{"type": "Feature", "properties": {"floor": "low"}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("Test invalid JSON", func(t *testing.T) {
+		_, err := Parse([]byte(`{`))
+		assert.Error(t, err)
+	})
+}
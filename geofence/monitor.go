@@ -0,0 +1,200 @@
+package geofence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"pw_slippymap/datasources"
+	"strings"
+	"time"
+)
+
+const (
+	WEBHOOK_TIMEOUT_SECONDS = 10
+)
+
+// AlertType is whether an aircraft entered or left a fence
+type AlertType int
+
+const (
+	ALERT_ENTERED AlertType = iota // the aircraft entered the fence, or was first seen inside it
+	ALERT_EXITED                   // the aircraft left the fence
+)
+
+func (t AlertType) String() string {
+	switch t {
+	case ALERT_ENTERED:
+		return "entered"
+	case ALERT_EXITED:
+		return "exited"
+	default:
+		return "unknown"
+	}
+}
+
+// Alert is an aircraft entering or leaving a fence
+type Alert struct {
+	Type     AlertType
+	Fence    *Fence
+	ICAO     int
+	Aircraft datasources.Aircraft // the aircraft at the position that caused the alert
+	Time     time.Time
+}
+
+func (a Alert) String() string {
+	// returns a human readable description of the alert
+	callsign := strings.TrimSpace(a.Aircraft.Callsign)
+	if callsign == "" {
+		callsign = "unknown"
+	}
+	return fmt.Sprintf("%s (%X) %s %s at %d ft", callsign, a.ICAO, a.Type, a.Fence.Name, a.Aircraft.AltBaro)
+}
+
+// Notifier is something that alerts are sent to, eg: the log, the UI, a webhook
+type Notifier interface {
+	Notify(alert Alert)
+}
+
+// NotifierFunc is a function that is a Notifier
+type NotifierFunc func(alert Alert)
+
+func (f NotifierFunc) Notify(alert Alert) {
+	f(alert)
+}
+
+// LogNotifier logs alerts
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(alert Alert) {
+	log.Printf("geofence: %s", alert)
+}
+
+// WebhookNotifier POSTs alerts as JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	client *http.Client
+}
+
+// webhookPayload is the JSON body POSTed by WebhookNotifier
+type webhookPayload struct {
+	Alert    string    `json:"alert"` // "entered" or "exited"
+	Fence    string    `json:"fence"`
+	ICAO     string    `json:"icao"`
+	Callsign string    `json:"callsign"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Altitude int       `json:"altitude"`
+	Time     time.Time `json:"time"`
+	Message  string    `json:"message"`
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	// Returns a Notifier that POSTs alerts to url
+	return &WebhookNotifier{
+		URL:    url,
+		client: &http.Client{Timeout: time.Second * WEBHOOK_TIMEOUT_SECONDS},
+	}
+}
+
+func (wn *WebhookNotifier) Notify(alert Alert) {
+	// POSTs the alert in the background, so a slow webhook doesn't hold up other notifiers
+	body, err := json.Marshal(webhookPayload{
+		Alert:    alert.Type.String(),
+		Fence:    alert.Fence.Name,
+		ICAO:     fmt.Sprintf("%06X", alert.ICAO),
+		Callsign: strings.TrimSpace(alert.Aircraft.Callsign),
+		Lat:      alert.Aircraft.Lat,
+		Lon:      alert.Aircraft.Long,
+		Altitude: alert.Aircraft.AltBaro,
+		Time:     alert.Time,
+		Message:  alert.String(),
+	})
+	if err != nil {
+		log.Printf("geofence.Webhook: %s", err)
+		return
+	}
+	go func() {
+		resp, err := wn.client.Post(wn.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("geofence.Webhook: %s", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			log.Printf("geofence.Webhook: %s returned HTTP status %d", wn.URL, resp.StatusCode)
+		}
+	}()
+}
+
+// Monitor watches AircraftDB position updates, and sends alerts when aircraft enter or leave fences
+type Monitor struct {
+	fences    []*Fence
+	notifiers []Notifier
+	inside    map[int]map[*Fence]bool // fences each aircraft is inside
+}
+
+func NewMonitor(fences []*Fence, notifiers ...Notifier) *Monitor {
+	// Returns a Monitor that sends alerts for fences to notifiers
+	return &Monitor{
+		fences:    fences,
+		notifiers: notifiers,
+		inside:    make(map[int]map[*Fence]bool),
+	}
+}
+
+func (m *Monitor) evaluate(e datasources.Event) (alerts []Alert) {
+	// returns the alerts caused by an AircraftDB event
+	switch e.Type {
+	case datasources.EVENT_POSITION_UPDATED:
+		a := e.Aircraft
+		inside := m.inside[e.ICAO]
+		for _, f := range m.fences {
+			now := f.Contains(a.Lat, a.Long, a.AltBaro)
+			if now == inside[f] {
+				continue
+			}
+			if inside == nil {
+				inside = make(map[*Fence]bool)
+				m.inside[e.ICAO] = inside
+			}
+			inside[f] = now
+			alertType := ALERT_ENTERED
+			if !now {
+				alertType = ALERT_EXITED
+			}
+			alerts = append(alerts, Alert{
+				Type:     alertType,
+				Fence:    f,
+				ICAO:     e.ICAO,
+				Aircraft: a,
+				Time:     e.Time,
+			})
+		}
+
+	case datasources.EVENT_TIMED_OUT:
+		// we don't know where it went, so no alert
+		delete(m.inside, e.ICAO)
+	}
+	return alerts
+}
+
+func (m *Monitor) Run(ctx context.Context, adb *datasources.AircraftDB) {
+	// evaluates adb position updates until ctx is cancelled
+	sub := adb.Subscribe(0)
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-sub.C:
+			for _, alert := range m.evaluate(e) {
+				for _, n := range m.notifiers {
+					n.Notify(alert)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package geofence
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pw_slippymap/datasources"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFences(t *testing.T) []*Fence {
+	// returns a fence from 0,0 to 1,1, below 5000 ft
+	fences, err := Parse([]byte(`{"type": "Feature", "properties": {"name": "test", "ceiling": 5000}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]]}}`))
+	require.NoError(t, err)
+	return fences
+}
+
+func positionEvent(icao int, lat, long float64, alt int) datasources.Event {
+	// returns a position update event
+	return datasources.Event{
+		Type:     datasources.EVENT_POSITION_UPDATED,
+		ICAO:     icao,
+		Time:     time.Now(),
+		Aircraft: datasources.Aircraft{Callsign: "TEST1   ", Lat: lat, Long: long, AltBaro: alt},
+	}
+}
+
+func TestMonitorEvaluate(t *testing.T) {
+
+	m := NewMonitor(testFences(t))
+
+	t.Run("Test outside", func(t *testing.T) {
+		assert.Empty(t, m.evaluate(positionEvent(0x7C79CA, 2, 2, 1000)))
+	})
+
+	t.Run("Test entered", func(t *testing.T) {
+		alerts := m.evaluate(positionEvent(0x7C79CA, 0.5, 0.5, 1000))
+		require.Len(t, alerts, 1)
+		assert.Equal(t, ALERT_ENTERED, alerts[0].Type)
+		assert.Equal(t, "test", alerts[0].Fence.Name)
+		assert.Equal(t, 0x7C79CA, alerts[0].ICAO)
+		assert.Equal(t, "TEST1 (7C79CA) entered test at 1000 ft", alerts[0].String())
+
+		// still inside
+		assert.Empty(t, m.evaluate(positionEvent(0x7C79CA, 0.6, 0.6, 1000)))
+	})
+
+	t.Run("Test exited by climbing", func(t *testing.T) {
+		alerts := m.evaluate(positionEvent(0x7C79CA, 0.6, 0.6, 6000))
+		require.Len(t, alerts, 1)
+		assert.Equal(t, ALERT_EXITED, alerts[0].Type)
+	})
+
+	t.Run("Test first seen inside", func(t *testing.T) {
+		alerts := m.evaluate(positionEvent(0x7C6DD8, 0.5, 0.5, 1000))
+		require.Len(t, alerts, 1)
+		assert.Equal(t, ALERT_ENTERED, alerts[0].Type)
+	})
+
+	t.Run("Test timed out", func(t *testing.T) {
+		assert.Empty(t, m.evaluate(datasources.Event{Type: datasources.EVENT_TIMED_OUT, ICAO: 0x7C6DD8}))
+		assert.NotContains(t, m.inside, 0x7C6DD8)
+	})
+}
+
+func TestMonitorRun(t *testing.T) {
+
+	adb := datasources.NewAircraftDB(60)
+	alerts := make(chan Alert, 10)
+	m := NewMonitor(testFences(t), LogNotifier{}, NotifierFunc(func(a Alert) { alerts <- a }))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx, adb)
+
+	// move the aircraft inside the fence until the monitor has subscribed & sees it
+	fs := datasources.FieldSource{Source: "test", Time: time.Now()}
+	i := 0
+	require.Eventually(t, func() bool {
+		i++
+		fs.Time = fs.Time.Add(time.Second)
+		adb.SetPosition(0x7C79CA, 0.5, 0.5+float64(i)*0.001, 1000, fs)
+		adb.SetLastSeen(0x7C79CA, fs)
+		select {
+		case a := <-alerts:
+			return a.Type == ALERT_ENTERED
+		default:
+			return false
+		}
+	}, time.Second*5, time.Millisecond*10)
+
+	fs.Time = fs.Time.Add(time.Second)
+	adb.SetPosition(0x7C79CA, 2, 2, 1000, fs)
+	select {
+	case a := <-alerts:
+		assert.Equal(t, ALERT_EXITED, a.Type)
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "timed out waiting for alert")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	received := make(chan webhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhookPayload
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		received <- p
+	}))
+	defer server.Close()
+
+	fences := testFences(t)
+	alert := Alert{
+		Type:     ALERT_ENTERED,
+		Fence:    fences[0],
+		ICAO:     0x7C79CA,
+		Aircraft: datasources.Aircraft{Callsign: "HARR89  ", Lat: 0.5, Long: 0.6, AltBaro: 1300},
+		Time:     time.Now(),
+	}
+	NewWebhookNotifier(server.URL).Notify(alert)
+
+	select {
+	case p := <-received:
+		assert.Equal(t, "entered", p.Alert)
+		assert.Equal(t, "test", p.Fence)
+		assert.Equal(t, "7C79CA", p.ICAO)
+		assert.Equal(t, "HARR89", p.Callsign)
+		assert.Equal(t, 0.6, p.Lon)
+		assert.Equal(t, 1300, p.Altitude)
+		assert.Equal(t, alert.String(), p.Message)
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "timed out waiting for webhook")
+	}
+}
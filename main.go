@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"image"
 	"image/color"
 	"log"
 	"os"
@@ -9,11 +11,20 @@ import (
 	"pw_slippymap/attribution"
 	"pw_slippymap/datasources"
 	"pw_slippymap/datasources/readsb_protobuf"
+	"pw_slippymap/datasources/recording"
+	"pw_slippymap/datasources/sourceflags"
+	"pw_slippymap/deadreckoning"
+	"pw_slippymap/geofence"
 	"pw_slippymap/markers"
+	"pw_slippymap/proximity"
 	"pw_slippymap/slippymap"
+	"pw_slippymap/timeline"
+	"pw_slippymap/toast"
 	"pw_slippymap/userinput"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/akamensky/argparse"
 	"github.com/fogleman/gg"
@@ -29,6 +40,20 @@ const (
 	INIT_WINDOW_SIZE    = 0.8      // percentage size of active screen
 	ZOOM_COOLDOWN_TICKS = 5        // number of ticks to wait between zoom in/out ops
 
+	EMERGENCY_RING_SIZE         = 64   // pixels, diameter of the emergency highlight ring image
+	EMERGENCY_RING_PULSE_MILLIS = 1000 // time for the emergency highlight ring to expand & fade
+	EMERGENCY_BANNER_HEIGHT     = 20   // pixels
+
+	GEOFENCE_LINE_WIDTH = 2    // pixels, geofence outline
+	GEOFENCE_IMG_MAX_PX = 4096 // pixels, largest geofence rendering, bigger fences are rendered around the window
+
+	MILITARY_RING_SIZE = 40 // pixels, diameter of the military highlight ring image
+
+	// how aircraft with military ICAO addresses are drawn (--military)
+	MILITARY_SHOW      = "show"
+	MILITARY_HIDE      = "hide"
+	MILITARY_HIGHLIGHT = "highlight"
+
 	// APP STATES -----------------------------------------
 
 	// normal states
@@ -64,6 +89,7 @@ type UserInterface struct {
 	// slippymap
 	slippymap    *slippymap.SlippyMap
 	tileProvider *slippymap.TileProvider // tile provider for slippymap
+	initialView  mapView                 // where the map starts
 
 	// user input
 	touchIDs []ebiten.TouchID
@@ -72,6 +98,9 @@ type UserInterface struct {
 	// aircraft db
 	aircraftDb *datasources.AircraftDB
 
+	// data sources feeding the aircraft db
+	dataSources []datasources.DataSource
+
 	// markers
 	aircraftMarkers      *map[string]markers.Marker
 	groundVehicleMarkers *map[string]markers.Marker
@@ -79,10 +108,49 @@ type UserInterface struct {
 	// altitude scale
 	altitudeScale *altitude.AltitudeScale
 
+	// replay timeline scrubber, nil if not replaying a recording
+	timeline *timeline.Timeline
+
+	// emergencies: highlight ring, and number of aircraft declaring an emergency (so the ring keeps pulsing)
+	emergencyRing *ebiten.Image
+	emergencies   int
+
+	// how to draw military aircraft (one of MILITARY_*), and their highlight ring
+	militaryFilter string
+	militaryRing   *ebiten.Image
+
+	// geofences, and their last rendering (only redrawn when the map zooms)
+	geofences        []*geofence.Fence
+	geofenceImg      *ebiten.Image
+	geofenceImgState geofenceDrawState
+
+	// notifications shown at the top right of the screen
+	toasts *toast.Toasts
+
+	// separation minima for flagging pairs of aircraft
+	proximityMinima proximity.Minima
+
+	// extrapolates aircraft positions between reports, and whether any markers are moving (so frames keep being drawn)
+	deadReckoning *deadreckoning.Tracker
+	markersMoving bool
+
 	// debugging: show map tile OSM X/Y/zoom
 	debugShowMapTileXYZ bool
 }
 
+// mapView is a map centre & zoom level
+type mapView struct {
+	lat, long float64
+	zoomLevel int
+}
+
+// geofenceDrawState is what the geofence rendering depends on, in pixels relative to lat/long 0,0
+type geofenceDrawState struct {
+	zoomLevel int
+	bounds    image.Rectangle // all the fences
+	rendered  image.Rectangle // area of the fences in the rendering
+}
+
 func (ui *UserInterface) getState() int {
 	// get the user interface (game) state
 	ui.stateMutex.Lock()
@@ -113,6 +181,22 @@ func (ui *UserInterface) loadSprites() {
 	failFatally(err)
 	ui.aircraftMarkers = &aircraftMarkers
 	ui.groundVehicleMarkers = &groundVehicleMarkers
+
+	// emergency highlight ring
+	dc := gg.NewContext(EMERGENCY_RING_SIZE, EMERGENCY_RING_SIZE)
+	dc.DrawCircle(EMERGENCY_RING_SIZE/2, EMERGENCY_RING_SIZE/2, EMERGENCY_RING_SIZE/2-3)
+	dc.SetColor(color.RGBA{R: 255, G: 0, B: 0, A: 255})
+	dc.SetLineWidth(4)
+	dc.Stroke()
+	ui.emergencyRing = ebiten.NewImageFromImage(dc.Image())
+
+	// military highlight ring
+	dc = gg.NewContext(MILITARY_RING_SIZE, MILITARY_RING_SIZE)
+	dc.DrawCircle(MILITARY_RING_SIZE/2, MILITARY_RING_SIZE/2, MILITARY_RING_SIZE/2-2)
+	dc.SetColor(color.RGBA{R: 85, G: 107, B: 47, A: 255})
+	dc.SetLineWidth(3)
+	dc.Stroke()
+	ui.militaryRing = ebiten.NewImageFromImage(dc.Image())
 }
 
 func (ui *UserInterface) handleWindowResize(windowW, windowH int) {
@@ -169,7 +253,8 @@ func (ui *UserInterface) handleMouseWheel() {
 func (ui *UserInterface) handleMouseMovement() bool {
 	// mouse / touch dragging
 	forceUpdate := false
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+	mouseX, mouseY := ebiten.CursorPosition()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && !(ui.timeline != nil && ui.timeline.Contains(mouseX, mouseY)) {
 		s := userinput.NewStroke(&userinput.MouseStrokeSource{})
 		s.SetDraggingObject(ui.slippymap)
 		ui.strokes[s] = struct{}{}
@@ -205,7 +290,7 @@ func (ui *UserInterface) Update() error {
 		// fmt.Println("======== Start NewAltitudeScale ========")
 		ui.altitudeScale = altitude.NewAltitudeScale(800.0)
 		// fmt.Println("======== End NewAltitudeScale ========")
-		ui.slippymap = slippymap.NewSlippyMap(windowW, windowH, INIT_ZOOM_LEVEL, INIT_CENTRE_LAT, INIT_CENTRE_LONG, *ui.tileProvider)
+		ui.slippymap = slippymap.NewSlippyMap(windowW, windowH, ui.initialView.zoomLevel, ui.initialView.lat, ui.initialView.long, *ui.tileProvider)
 		ui.setState(STATE_RUN)
 
 	case STATE_RUN:
@@ -216,12 +301,24 @@ func (ui *UserInterface) Update() error {
 		// handle mouse wheel
 		ui.handleMouseWheel()
 
+		// handle replay timeline, above the altitude scale
+		if ui.timeline != nil {
+			timelineBottom := windowH - ui.altitudeScale.Img.Bounds().Dy()
+			ui.timeline.SetBounds(image.Rect(0, timelineBottom-timeline.TIMELINE_HEIGHT, windowW, timelineBottom))
+			ui.timeline.Update()
+		}
+
 		// handle mouse/touch dragging for map movement
 		forceUpdate := ui.handleMouseMovement()
 
 		// update the slippymap
 		ui.slippymap.Update(forceUpdate)
 
+		// keep emergency highlights pulsing & extrapolated markers moving, and redraw to remove expired toasts
+		if ui.emergencies > 0 || ui.markersMoving || ui.toasts.Active() {
+			ebiten.ScheduleFrame()
+		}
+
 	case STATE_DEBUG_MARKERS_STARTUP:
 		// debug mode: draw all the markers for testing and adjusting scale
 		ebiten.SetWindowTitle("plane.watch - Debug Markers")
@@ -269,16 +366,47 @@ func (ui *UserInterface) drawAircraftMarkers(screen *ebiten.Image, mouseX, mouse
 	// TODO: change order based on altitude
 	aircraftMap := ui.aircraftDb.GetAircraft()
 	aircraftIcaos := make([]int, 0, len(aircraftMap))
-	for k := range aircraftMap {
+	for k, v := range aircraftMap {
+		if v.Military && ui.militaryFilter == MILITARY_HIDE {
+			delete(aircraftMap, k)
+			continue
+		}
 		aircraftIcaos = append(aircraftIcaos, k)
 	}
 	sort.Ints(aircraftIcaos)
 
+	// predict losses of separation from the reported positions
+	conflicts := proximity.Detect(aircraftMap, ui.proximityMinima)
+
+	// move aircraft to where they are estimated to be now
+	now := time.Now()
+	ui.markersMoving = false
+	for k, v := range aircraftMap {
+		if v.Lat == 0 && v.Long == 0 {
+			continue
+		}
+		var moving bool
+		v.Lat, v.Long, moving = ui.deadReckoning.Position(k, v, now)
+		aircraftMap[k] = v
+		ui.markersMoving = ui.markersMoving || moving
+	}
+	ui.deadReckoning.Prune(aircraftMap)
+
+	// draw predicted losses of separation, beneath the aircraft
+	ui.drawConflicts(screen, conflicts, aircraftMap)
+
+	// aircraft declaring an emergency, for the banner
+	var emergencies []string
+
 	// for each plane we know about
 	for _, k := range aircraftIcaos {
 
 		v := aircraftMap[k]
 
+		if v.Emergency != readsb_protobuf.AircraftMeta_EMERGENCY_NONE {
+			emergencies = append(emergencies, fmt.Sprintf("%s (%X) squawk %04X: %s", strings.TrimSpace(v.Callsign), k, v.Squawk, datasources.EmergencyDescription(v.Emergency)))
+		}
+
 		// skip planes that aren't sending a position
 		// TODO: what about planes actually at 0,0?
 		if v.Lat == 0 && v.Long == 0 {
@@ -315,6 +443,18 @@ func (ui *UserInterface) drawAircraftMarkers(screen *ebiten.Image, mouseX, mouse
 			// plane is probably off the visible map, or not sending a position
 		} else {
 
+			// highlight aircraft declaring an emergency
+			if v.Emergency != readsb_protobuf.AircraftMeta_EMERGENCY_NONE {
+				ui.drawEmergencyRing(screen, aircraftX, aircraftY)
+			}
+
+			// highlight military aircraft
+			if v.Military && ui.militaryFilter == MILITARY_HIGHLIGHT {
+				dio := &ebiten.DrawImageOptions{}
+				dio.GeoM.Translate(float64(aircraftX-MILITARY_RING_SIZE/2), float64(aircraftY-MILITARY_RING_SIZE/2))
+				screen.DrawImage(ui.militaryRing, dio)
+			}
+
 			// prepare the draw options for the marker
 			aircraftDrawOpts := aircraftMarker.MarkerDrawOpts(float64(v.Track), float64(aircraftX), float64(aircraftY))
 
@@ -345,16 +485,16 @@ func (ui *UserInterface) drawAircraftMarkers(screen *ebiten.Image, mouseX, mouse
 					if a != 0 {
 
 						// update mouseover text
-						mouseOverMarkerText = fmt.Sprintf("ICAO: %X, Callsign: %s, Type: %s, Category: %X, Alt: %d, Gs: %d, AirGround: %s", k, v.Callsign, v.AircraftType, v.Category, v.AltBaro, v.GroundSpeed, v.AirGround.String())
+						mouseOverMarkerText = fmt.Sprintf("ICAO: %X, Callsign: %s, Registration: %s, Type: %s (%s, %s, wake %s), Operator: %s (%s), Country: %s (%s), Military: %t, Category: %X, Squawk: %04X, Emergency: %s, Phase: %s, Alert: %t, SPI: %t, Alt: %d, Geom alt: %d, Rate: %d, Gs: %d, IAS: %d, TAS: %d, Mach: %.2f, MCP alt: %d, AirGround: %s, Receivers: %s",
+							k, v.Callsign, v.Registration, v.AircraftType, v.Model, v.TypeClass, v.WakeCategory, v.Operator, v.OperatorCountry, v.Country, v.CountryCode, v.Military, v.Category, v.Squawk, datasources.EmergencyDescription(v.Emergency), v.FlightPhase, v.Meta.Alert, v.Meta.Spi, v.AltBaro, v.Meta.AltGeom, v.Meta.BaroRate, v.GroundSpeed, v.Meta.Ias, v.Meta.Tas, v.Meta.Mach, v.Meta.NavAltitudeMcp, v.AirGround.String(), strings.Join(v.Receivers, ", "))
 
 						// draw trails
 						// TODO: move to function
 						// TODO: have a layer/image for all trails, draw beneath aircraft markers
 						dc := gg.NewContext(ui.slippymap.GetSize())
 						first := true
-						ui.aircraftDb.Mutex.Lock()
 						var prevX, prevY int
-						for _, v := range ui.aircraftDb.Aircraft[k].History {
+						for _, v := range ui.aircraftDb.GetHistory(k) {
 							var x, y int
 							x, y, err = ui.slippymap.LatLongToPixel(v.Lat, v.Long)
 							if err == nil {
@@ -373,7 +513,6 @@ func (ui *UserInterface) drawAircraftMarkers(screen *ebiten.Image, mouseX, mouse
 							}
 						}
 						dc.StrokePreserve()
-						ui.aircraftDb.Mutex.Unlock()
 						screen.DrawImage(ebiten.NewImageFromImage(dc.Image()), nil)
 					}
 				}
@@ -382,13 +521,190 @@ func (ui *UserInterface) drawAircraftMarkers(screen *ebiten.Image, mouseX, mouse
 			// draw it
 			screen.DrawImage(aircraftMarker.Img, &aircraftDrawOpts)
 
+			// flight phase glyph, to the top right of the marker
+			if glyph := v.FlightPhase.Glyph(); glyph != "" {
+				ebitenutil.DebugPrintAt(screen, glyph, int(btmRightX), int(topLeftY))
+			}
+
 		}
 	}
 
+	// list aircraft declaring an emergency, below the debug text
+	ui.emergencies = len(emergencies)
+	if len(emergencies) > 0 {
+		ui.drawEmergencyBanner(screen, emergencies, ui.debugOverlayHeight())
+	}
+
 	return mouseOverMarkerText
 
 }
 
+func (ui *UserInterface) drawEmergencyRing(screen *ebiten.Image, x, y int) {
+	// draws a ring centred on x, y that repeatedly expands & fades
+	pulse := float64(time.Now().UnixMilli()%EMERGENCY_RING_PULSE_MILLIS) / EMERGENCY_RING_PULSE_MILLIS
+	scale := 0.5 + pulse
+	dio := &ebiten.DrawImageOptions{}
+	dio.GeoM.Translate(-EMERGENCY_RING_SIZE/2, -EMERGENCY_RING_SIZE/2)
+	dio.GeoM.Scale(scale, scale)
+	dio.GeoM.Translate(float64(x), float64(y))
+	dio.ColorM.Scale(1, 1, 1, 1-pulse)
+	screen.DrawImage(ui.emergencyRing, dio)
+}
+
+func (ui *UserInterface) drawEmergencyBanner(screen *ebiten.Image, emergencies []string, y int) {
+	// draws a red banner across the screen at y, listing emergencies
+	w, _ := screen.Size()
+	h := EMERGENCY_BANNER_HEIGHT * len(emergencies)
+	ebitenutil.DrawRect(screen, 0, float64(y), float64(w), float64(h), color.RGBA{R: 200, G: 0, B: 0, A: 220})
+	for i, e := range emergencies {
+		ebitenutil.DebugPrintAt(screen, "EMERGENCY: "+e, 5, y+(i*EMERGENCY_BANNER_HEIGHT)+2)
+	}
+}
+
+func (ui *UserInterface) geofenceBounds(originX, originY float64) (bounds image.Rectangle, err error) {
+	// returns the pixel bounds of the geofences at the current zoom level, relative to lat/long 0,0
+	for _, f := range ui.geofences {
+		for _, polygon := range f.Polygons {
+			for _, ring := range polygon {
+				for _, p := range ring {
+					x, y, err := ui.slippymap.LatLongToPixelUnbounded(p.Lat, p.Long)
+					if err != nil {
+						return bounds, err
+					}
+					bounds = bounds.Union(image.Rect(int(x-originX), int(y-originY), int(x-originX)+1, int(y-originY)+1))
+				}
+			}
+		}
+	}
+	return bounds.Inset(-GEOFENCE_LINE_WIDTH), nil
+}
+
+func (ui *UserInterface) drawGeofences(screen *ebiten.Image, windowW, windowH int) {
+	// draws geofences as translucent polygons
+	if len(ui.geofences) == 0 {
+		return
+	}
+
+	// the fences are rendered relative to lat/long 0,0, so only need re-rendering when the map zooms
+	originX, originY, err := ui.slippymap.LatLongToPixelUnbounded(0, 0)
+	if err != nil {
+		return
+	}
+	state := ui.geofenceImgState
+	render := state.zoomLevel != ui.slippymap.GetZoomLevel()
+	if render {
+		state.zoomLevel = ui.slippymap.GetZoomLevel()
+		state.bounds, err = ui.geofenceBounds(originX, originY)
+		if err != nil {
+			return
+		}
+	}
+
+	// fences too big to render whole are rendered around the window, & re-rendered when it moves off that area
+	window := image.Rect(0, 0, windowW, windowH).Sub(image.Pt(int(originX), int(originY)))
+	if !render && state.rendered != state.bounds && !window.Intersect(state.bounds).In(state.rendered) {
+		render = true
+	}
+
+	if render {
+		state.rendered = state.bounds
+		if state.rendered.Dx() > GEOFENCE_IMG_MAX_PX || state.rendered.Dy() > GEOFENCE_IMG_MAX_PX {
+			centre := window.Min.Add(window.Size().Div(2))
+			half := image.Pt(GEOFENCE_IMG_MAX_PX/2, GEOFENCE_IMG_MAX_PX/2)
+			state.rendered = image.Rectangle{Min: centre.Sub(half), Max: centre.Add(half)}.Intersect(state.bounds)
+		}
+		if ui.geofenceImg != nil {
+			ui.geofenceImg.Dispose()
+			ui.geofenceImg = nil
+		}
+		ui.geofenceImgState = state
+		if state.rendered.Empty() {
+			// none of the fences are near the window
+			return
+		}
+
+		// fence points relative to the rendering
+		dx := -originX - float64(state.rendered.Min.X)
+		dy := -originY - float64(state.rendered.Min.Y)
+
+		dc := gg.NewContext(state.rendered.Dx(), state.rendered.Dy())
+		dc.SetFillRuleEvenOdd()
+		dc.SetLineWidth(GEOFENCE_LINE_WIDTH)
+		for _, f := range ui.geofences {
+			for _, polygon := range f.Polygons {
+				for _, ring := range polygon {
+					for i, p := range ring {
+						x, y, err := ui.slippymap.LatLongToPixelUnbounded(p.Lat, p.Long)
+						if err != nil {
+							return
+						}
+						if i == 0 {
+							dc.MoveTo(x+dx, y+dy)
+						} else {
+							dc.LineTo(x+dx, y+dy)
+						}
+					}
+					dc.ClosePath()
+				}
+				dc.SetColor(color.RGBA{R: 255, G: 165, B: 0, A: 48})
+				dc.FillPreserve()
+				dc.SetColor(color.RGBA{R: 255, G: 165, B: 0, A: 200})
+				dc.Stroke()
+			}
+		}
+		ui.geofenceImg = ebiten.NewImageFromImage(dc.Image())
+	}
+	if ui.geofenceImg == nil {
+		return
+	}
+
+	// move the rendering to where lat/long 0,0 is on the screen
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(originX+float64(ui.geofenceImgState.rendered.Min.X), originY+float64(ui.geofenceImgState.rendered.Min.Y))
+	screen.DrawImage(ui.geofenceImg, op)
+}
+
+func (ui *UserInterface) drawConflicts(screen *ebiten.Image, conflicts []proximity.Conflict, aircraftMap map[int]datasources.Aircraft) {
+	// draws a line between pairs of aircraft predicted to lose separation, labelled with the time to closest approach
+	for _, c := range conflicts {
+		a, b := aircraftMap[c.A], aircraftMap[c.B]
+		ax, ay, err := ui.slippymap.LatLongToPixelUnbounded(a.Lat, a.Long)
+		if err != nil {
+			return
+		}
+		bx, by, err := ui.slippymap.LatLongToPixelUnbounded(b.Lat, b.Long)
+		if err != nil {
+			return
+		}
+		ebitenutil.DrawLine(screen, ax, ay, bx, by, color.RGBA{R: 255, G: 0, B: 0, A: 255})
+
+		label := "CPA now"
+		if c.TimeToCPA > 0 {
+			label = fmt.Sprintf("CPA %d:%02d", int(c.TimeToCPA.Minutes()), int(c.TimeToCPA.Seconds())%60)
+		}
+		label = fmt.Sprintf("%s %.1fnm %dft", label, c.LateralCPA/proximity.METRES_PER_NAUTICAL_MILE, c.VerticalCPA)
+		ebitenutil.DebugPrintAt(screen, label, int((ax+bx)/2)+5, int((ay+by)/2))
+	}
+}
+
+func (ui *UserInterface) debugOverlayHeight() int {
+	// returns the height of the darkened area behind the debug text
+	return 115 + (15 * len(ui.dataSources))
+}
+
+func (ui *UserInterface) drawDataSourceStatus(screen *ebiten.Image, x, y int) {
+	// draws one line per data source showing its health
+	for _, ds := range ui.dataSources {
+		h := ds.Health()
+		statusText := fmt.Sprintf("Data source: %s: %s", ds.Name(), h.Status)
+		if h.Status == datasources.DATASOURCE_FAILED && h.LastError != nil {
+			statusText = fmt.Sprintf("%s (%s)", statusText, h.LastError)
+		}
+		ebitenutil.DebugPrintAt(screen, statusText, x, y)
+		y += 15
+	}
+}
+
 func (ui *UserInterface) debugDrawMarkers(screen *ebiten.Image, windowW, windowH int) {
 
 	var markerTypes []string
@@ -432,6 +748,9 @@ func (ui *UserInterface) Draw(screen *ebiten.Image) {
 		// draw map
 		ui.slippymap.Draw(screen, ui.debugShowMapTileXYZ)
 
+		// draw geofences, under the aircraft
+		ui.drawGeofences(screen, windowW, windowH)
+
 		// draw aircraft
 		mouseOverMarkerText := ui.drawAircraftMarkers(screen, mouseX, mouseY)
 
@@ -442,13 +761,20 @@ func (ui *UserInterface) Draw(screen *ebiten.Image) {
 		screen.DrawImage(ui.altitudeScale.Img, altitudeScaleDio)
 		// fmt.Println("======== End Draw Alt Scale ========")
 
-		// draw OpenStreetMap attribution at the bottom right of map
-		mapAttributionDio := &ebiten.DrawImageOptions{}
-		mapAttributionDio.GeoM.Translate(float64(windowW)-float64(attribution.MapAttribution.Img.Bounds().Dx()), float64(windowH)-float64(attribution.MapAttribution.Img.Bounds().Dy()))
-		screen.DrawImage(attribution.MapAttribution.Img, mapAttributionDio)
+		// draw tile provider attribution at the bottom right of map
+		if attribution.MapAttribution.Img != nil {
+			mapAttributionDio := &ebiten.DrawImageOptions{}
+			mapAttributionDio.GeoM.Translate(float64(windowW)-float64(attribution.MapAttribution.Img.Bounds().Dx()), float64(windowH)-float64(attribution.MapAttribution.Img.Bounds().Dy()))
+			screen.DrawImage(attribution.MapAttribution.Img, mapAttributionDio)
+		}
+
+		// draw replay timeline
+		if ui.timeline != nil {
+			ui.timeline.Draw(screen)
+		}
 
 		// debugging: darken area with debug text
-		darkArea := ebiten.NewImage(windowW, 115)
+		darkArea := ebiten.NewImage(windowW, ui.debugOverlayHeight())
 		darkArea.Fill(color.Black)
 		darkAreaDio := &ebiten.DrawImageOptions{}
 		darkAreaDio.ColorM.Scale(1, 1, 1, 0.65)
@@ -483,14 +809,21 @@ func (ui *UserInterface) Draw(screen *ebiten.Image) {
 		}
 		ebitenutil.DebugPrintAt(screen, dbgMouseLatLongText, 0, 60)
 
-		// debugging: show number of tiles
-		dbgNumTilesText := fmt.Sprintf("Tiles rendered: %d", ui.slippymap.GetNumTiles())
+		// debugging: show number of tiles, and those still loading or failed
+		tilesPending, tilesFailed := ui.slippymap.GetTileCounts()
+		dbgNumTilesText := fmt.Sprintf("Tiles rendered: %d (pending: %d, failed: %d)", ui.slippymap.GetNumTiles(), tilesPending, tilesFailed)
 		ebitenutil.DebugPrintAt(screen, dbgNumTilesText, 0, 75)
 
 		// debugging: show number of tiles
 		dbgMouseOverMarkerText := fmt.Sprintf("Mouse over marker: %s", mouseOverMarkerText)
 		ebitenutil.DebugPrintAt(screen, dbgMouseOverMarkerText, 0, 90)
 
+		// show data source health
+		ui.drawDataSourceStatus(screen, 0, 105)
+
+		// draw notifications
+		ui.toasts.Draw(screen)
+
 	case STATE_DEBUG_MARKERS_STARTUP:
 		// debug mode: draw all the markers for testing and adjusting scale
 
@@ -536,19 +869,103 @@ func failFatally(err error) {
 }
 
 type runtimeConfiguration struct {
-	readsbProtobufUrl   string
+	dataSources         *sourceflags.Flags
+	recordPath          string
+	historyRetention    datasources.HistoryRetention
+	geofencePaths       []string
+	geofenceWebhook     string
+	proximityMinima     proximity.Minima
+	deadReckoningMaxAge time.Duration
+	militaryFilter      string
+	tiles               string            // tile provider name, or URL template
+	tileAPIKey          string            // overrides the tile provider's API key
+	tileHeaders         map[string]string // added to the tile provider's HTTP headers
+	tileAttribution     string            // overrides the tile provider's attribution
 	initalState         int
 	debugShowMapTileXYZ bool
 }
 
+func initTileProvider(conf runtimeConfiguration) (tileProvider slippymap.TileProvider, initialView mapView, err error) {
+	// returns the tile provider chosen with --tiles, and where the map should start
+	initialView = mapView{lat: INIT_CENTRE_LAT, long: INIT_CENTRE_LONG, zoomLevel: INIT_ZOOM_LEVEL}
+
+	// offline tiles, start where the file has tiles
+	if strings.HasSuffix(conf.tiles, ".mbtiles") {
+		mbt, err := slippymap.OpenMBTiles(conf.tiles)
+		if err != nil {
+			return nil, initialView, err
+		}
+		if lat, long, zoomLevel, ok := mbt.Centre(); ok {
+			initialView = mapView{lat: lat, long: long, zoomLevel: zoomLevel}
+		}
+		minZoom, maxZoom := slippymap.TileProviderZoomRange(mbt)
+		if initialView.zoomLevel < minZoom {
+			initialView.zoomLevel = minZoom
+		}
+		if initialView.zoomLevel > maxZoom {
+			initialView.zoomLevel = maxZoom
+		}
+		log.Printf("Map tiles from %s: %s, zoom %d-%d", conf.tiles, mbt.Metadata("name"), minZoom, maxZoom)
+		return slippymap.NewMemoryTileProvider(mbt, slippymap.MEMORY_TILE_CACHE_SIZE), initialView, nil
+	}
+
+	// offline tiles, from files
+	if strings.Contains(conf.tiles, "{z}") && !strings.Contains(conf.tiles, "://") {
+		log.Printf("Map tiles from %s", conf.tiles)
+		return slippymap.NewMemoryTileProvider(slippymap.NewFileTileProvider(conf.tiles), slippymap.MEMORY_TILE_CACHE_SIZE), initialView, nil
+	}
+
+	templateTileProvider, err := slippymap.GetTileProvider(conf.tiles)
+	if err != nil {
+		return nil, initialView, err
+	}
+	if conf.tileAPIKey != "" {
+		templateTileProvider.APIKey = conf.tileAPIKey
+	}
+	for k, v := range conf.tileHeaders {
+		templateTileProvider.Headers[k] = v
+	}
+	tileProvider, err = slippymap.TileProviderForOS(templateTileProvider, slippymap.TileCacheName(conf.tiles))
+	return tileProvider, initialView, err
+}
+
 func processCommandLine() runtimeConfiguration {
 	// process the command line
 
 	// create new parser object
 	parser := argparse.NewParser("pw-slippymap", "front-end for plane.watch")
 
-	// readsb-protobuf aircraft.pb URL
-	readsbProtobufUrl := parser.String("", "aircraftpburl", &argparse.Options{Required: false, Help: "Uses readsb-protobuf web interface as a data source. Eg: 'http://1.2.3.4/'"})
+	// data sources
+	dataSources := sourceflags.Add(parser)
+
+	// record updates, for --replay
+	recordPath := parser.String("", "record", &argparse.Options{Required: false, Help: "Records updates from readsb data sources to a file, for later replay"})
+
+	// track history
+	historyMaxAge := parser.Int("", "historymaxage", &argparse.Options{Required: false, Help: "Seconds of track history to keep for each aircraft, 0 for no limit", Default: datasources.HISTORY_MAX_AGE_SECONDS})
+	historyMaxPoints := parser.Int("", "historymaxpoints", &argparse.Options{Required: false, Help: "Number of track history points to keep for each aircraft before decimating, 0 for no limit", Default: datasources.HISTORY_MAX_POINTS})
+	historyTolerance := parser.Float("", "historytolerance", &argparse.Options{Required: false, Help: "Metres a point can be from a decimated track before it is kept, 0 to disable decimation", Default: float64(datasources.HISTORY_DECIMATE_TOLERANCE_M)})
+
+	// geofences
+	geofencePaths := parser.StringList("", "geofence", &argparse.Options{Required: false, Help: "Alerts when aircraft enter or leave the Polygon/MultiPolygon features in a GeoJSON file. Features can have 'name', 'floor' & 'ceiling' (feet) properties. Can be given multiple times."})
+	geofenceWebhook := parser.String("", "geofencewebhook", &argparse.Options{Required: false, Help: "POSTs geofence alerts as JSON to this URL"})
+
+	// proximity / loss of separation
+	proximityLateral := parser.Float("", "proximitylateral", &argparse.Options{Required: false, Help: "Flags aircraft predicted to come within this many nautical miles of each other, 0 to disable", Default: proximity.PROXIMITY_LATERAL_NM})
+	proximityVertical := parser.Int("", "proximityvertical", &argparse.Options{Required: false, Help: "Flags aircraft predicted to come within this many feet vertically of each other", Default: proximity.PROXIMITY_VERTICAL_FT})
+	proximityLookahead := parser.Int("", "proximitylookahead", &argparse.Options{Required: false, Help: "Seconds ahead to predict aircraft coming too close", Default: proximity.PROXIMITY_LOOKAHEAD_SECONDS})
+
+	// dead reckoning
+	deadReckoningMaxAge := parser.Int("", "deadreckoningmaxage", &argparse.Options{Required: false, Help: "Seconds to extrapolate aircraft positions past their last report, so markers move smoothly, 0 to disable", Default: deadreckoning.DEAD_RECKONING_MAX_AGE_SECONDS})
+
+	// map tiles
+	tiles := parser.String("", "tiles", &argparse.Options{Required: false, Help: "Map tile provider: one of " + strings.Join(slippymap.TileProviderNames(), ", ") + ", a URL template, or a path template or MBTiles file for offline use. Eg: 'https://{s}.example.com/{z}/{x}/{y}.png', '/data/tiles/{z}/{x}/{y}.png' or 'perth.mbtiles'", Default: slippymap.DEFAULT_TILE_PROVIDER})
+	tileAPIKey := parser.String("", "tileapikey", &argparse.Options{Required: false, Help: "API key for the map tile provider, replaces {apikey} in the URL template"})
+	tileHeaders := parser.StringList("", "tileheader", &argparse.Options{Required: false, Help: "HTTP header to send when downloading map tiles. Eg: 'Authorization: Bearer xyz'. Can be given multiple times."})
+	tileAttribution := parser.String("", "tileattribution", &argparse.Options{Required: false, Help: "Credit to show on the map for the map tiles, overrides the tile provider's"})
+
+	// military aircraft
+	militaryFilter := parser.Selector("", "military", []string{MILITARY_SHOW, MILITARY_HIDE, MILITARY_HIGHLIGHT}, &argparse.Options{Required: false, Help: "Shows, hides or highlights aircraft with military ICAO addresses", Default: MILITARY_SHOW})
 
 	// debug options
 	debugDrawMarkers := parser.Flag("", "debugdrawmarkers", &argparse.Options{Required: false, Help: "Debug mode: show all aircraft markers"})
@@ -566,9 +983,51 @@ func processCommandLine() runtimeConfiguration {
 	// Prepare runtime
 	conf := runtimeConfiguration{}
 
-	// if --aircraftpburl set, add to runtime conf
-	if *readsbProtobufUrl != "" {
-		conf.readsbProtobufUrl = *readsbProtobufUrl
+	conf.dataSources = dataSources
+
+	// if --record set, add to runtime conf
+	conf.recordPath = *recordPath
+
+	// track history retention
+	conf.historyRetention = datasources.HistoryRetention{
+		MaxAge:            time.Second * time.Duration(*historyMaxAge),
+		MaxPoints:         *historyMaxPoints,
+		DecimateTolerance: *historyTolerance,
+	}
+
+	// if --geofence set, add to runtime conf
+	for _, p := range *geofencePaths {
+		if p != "" {
+			conf.geofencePaths = append(conf.geofencePaths, p)
+		}
+	}
+	conf.geofenceWebhook = *geofenceWebhook
+
+	// separation minima
+	conf.proximityMinima = proximity.Minima{
+		Lateral:   *proximityLateral * proximity.METRES_PER_NAUTICAL_MILE,
+		Vertical:  *proximityVertical,
+		Lookahead: time.Second * time.Duration(*proximityLookahead),
+	}
+
+	conf.deadReckoningMaxAge = time.Second * time.Duration(*deadReckoningMaxAge)
+	conf.militaryFilter = *militaryFilter
+
+	// map tiles
+	conf.tiles = *tiles
+	conf.tileAPIKey = *tileAPIKey
+	conf.tileAttribution = *tileAttribution
+	conf.tileHeaders = make(map[string]string)
+	for _, h := range *tileHeaders {
+		if h == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(h, ":")
+		if !ok {
+			fmt.Print(parser.Usage(fmt.Sprintf("invalid --tileheader %q, expected 'Name: value'", h)))
+			os.Exit(1)
+		}
+		conf.tileHeaders[strings.TrimSpace(k)] = strings.TrimSpace(v)
 	}
 
 	if *debugDrawMarkers {
@@ -596,6 +1055,11 @@ func main() {
 
 	// init aircraftdb
 	adb := datasources.NewAircraftDB(60)
+	adb.SetHistoryRetention(conf.historyRetention)
+	adb.SetOnChange(ebiten.ScheduleFrame)
+
+	// load the aircraft registration & type database in the background, as it may need downloading
+	go conf.dataSources.LoadAircraftDB(context.Background(), adb)
 
 	// determine starting window size
 	// 80% of fullscreen
@@ -608,24 +1072,91 @@ func main() {
 	ebiten.SetWindowSize(windowWidth, windowHeight)
 	ebiten.SetWindowTitle("plane.watch")
 
-	tileProvider, err := slippymap.TileProviderForOS()
+	// map tiles, credited at the bottom right of the map
+	tileProvider, initialView, err := initTileProvider(conf)
 	if err != nil {
 		log.Fatal("could not initilalise tile provider because: ", err.Error())
 	}
 
-	// if readsb aircraft.db datasource has been specified, initialise it
-	if conf.readsbProtobufUrl != "" && conf.initalState == STATE_STARTUP {
-		log.Printf("Datasource: readsb-protobuf at url: %s", conf.readsbProtobufUrl)
-		go datasources.ReadsbProtobufAircraft(conf.readsbProtobufUrl, adb)
+	// zoom past the tile provider's maximum zoom level by scaling up its tiles
+	tileProvider = slippymap.NewOverzoomTileProvider(tileProvider)
+	attributionText := slippymap.TileProviderAttribution(tileProvider)
+	if conf.tileAttribution != "" {
+		attributionText = conf.tileAttribution
+	}
+	attribution.MapAttribution.SetText(attributionText)
+
+	// if recording, open the recording file
+	var recorder *recording.Recorder
+	if conf.recordPath != "" {
+		recorder, err = recording.Create(conf.recordPath)
+		if err != nil {
+			log.Fatalf("could not create recording %s because: %s", conf.recordPath, err.Error())
+		}
+		log.Printf("Recording readsb updates to: %s", conf.recordPath)
+	}
+
+	// if readsb datasources have been specified, initialise them
+	var dataSources []datasources.DataSource
+	var replayTimeline *timeline.Timeline
+	if conf.initalState == STATE_STARTUP {
+		var replay *datasources.ReplaySource
+		dataSources, replay, err = conf.dataSources.DataSources(adb, recorder)
+		if err != nil {
+			log.Fatalf("could not initialise datasources because: %s", err.Error())
+		}
+		if replay != nil {
+			replayTimeline = timeline.NewTimeline(replay)
+		}
+	}
+
+	// start datasources
+	for _, ds := range dataSources {
+		err = ds.Start(context.Background())
+		if err != nil {
+			log.Fatalf("could not start datasource %s because: %s", ds.Name(), err.Error())
+		}
+	}
+
+	// load geofences
+	var fences []*geofence.Fence
+	for _, p := range conf.geofencePaths {
+		f, err := geofence.Load(p)
+		if err != nil {
+			log.Fatalf("could not load geofences from %s because: %s", p, err.Error())
+		}
+		log.Printf("Geofences: loaded %d from: %s", len(f), p)
+		fences = append(fences, f...)
+	}
+
+	// watch geofences, alerting to the log, the screen & optionally a webhook
+	toasts := &toast.Toasts{}
+	if len(fences) > 0 {
+		notifiers := []geofence.Notifier{
+			geofence.LogNotifier{},
+			geofence.NotifierFunc(func(a geofence.Alert) { toasts.Add(a.String()) }),
+		}
+		if conf.geofenceWebhook != "" {
+			notifiers = append(notifiers, geofence.NewWebhookNotifier(conf.geofenceWebhook))
+		}
+		go geofence.NewMonitor(fences, notifiers...).Run(context.Background(), adb)
 	}
 
 	// prepare "game"
 	ui := &UserInterface{
 		aircraftDb:          adb,
+		dataSources:         dataSources,
 		strokes:             map[*userinput.Stroke]struct{}{},
 		tileProvider:        &tileProvider,
+		initialView:         initialView,
 		state:               conf.initalState,
 		debugShowMapTileXYZ: conf.debugShowMapTileXYZ,
+		timeline:            replayTimeline,
+		geofences:           fences,
+		toasts:              toasts,
+		proximityMinima:     conf.proximityMinima,
+		deadReckoning:       deadreckoning.NewTracker(conf.deadReckoningMaxAge),
+		militaryFilter:      conf.militaryFilter,
 	}
 
 	// In FPSModeVsyncOffMinimum, the game's Update and Draw are called only when
@@ -639,12 +1170,18 @@ func main() {
 	}
 
 	// run
-	defer endProgram()
+	defer endProgram(dataSources, recorder)
 	if err := ebiten.RunGame(ui); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func endProgram() {
+func endProgram(dataSources []datasources.DataSource, recorder *recording.Recorder) {
 	log.Println("Quitting")
+	for _, ds := range dataSources {
+		ds.Stop()
+	}
+	if recorder != nil {
+		recorder.Close()
+	}
 }
@@ -0,0 +1,211 @@
+package proximity
+
+// Proximity finds pairs of airborne aircraft predicted to lose separation, by computing each pair's
+// closest point of approach (CPA) from their positions, altitudes & velocities.
+//
+// To avoid comparing every aircraft with every other aircraft, aircraft are bucketed into a grid of
+// cells as big as the furthest two aircraft can close on each other in the lookahead time. Only
+// aircraft in the same or neighbouring cells can conflict.
+
+import (
+	"math"
+	"pw_slippymap/datasources"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"sort"
+	"time"
+)
+
+const (
+	PROXIMITY_LATERAL_NM               = 3.0  // default lateral minimum, nautical miles
+	PROXIMITY_VERTICAL_FT              = 1000 // default vertical minimum, feet
+	PROXIMITY_LOOKAHEAD_SECONDS        = 120  // default time to look ahead for conflicts
+	PROXIMITY_MAX_POSITION_AGE_SECONDS = 30   // positions older than this (relative to the newest position) are ignored
+	METRES_PER_NAUTICAL_MILE           = 1852
+	METRES_PER_SECOND_PER_KNOT         = METRES_PER_NAUTICAL_MILE / 3600.0
+	DEGREES_TO_RADIANS                 = math.Pi / 180
+)
+
+// Minima is the separation aircraft are expected to keep
+type Minima struct {
+	Lateral   float64       // metres, 0 to disable proximity detection
+	Vertical  int           // feet
+	Lookahead time.Duration // how far ahead to predict
+}
+
+func DefaultMinima() Minima {
+	// Returns the default Minima
+	return Minima{
+		Lateral:   PROXIMITY_LATERAL_NM * METRES_PER_NAUTICAL_MILE,
+		Vertical:  PROXIMITY_VERTICAL_FT,
+		Lookahead: time.Second * PROXIMITY_LOOKAHEAD_SECONDS,
+	}
+}
+
+// Conflict is a pair of aircraft predicted to be closer than the minima
+type Conflict struct {
+	A, B        int           // ICAO addresses, A < B
+	TimeToCPA   time.Duration // 0 if the aircraft are already inside the minima and diverging
+	LateralCPA  float64       // metres between the aircraft at CPA
+	VerticalCPA int           // feet between the aircraft at CPA
+}
+
+// track is an aircraft's position & velocity
+type track struct {
+	icao      int
+	lat, long float64 // degrees
+	vx, vy    float64 // metres/second, east & north
+	alt       float64 // feet
+	vrate     float64 // feet/second
+}
+
+// cell is a grid square
+type cell struct {
+	x, y int
+}
+
+// neighbouring cells that haven't already been compared with a cell, when walking the grid
+var forwardNeighbours = []cell{{1, 0}, {1, 1}, {0, 1}, {-1, 1}}
+
+func eligible(a datasources.Aircraft) bool {
+	// returns true if the aircraft is airborne with a known position
+	if a.Lat == 0 && a.Long == 0 {
+		return false
+	}
+	if a.PositionSource.Time.IsZero() {
+		return false
+	}
+	if a.AirGround == readsb_protobuf.AircraftMeta_AG_GROUND {
+		return false
+	}
+	// ground vehicles (https://wiki.jetvision.de/wiki/Radarcape:Software_Features#Aircraft_categories)
+	if a.Category == 0xC1 || a.Category == 0xC2 {
+		return false
+	}
+	return true
+}
+
+func tracks(aircraft map[int]datasources.Aircraft) (ts []track, maxSpeed float64) {
+	// returns the tracks of eligible aircraft, extrapolated to the time of the newest position, and the
+	// fastest ground speed in metres/second
+
+	// newest position
+	var newest time.Time
+	for _, a := range aircraft {
+		if eligible(a) && a.PositionSource.Time.After(newest) {
+			newest = a.PositionSource.Time
+		}
+	}
+
+	for icao, a := range aircraft {
+		if !eligible(a) {
+			continue
+		}
+		age := newest.Sub(a.PositionSource.Time).Seconds()
+		if age > PROXIMITY_MAX_POSITION_AGE_SECONDS {
+			continue
+		}
+		speed := float64(a.GroundSpeed) * METRES_PER_SECOND_PER_KNOT
+		heading := float64(a.Track) * DEGREES_TO_RADIANS
+		t := track{
+			icao:  icao,
+			vx:    speed * math.Sin(heading),
+			vy:    speed * math.Cos(heading),
+			vrate: float64(a.Meta.VerticalRate()) / 60,
+		}
+		t.lat = a.Lat + (t.vy*age)/datasources.EARTH_RADIUS_METRES/DEGREES_TO_RADIANS
+		t.long = a.Long + (t.vx*age)/(datasources.EARTH_RADIUS_METRES*math.Cos(a.Lat*DEGREES_TO_RADIANS))/DEGREES_TO_RADIANS
+		t.alt = float64(a.AltBaro) + (t.vrate * age)
+		ts = append(ts, t)
+		maxSpeed = math.Max(maxSpeed, speed)
+	}
+	return ts, maxSpeed
+}
+
+func cpa(a, b track, m Minima) (c Conflict, conflict bool) {
+	// returns the closest point of approach of a & b within the lookahead, and whether it's inside the minima
+
+	// relative position (metres, flat projection at the pair's mean latitude) & velocity of b from a
+	cosLat := math.Cos((a.lat + b.lat) / 2 * DEGREES_TO_RADIANS)
+	px := (b.long - a.long) * DEGREES_TO_RADIANS * datasources.EARTH_RADIUS_METRES * cosLat
+	py := (b.lat - a.lat) * DEGREES_TO_RADIANS * datasources.EARTH_RADIUS_METRES
+	vx, vy := b.vx-a.vx, b.vy-a.vy
+
+	// time that minimises |p + v*t|
+	t := 0.0
+	if v2 := vx*vx + vy*vy; v2 > 0 {
+		t = -(px*vx + py*vy) / v2
+	}
+	t = math.Max(0, math.Min(t, m.Lookahead.Seconds()))
+
+	lateral := math.Hypot(px+vx*t, py+vy*t)
+	vertical := math.Abs((b.alt + b.vrate*t) - (a.alt + a.vrate*t))
+
+	c = Conflict{
+		A:           a.icao,
+		B:           b.icao,
+		TimeToCPA:   time.Duration(t * float64(time.Second)),
+		LateralCPA:  lateral,
+		VerticalCPA: int(math.Round(vertical)),
+	}
+	if c.A > c.B {
+		c.A, c.B = c.B, c.A
+	}
+	return c, lateral < m.Lateral && vertical < float64(m.Vertical)
+}
+
+func Detect(aircraft map[int]datasources.Aircraft, m Minima) []Conflict {
+	// Returns the pairs of aircraft predicted to be inside the minima within the lookahead, soonest first
+	if m.Lateral <= 0 {
+		return nil
+	}
+	ts, maxSpeed := tracks(aircraft)
+
+	// bucket into cells big enough that conflicting aircraft are in the same or neighbouring cells
+	// longitude is scaled by the highest latitude, so distances between cells are never more than
+	// the true distance, which keeps the grid from missing pairs
+	maxAbsLat := 0.0
+	for _, t := range ts {
+		maxAbsLat = math.Max(maxAbsLat, math.Abs(t.lat))
+	}
+	cosLat := math.Cos(maxAbsLat * DEGREES_TO_RADIANS)
+	cellSize := m.Lateral + (2 * maxSpeed * m.Lookahead.Seconds())
+	grid := make(map[cell][]track)
+	for _, t := range ts {
+		x := t.long * DEGREES_TO_RADIANS * datasources.EARTH_RADIUS_METRES * cosLat
+		y := t.lat * DEGREES_TO_RADIANS * datasources.EARTH_RADIUS_METRES
+		c := cell{x: int(math.Floor(x / cellSize)), y: int(math.Floor(y / cellSize))}
+		grid[c] = append(grid[c], t)
+	}
+
+	var conflicts []Conflict
+	check := func(a, b track) {
+		if c, conflict := cpa(a, b, m); conflict {
+			conflicts = append(conflicts, c)
+		}
+	}
+	for c, cellTracks := range grid {
+		for i, a := range cellTracks {
+			// same cell
+			for _, b := range cellTracks[i+1:] {
+				check(a, b)
+			}
+			// neighbouring cells
+			for _, n := range forwardNeighbours {
+				for _, b := range grid[cell{x: c.x + n.x, y: c.y + n.y}] {
+					check(a, b)
+				}
+			}
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].TimeToCPA != conflicts[j].TimeToCPA {
+			return conflicts[i].TimeToCPA < conflicts[j].TimeToCPA
+		}
+		if conflicts[i].A != conflicts[j].A {
+			return conflicts[i].A < conflicts[j].A
+		}
+		return conflicts[i].B < conflicts[j].B
+	})
+	return conflicts
+}
@@ -0,0 +1,136 @@
+package proximity
+
+import (
+	"math/rand"
+	"pw_slippymap/datasources"
+	"pw_slippymap/datasources/readsb_protobuf"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAircraft(lat, long float64, alt, track, gs int, t time.Time) datasources.Aircraft {
+	// returns an airborne aircraft
+	return datasources.Aircraft{
+		Lat:            lat,
+		Long:           long,
+		AltBaro:        alt,
+		Track:          track,
+		GroundSpeed:    gs,
+		AirGround:      readsb_protobuf.AircraftMeta_AG_AIRBORNE,
+		PositionSource: datasources.FieldSource{Source: "test", Time: t},
+	}
+}
+
+func TestDetect(t *testing.T) {
+
+	now := time.Now()
+
+	t.Run("Test head on", func(t *testing.T) {
+		// 0.1 degrees of latitude apart (~11.1 km), closing at 400 kt (~206 m/s): CPA in ~54s
+		conflicts := Detect(map[int]datasources.Aircraft{
+			0x7C79CA: testAircraft(-32.0, 115.9, 5000, 0, 200, now),
+			0x7C6DD8: testAircraft(-31.9, 115.9, 5200, 180, 200, now),
+		}, DefaultMinima())
+		require.Len(t, conflicts, 1)
+		c := conflicts[0]
+		assert.Equal(t, 0x7C6DD8, c.A)
+		assert.Equal(t, 0x7C79CA, c.B)
+		assert.InDelta(t, 54, c.TimeToCPA.Seconds(), 1)
+		assert.InDelta(t, 0, c.LateralCPA, 1)
+		assert.Equal(t, 200, c.VerticalCPA)
+	})
+
+	t.Run("Test vertically separated", func(t *testing.T) {
+		assert.Empty(t, Detect(map[int]datasources.Aircraft{
+			0x7C79CA: testAircraft(-32.0, 115.9, 5000, 0, 200, now),
+			0x7C6DD8: testAircraft(-31.9, 115.9, 7000, 180, 200, now),
+		}, DefaultMinima()))
+	})
+
+	t.Run("Test climbing into conflict", func(t *testing.T) {
+		a := testAircraft(-32.0, 115.9, 5000, 0, 200, now)
+		b := testAircraft(-31.9, 115.9, 3000, 180, 200, now)
+		b.Meta.BaroRate = 2000
+		conflicts := Detect(map[int]datasources.Aircraft{0x7C79CA: a, 0x7C6DD8: b}, DefaultMinima())
+		require.Len(t, conflicts, 1)
+		assert.InDelta(t, 200, conflicts[0].VerticalCPA, 40)
+	})
+
+	t.Run("Test beyond lookahead", func(t *testing.T) {
+		// ~111 km apart, CPA in ~9 minutes
+		assert.Empty(t, Detect(map[int]datasources.Aircraft{
+			0x7C79CA: testAircraft(-32.0, 115.9, 5000, 0, 200, now),
+			0x7C6DD8: testAircraft(-31.0, 115.9, 5000, 180, 200, now),
+		}, DefaultMinima()))
+	})
+
+	t.Run("Test diverging inside minima", func(t *testing.T) {
+		conflicts := Detect(map[int]datasources.Aircraft{
+			0x7C79CA: testAircraft(-32.0, 115.9, 5000, 180, 200, now),
+			0x7C6DD8: testAircraft(-31.99, 115.9, 5000, 0, 200, now),
+		}, DefaultMinima())
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, time.Duration(0), conflicts[0].TimeToCPA)
+	})
+
+	t.Run("Test ground & stale aircraft ignored", func(t *testing.T) {
+		ground := testAircraft(-31.9, 115.9, 0, 180, 200, now)
+		ground.AirGround = readsb_protobuf.AircraftMeta_AG_GROUND
+		stale := testAircraft(-31.9, 115.9, 5000, 180, 200, now.Add(-time.Minute))
+		assert.Empty(t, Detect(map[int]datasources.Aircraft{
+			0x7C79CA: testAircraft(-32.0, 115.9, 5000, 0, 200, now),
+			0x7C6DD8: ground,
+			0x7C6DD9: stale,
+		}, DefaultMinima()))
+	})
+
+	t.Run("Test older position extrapolated", func(t *testing.T) {
+		// the second aircraft's position is 20s old, it has flown ~2 km towards the first since
+		conflicts := Detect(map[int]datasources.Aircraft{
+			0x7C79CA: testAircraft(-32.0, 115.9, 5000, 0, 200, now),
+			0x7C6DD8: testAircraft(-31.9, 115.9, 5000, 180, 200, now.Add(-time.Second*20)),
+		}, DefaultMinima())
+		require.Len(t, conflicts, 1)
+		assert.InDelta(t, 44, conflicts[0].TimeToCPA.Seconds(), 1)
+	})
+
+	t.Run("Test disabled", func(t *testing.T) {
+		assert.Empty(t, Detect(map[int]datasources.Aircraft{
+			0x7C79CA: testAircraft(-32.0, 115.9, 5000, 180, 200, now),
+			0x7C6DD8: testAircraft(-31.99, 115.9, 5000, 0, 200, now),
+		}, Minima{}))
+	})
+}
+
+func TestDetectMatchesBruteForce(t *testing.T) {
+
+	// a busy feed: the grid must find the same conflicts as comparing every pair
+	now := time.Now()
+	r := rand.New(rand.NewSource(1))
+	aircraft := make(map[int]datasources.Aircraft)
+	for i := 0; i < 500; i++ {
+		aircraft[0x7C0000+i] = testAircraft(
+			-33+r.Float64()*2, 115+r.Float64()*2,
+			r.Intn(10)*1000, r.Intn(360), 100+r.Intn(400),
+			now.Add(-time.Duration(r.Intn(10))*time.Second),
+		)
+	}
+	m := DefaultMinima()
+
+	ts, _ := tracks(aircraft)
+	expected := 0
+	for i := range ts {
+		for j := i + 1; j < len(ts); j++ {
+			if _, conflict := cpa(ts[i], ts[j], m); conflict {
+				expected++
+			}
+		}
+	}
+
+	conflicts := Detect(aircraft, m)
+	assert.Positive(t, expected)
+	assert.Len(t, conflicts, expected)
+}
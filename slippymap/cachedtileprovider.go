@@ -1,113 +1,106 @@
 package slippymap
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"image"
+	"image/png"
 	"os"
 	"path"
 )
 
-// TileProvider generates URLs (either https:// or file://) to the tile for the coord
-type TileProvider interface {
-	GetTileAddress(osm OSMTileID) (tilePath string, err error)
-}
-
 func NewCachedTileProvider(tileCachePath string, tileProvider TileProvider) *CachedTileProvider {
-
+	// Returns a tile provider caching the tiles from tileProvider on disk in tileCachePath
 	return &CachedTileProvider{
-		httpClient: &http.Client{
-			Transport: newTransportWithLimitedConcurrency(),
-		},
 		tileProvider:  tileProvider,
 		tileCachePath: tileCachePath,
 	}
 }
 
-// CachedTileProvider wraps a real TileProvider to cache the contnets of the URLs on disk
+// CachedTileProvider wraps another TileProvider to cache its tiles on disk
 type CachedTileProvider struct {
-	httpClient    *http.Client
 	tileProvider  TileProvider
 	tileCachePath string
 }
 
-func (ctp *CachedTileProvider) GetTileAddress(osm OSMTileID) (tilePath string, err error) {
-	// if the tile at URL is not already cached, download it
-	// return the local path to the tile in cache
-
-	// TODO: this will eventually need refactoring. There's no retry mechanism if there's a failure.
-	// We probably also want to do something with "if-modified-since" if the cached file is older than 7 days.
-	// This is bare minimum to get functionality working
+var _ TileDataProvider = &CachedTileProvider{}
 
-	// determine tile filename
-	tileFile := fmt.Sprintf("%d_%d_%d.png", osm.x, osm.y, osm.zoom)
-
-	// determine full path to tile file
-	tilePath = path.Join(ctp.tileCachePath, tileFile)
-
-	// check if tile exists in cache
-	if _, err := os.Stat(tilePath); errors.Is(err, os.ErrNotExist) {
-		// tile does not exist in cache
+func (ctp *CachedTileProvider) tilePath(osm OSMTileID) string {
+	// returns the path to the tile in cache
+	return path.Join(ctp.tileCachePath, fmt.Sprintf("%d_%d_%d.png", osm.x, osm.y, osm.zoom))
+}
 
-		// determine OSM url
-		url, err := ctp.tileProvider.GetTileAddress(osm)
-		if err != nil {
-			return "", err
-		}
+func (ctp *CachedTileProvider) GetTileData(ctx context.Context, osm OSMTileID) ([]byte, error) {
+	// if the tile is not already cached, get it from the wrapped tile provider & cache it
+	// return the cached tile
 
-		// log.Print("Downloading tile to cache:", url, "to", tilePath)
+	// TODO: We probably want to do something with "if-modified-since" if the cached file is older than 7 days.
 
-		// prepare the request
-		log.Printf("Downloading tile: %s", url)
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return "", err
-		}
+	tilePath := ctp.tilePath(osm)
 
-		// set the header (requirement for using osm)
-		req.Header.Set("User-Agent", "pw_slippymap/0.1 https://github.com/plane-watch/pw-slippymap")
+	// check if tile exists in cache
+	data, err := os.ReadFile(tilePath)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
 
-		// get the data
-		resp, err := ctp.httpClient.Do(req)
+	// tile does not exist in cache, so get it as stored by the wrapped tile provider, or as a PNG
+	if tdp, ok := ctp.tileProvider.(TileDataProvider); ok {
+		data, err = tdp.GetTileData(ctx, osm)
 		if err != nil {
-			return "", err
-		}
-		defer resp.Body.Close()
-
-		// check response code
-		if resp.StatusCode != 200 {
-			errText := fmt.Sprintf("Downloading %s returned: %s", url, resp.Status)
-			return "", errors.New(errText)
+			return nil, err
 		}
-
-		// create the file
-		out, err := os.Create(tilePath)
+	} else {
+		img, err := ctp.tileProvider.GetTile(ctx, osm)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		defer out.Close()
-
-		// write data to file
-		_, err = io.Copy(out, resp.Body)
+		var buf bytes.Buffer
+		err = png.Encode(&buf, img)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+		data = buf.Bytes()
+	}
 
-		return tilePath, nil
-
-	} else {
-		// log.Print("Tile is cached:", tilePath)
-		return tilePath, nil
+	// write to a temporary file & rename, so a partly written tile is never read from the cache
+	tmpPath := tilePath + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return nil, err
 	}
+	err = os.Rename(tmpPath, tilePath)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
-func newTransportWithLimitedConcurrency() http.RoundTripper {
-	t := http.DefaultTransport.(*http.Transport).Clone()
+func (ctp *CachedTileProvider) GetTile(ctx context.Context, osm OSMTileID) (image.Image, error) {
+	// returns the tile osm, from cache if possible
+	data, err := ctp.GetTileData(ctx, osm)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decodeTile(osm, data)
+	if err != nil {
+		// don't keep a bad tile, so it is downloaded again when retried
+		os.Remove(ctp.tilePath(osm))
+	}
+	return img, err
+}
 
-	// We have 3 OSM CDN endpoints that we round robin, so max total conns is 3x this value
-	t.MaxConnsPerHost = 1
+func (ctp *CachedTileProvider) ZoomRange() (min, max int) {
+	// returns the zoom levels of the wrapped tile provider
+	return TileProviderZoomRange(ctp.tileProvider)
+}
 
-	return t
+func (ctp *CachedTileProvider) TileAttribution() string {
+	// returns the credit for the wrapped tile provider's tiles
+	return TileProviderAttribution(ctp.tileProvider)
 }
@@ -1,7 +1,9 @@
 package slippymap
 
 import (
+	"context"
 	"errors"
+	"image/color"
 	"io/ioutil"
 	"os"
 	"path"
@@ -20,10 +22,9 @@ func TestNewCachedTileProvider(t *testing.T) {
 	}
 
 	var (
-		err      error
-		dir      string
-		ctp      TileProvider
-		tilePath string
+		err error
+		dir string
+		ctp TileProvider
 	)
 
 	// create temp dir
@@ -31,9 +32,12 @@ func TestNewCachedTileProvider(t *testing.T) {
 	require.NoError(t, err, "Could not create temp dir")
 	defer os.RemoveAll(dir)
 
+	green := color.RGBA{G: 255, A: 255}
+	server, requests := newTestTileServer(t, encodeTestTile(t, green))
+
 	// get cached tile provider
 	t.Run("Test NewCachedTileProvider", func(t *testing.T) {
-		ctp = NewCachedTileProvider(dir, &OSMTileProvider{})
+		ctp = NewCachedTileProvider(dir, NewHTTPTileProvider(&TemplateTileProvider{URLTemplate: server.URL + "/{z}/{x}/{y}.png", MaxZoom: 19}))
 
 		// request a tile
 		osm := OSMTileID{
@@ -41,17 +45,43 @@ func TestNewCachedTileProvider(t *testing.T) {
 			y:    2,
 			zoom: 3,
 		}
-		tilePath, err = ctp.GetTileAddress(osm)
-		require.NoError(t, err, "Error returned from GetTileAddress")
+		img, err := ctp.GetTile(context.Background(), osm)
+		require.NoError(t, err, "Error returned from GetTile")
+		assertTileColour(t, green, img)
+		<-requests
 
 		// check for success
 		expectedPath := path.Join(dir, "1_2_3.png")
-		assert.Equal(t, expectedPath, tilePath)
+		assert.FileExists(t, expectedPath)
+
+		// second request from cache
+		img, err = ctp.GetTile(context.Background(), osm)
+		require.NoError(t, err, "Error returned from GetTile")
+		assertTileColour(t, green, img)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("Test NewCachedTileProvider image provider", func(t *testing.T) {
+		// tiles from a provider that only returns images are cached as PNGs
+		ctp = NewCachedTileProvider(dir, &countingTileProvider{requests: make(map[OSMTileID]int)})
+		img, err := ctp.GetTile(context.Background(), OSMTileID{x: 4, y: 5, zoom: 6})
+		require.NoError(t, err)
+		assertTileColour(t, color.RGBA{R: 255, A: 255}, img)
+		assert.FileExists(t, path.Join(dir, "4_5_6.png"))
+	})
+
+	t.Run("Test NewCachedTileProvider bad tile", func(t *testing.T) {
+		// a tile that can't be decoded is removed from cache, so it is downloaded again
+		badPath := path.Join(dir, "1_2_3.png")
+		require.NoError(t, os.WriteFile(badPath, []byte("<html>oops</html>"), 0600))
+		_, err := ctp.GetTile(context.Background(), OSMTileID{x: 1, y: 2, zoom: 3})
+		require.Error(t, err)
+		assert.NoFileExists(t, badPath)
 	})
 
 	t.Run("Test NewCachedTileProvider Error Handling", func(t *testing.T) {
 		// get cached tile provider & fake an error
-		ctp = NewCachedTileProvider(dir, &FaultyTileProvider{})
+		ctp = NewCachedTileProvider(dir, NewHTTPTileProvider(&FaultyTileProvider{}))
 
 		// request a tile (should return error)
 		osm := OSMTileID{
@@ -59,8 +89,9 @@ func TestNewCachedTileProvider(t *testing.T) {
 			y:    3,
 			zoom: 4,
 		}
-		tilePath, err = ctp.GetTileAddress(osm)
+		_, err = ctp.GetTile(context.Background(), osm)
 		require.Error(t, err)
+		assert.NoFileExists(t, path.Join(dir, "2_3_4.png"))
 	})
 }
 
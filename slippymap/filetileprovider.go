@@ -0,0 +1,68 @@
+package slippymap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FileTileProvider reads XYZ tiles from files, eg: exported by a tile server or downloaded beforehand,
+// from a path template, eg: "/data/tiles/{z}/{x}/{y}.png"
+type FileTileProvider struct {
+	PathTemplate string
+	MinZoom      int    // lowest zoom level with tiles
+	MaxZoom      int    // highest zoom level with tiles, or 0 for ZOOM_LEVEL_MAX
+	Attribution  string // credit to display on the map
+}
+
+var _ TileDataProvider = &FileTileProvider{}
+
+func NewFileTileProvider(pathTemplate string) *FileTileProvider {
+	// Returns a tile provider reading tiles from the files at pathTemplate
+	return &FileTileProvider{PathTemplate: pathTemplate}
+}
+
+func (ftp *FileTileProvider) tilePath(osm OSMTileID) string {
+	// returns the path of the tile osm
+	return strings.NewReplacer(
+		"{z}", strconv.Itoa(osm.zoom),
+		"{x}", strconv.Itoa(osm.x),
+		"{y}", strconv.Itoa(osm.y),
+	).Replace(ftp.PathTemplate)
+}
+
+func (ftp *FileTileProvider) GetTileData(ctx context.Context, osm OSMTileID) ([]byte, error) {
+	// returns the contents of the tile's file, or ErrTileNotFound if there isn't one
+	tilePath := ftp.tilePath(osm)
+	data, err := os.ReadFile(tilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrTileNotFound, tilePath)
+	}
+	return data, err
+}
+
+func (ftp *FileTileProvider) GetTile(ctx context.Context, osm OSMTileID) (image.Image, error) {
+	// returns the tile osm, read from its file
+	data, err := ftp.GetTileData(ctx, osm)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTile(osm, data)
+}
+
+func (ftp *FileTileProvider) ZoomRange() (min, max int) {
+	// returns the zoom levels with tiles
+	if ftp.MaxZoom == 0 {
+		return ftp.MinZoom, ZOOM_LEVEL_MAX
+	}
+	return ftp.MinZoom, ftp.MaxZoom
+}
+
+func (ftp *FileTileProvider) TileAttribution() string {
+	// returns the credit for the tiles
+	return ftp.Attribution
+}
@@ -0,0 +1,46 @@
+package slippymap
+
+import (
+	"context"
+	"image/color"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTileProvider(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	blue := color.RGBA{B: 255, A: 255}
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "3", "1"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "3", "1", "2.png"), encodeTestTile(t, blue), 0600))
+
+	ftp := NewFileTileProvider(filepath.Join(dir, "{z}", "{x}", "{y}.png"))
+
+	t.Run("Test GetTile", func(t *testing.T) {
+		img, err := ftp.GetTile(context.Background(), OSMTileID{x: 1, y: 2, zoom: 3})
+		require.NoError(t, err)
+		assertTileColour(t, blue, img)
+	})
+
+	t.Run("Test not found", func(t *testing.T) {
+		_, err := ftp.GetTile(context.Background(), OSMTileID{x: 2, y: 2, zoom: 3})
+		assert.ErrorIs(t, err, ErrTileNotFound)
+	})
+
+	t.Run("Test zoom range", func(t *testing.T) {
+		min, max := TileProviderZoomRange(ftp)
+		assert.Equal(t, []int{ZOOM_LEVEL_MIN, ZOOM_LEVEL_MAX}, []int{min, max})
+		min, max = TileProviderZoomRange(&FileTileProvider{MinZoom: 4, MaxZoom: 8})
+		assert.Equal(t, []int{4, 8}, []int{min, max})
+	})
+}
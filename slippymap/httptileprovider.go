@@ -0,0 +1,124 @@
+package slippymap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrTileProviderUnreachable = errors.New("tile provider unreachable")
+)
+
+// unreachableError wraps errors connecting to the tile server, so they are both ErrTileProviderUnreachable
+// and the underlying error (eg: context.Canceled)
+type unreachableError struct {
+	err error
+}
+
+func (ue unreachableError) Error() string {
+	// returns the underlying error's text
+	return ue.err.Error()
+}
+
+func (ue unreachableError) Unwrap() error {
+	// returns the underlying error
+	return ue.err
+}
+
+func (ue unreachableError) Is(target error) bool {
+	// errors.Is(ue, ErrTileProviderUnreachable) is true
+	return target == ErrTileProviderUnreachable
+}
+
+// HTTPTileProvider downloads tiles from the URLs given by a TileAddressProvider
+type HTTPTileProvider struct {
+	httpClient *http.Client
+	addresses  TileAddressProvider
+}
+
+var _ TileDataProvider = &HTTPTileProvider{}
+
+func NewHTTPTileProvider(addresses TileAddressProvider) *HTTPTileProvider {
+	// Returns a tile provider downloading the tiles at the URLs given by addresses
+	return &HTTPTileProvider{
+		httpClient: &http.Client{
+			Transport: newTransportWithLimitedConcurrency(),
+		},
+		addresses: addresses,
+	}
+}
+
+func (htp *HTTPTileProvider) GetTileData(ctx context.Context, osm OSMTileID) ([]byte, error) {
+	// returns the tile osm as downloaded
+
+	// determine url
+	url, err := htp.addresses.GetTileAddress(osm)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare the request
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// set the header (requirement for using osm)
+	req.Header.Set("User-Agent", "pw_slippymap/0.1 https://github.com/plane-watch/pw-slippymap")
+
+	// and any the tile provider needs
+	if thp, ok := htp.addresses.(TileHeaderProvider); ok {
+		for k, v := range thp.TileHeaders() {
+			req.Header.Set(k, v)
+		}
+	}
+
+	// get the data
+	resp, err := htp.httpClient.Do(req)
+	if err != nil {
+		return nil, unreachableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	// check response code
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrTileNotFound, url)
+	default:
+		return nil, fmt.Errorf("downloading %s returned: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (htp *HTTPTileProvider) GetTile(ctx context.Context, osm OSMTileID) (image.Image, error) {
+	// returns the tile osm, downloaded
+	data, err := htp.GetTileData(ctx, osm)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTile(osm, data)
+}
+
+func (htp *HTTPTileProvider) ZoomRange() (min, max int) {
+	// returns the zoom levels of the tile addresses
+	return TileProviderZoomRange(htp.addresses)
+}
+
+func (htp *HTTPTileProvider) TileAttribution() string {
+	// returns the credit for the tile addresses' tiles
+	return TileProviderAttribution(htp.addresses)
+}
+
+func newTransportWithLimitedConcurrency() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	// We have 3 OSM CDN endpoints that we round robin, so max total conns is 3x this value
+	t.MaxConnsPerHost = 1
+
+	return t
+}
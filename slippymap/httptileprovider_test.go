@@ -0,0 +1,97 @@
+package slippymap
+
+import (
+	"context"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTileServer(t *testing.T, tile []byte) (server *httptest.Server, requests chan *http.Request) {
+	// returns a server with tile at /3/1/2.png, reporting each request on requests
+	t.Helper()
+	requests = make(chan *http.Request, 100)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		switch r.URL.Path {
+		case "/3/1/2.png":
+			w.Write(tile)
+		case "/slow.png":
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestHTTPTileProvider(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	server, requests := newTestTileServer(t, encodeTestTile(t, red))
+	htp := NewHTTPTileProvider(&TemplateTileProvider{
+		URLTemplate: server.URL + "/{z}/{x}/{y}.png",
+		MinZoom:     2,
+		MaxZoom:     10,
+		Attribution: "Test",
+		Headers:     map[string]string{"Authorization": "Bearer secret"},
+	})
+
+	t.Run("Test GetTile", func(t *testing.T) {
+		img, err := htp.GetTile(context.Background(), OSMTileID{x: 1, y: 2, zoom: 3})
+		require.NoError(t, err)
+		assertTileColour(t, red, img)
+
+		r := <-requests
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Contains(t, r.Header.Get("User-Agent"), "pw_slippymap")
+	})
+
+	t.Run("Test not found", func(t *testing.T) {
+		_, err := htp.GetTile(context.Background(), OSMTileID{x: 5, y: 5, zoom: 3})
+		assert.ErrorIs(t, err, ErrTileNotFound)
+		<-requests
+	})
+
+	t.Run("Test unavailable zoom level", func(t *testing.T) {
+		_, err := htp.GetTile(context.Background(), OSMTileID{x: 1, y: 2, zoom: 12})
+		assert.ErrorIs(t, err, ErrZoomLevelUnavailable)
+	})
+
+	t.Run("Test cancel", func(t *testing.T) {
+		slow := NewHTTPTileProvider(&TemplateTileProvider{URLTemplate: server.URL + "/slow.png", MaxZoom: 10})
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-requests
+			cancel()
+		}()
+		_, err := slow.GetTile(ctx, OSMTileID{x: 1, y: 2, zoom: 3})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, ErrTileProviderUnreachable)
+	})
+
+	t.Run("Test unreachable", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		unreachable := NewHTTPTileProvider(&TemplateTileProvider{URLTemplate: closed.URL + "/{z}/{x}/{y}.png", MaxZoom: 10})
+		_, err := unreachable.GetTile(context.Background(), OSMTileID{x: 1, y: 2, zoom: 3})
+		assert.ErrorIs(t, err, ErrTileProviderUnreachable)
+	})
+
+	t.Run("Test delegation", func(t *testing.T) {
+		min, max := TileProviderZoomRange(htp)
+		assert.Equal(t, []int{2, 10}, []int{min, max})
+		assert.Equal(t, "Test", TileProviderAttribution(htp))
+	})
+}
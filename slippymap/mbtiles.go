@@ -0,0 +1,206 @@
+package slippymap
+
+// MBTiles (https://github.com/mapbox/mbtiles-spec) is a SQLite file of map tiles, for use without internet.
+// Tiles are stored with TMS row numbering (row 0 is the southernmost), so y is flipped on lookup.
+// The SQLite driver isn't available in WASM (see mbtiles_sqlite.go), so OpenMBTiles fails there.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMBTilesFormat      = errors.New("not a raster MBTiles file")
+	ErrMBTilesUnsupported = errors.New("MBTiles is not supported on this platform")
+)
+
+var (
+	mbtilesRasterFormats   = []string{"png", "jpg", "jpeg", "webp"}             // tile formats we can decode
+	mbtilesDefaultMetadata = map[string]string{"minzoom": "0", "maxzoom": "22"} // if the file doesn't say
+)
+
+// MBTilesTileProvider reads raster tiles from an MBTiles file
+type MBTilesTileProvider struct {
+	db       *sql.DB
+	metadata map[string]string
+	minZoom  int
+	maxZoom  int
+}
+
+var _ TileDataProvider = &MBTilesTileProvider{}
+
+func OpenMBTiles(filePath string) (*MBTilesTileProvider, error) {
+	// Returns a tile provider reading tiles from the MBTiles file at filePath
+	_, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// build the DSN as a URL, so paths containing '?', '#' or '%' are escaped
+	dsn := &url.URL{Scheme: "file", Path: filePath, RawQuery: "mode=ro"}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMBTilesUnsupported, err)
+	}
+	mbt := &MBTilesTileProvider{
+		db:       db,
+		metadata: make(map[string]string),
+	}
+	err = mbt.loadMetadata()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return mbt, nil
+}
+
+func (mbt *MBTilesTileProvider) loadMetadata() error {
+	// reads the metadata table, and checks the tiles are a raster format we can decode
+	rows, err := mbt.db.Query("SELECT name, value FROM metadata")
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMBTilesFormat, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, value string
+		err = rows.Scan(&name, &value)
+		if err != nil {
+			return err
+		}
+		mbt.metadata[name] = value
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// format is required, but older files may not have it
+	format := mbt.metadata["format"]
+	if format != "" && !contains(mbtilesRasterFormats, format) {
+		return fmt.Errorf("%w: tile format is %s", ErrMBTilesFormat, format)
+	}
+
+	for _, name := range []string{"minzoom", "maxzoom"} {
+		if _, ok := mbt.metadata[name]; !ok {
+			mbt.metadata[name] = mbtilesDefaultMetadata[name]
+		}
+	}
+	mbt.minZoom, err = strconv.Atoi(mbt.metadata["minzoom"])
+	if err != nil {
+		return fmt.Errorf("%w: invalid minzoom: %s", ErrMBTilesFormat, err)
+	}
+	mbt.maxZoom, err = strconv.Atoi(mbt.metadata["maxzoom"])
+	if err != nil {
+		return fmt.Errorf("%w: invalid maxzoom: %s", ErrMBTilesFormat, err)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	// returns true if value is in values
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (mbt *MBTilesTileProvider) Close() error {
+	// Closes the MBTiles file
+	return mbt.db.Close()
+}
+
+func (mbt *MBTilesTileProvider) Metadata(name string) string {
+	// Returns a value from the MBTiles metadata table, eg: "name" or "description", or "" if it isn't set
+	return mbt.metadata[name]
+}
+
+func (mbt *MBTilesTileProvider) GetTileData(ctx context.Context, osm OSMTileID) ([]byte, error) {
+	// returns the tile osm as stored in the file, or ErrTileNotFound if the file doesn't have it
+	tmsY := (1 << osm.zoom) - 1 - osm.y
+	var data []byte
+	err := mbt.db.QueryRowContext(ctx, "SELECT tile_data FROM tiles WHERE zoom_level = ? AND tile_column = ? AND tile_row = ?", osm.zoom, osm.x, tmsY).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d/%d/%d", ErrTileNotFound, osm.zoom, osm.x, osm.y)
+	}
+	return data, err
+}
+
+func (mbt *MBTilesTileProvider) GetTile(ctx context.Context, osm OSMTileID) (image.Image, error) {
+	// returns the tile osm, or ErrTileNotFound if the file doesn't have it
+	data, err := mbt.GetTileData(ctx, osm)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTile(osm, data)
+}
+
+func (mbt *MBTilesTileProvider) ZoomRange() (min, max int) {
+	// returns the zoom levels in the file
+	return mbt.minZoom, mbt.maxZoom
+}
+
+func (mbt *MBTilesTileProvider) TileAttribution() string {
+	// returns the attribution from the file's metadata, which may contain HTML
+	return stripHTML(mbt.metadata["attribution"])
+}
+
+func stripHTML(s string) string {
+	// returns s without HTML tags, eg: attribution links
+	var b strings.Builder
+	inTag := false
+	for _, c := range s {
+		switch {
+		case c == '<':
+			inTag = true
+		case c == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(c)
+		}
+	}
+	return strings.Join(strings.Fields(strings.ReplaceAll(b.String(), "&copy;", "©")), " ")
+}
+
+func (mbt *MBTilesTileProvider) Bounds() (minLat, minLong, maxLat, maxLong float64, ok bool) {
+	// Returns the area the file has tiles for, from the "bounds" metadata (left,bottom,right,top)
+	v, ok := parseFloats(mbt.metadata["bounds"], 4)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	return v[1], v[0], v[3], v[2], true
+}
+
+func (mbt *MBTilesTileProvider) Centre() (lat, long float64, zoom int, ok bool) {
+	// Returns where to initially show the map, from the "center" metadata (long,lat,zoom), or the middle of the bounds
+	if v, ok := parseFloats(mbt.metadata["center"], 3); ok {
+		return v[1], v[0], int(v[2]), true
+	}
+	minLat, minLong, maxLat, maxLong, ok := mbt.Bounds()
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return (minLat + maxLat) / 2, (minLong + maxLong) / 2, mbt.minZoom, true
+}
+
+func parseFloats(s string, n int) (v []float64, ok bool) {
+	// returns the n comma separated numbers in s
+	fields := strings.Split(s, ",")
+	if len(fields) != n {
+		return nil, false
+	}
+	for _, f := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, false
+		}
+		v = append(v, x)
+	}
+	return v, true
+}
@@ -0,0 +1,9 @@
+//go:build !js
+
+// the SQLite driver does not build for webassembly
+
+package slippymap
+
+import (
+	_ "modernc.org/sqlite"
+)
@@ -0,0 +1,153 @@
+package slippymap
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createMBTiles(t *testing.T, metadata map[string]string, tiles map[[3]int]color.Color) string {
+	// returns the path to a new MBTiles file, with a single colour tile for each zoom/column/row (TMS) in tiles
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), "test.mbtiles")
+	db, err := sql.Open("sqlite", filePath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE metadata (name text, value text)")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)")
+	require.NoError(t, err)
+	for name, value := range metadata {
+		_, err = db.Exec("INSERT INTO metadata (name, value) VALUES (?, ?)", name, value)
+		require.NoError(t, err)
+	}
+	for zxy, c := range tiles {
+		img := image.NewRGBA(image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX))
+		img.Set(0, 0, c)
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+		_, err = db.Exec("INSERT INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)", zxy[0], zxy[1], zxy[2], buf.Bytes())
+		require.NoError(t, err)
+	}
+	return filePath
+}
+
+func TestMBTilesTileProvider(t *testing.T) {
+
+	// skip tests if webassembly
+	if runtime.GOOS == "js" {
+		t.SkipNow()
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	filePath := createMBTiles(t,
+		map[string]string{
+			"name":        "Perth",
+			"format":      "png",
+			"minzoom":     "3",
+			"maxzoom":     "5",
+			"bounds":      "115.5,-32.5,116.5,-31.5",
+			"attribution": `<a href="https://www.openstreetmap.org/copyright">&copy; OpenStreetMap</a>`,
+		},
+		map[[3]int]color.Color{
+			{3, 6, 3}: red,  // TMS row 3 at zoom 3 is XYZ y 4
+			{3, 6, 4}: blue, // XYZ y 3
+		},
+	)
+	mbt, err := OpenMBTiles(filePath)
+	require.NoError(t, err)
+	defer mbt.Close()
+
+	t.Run("Test tiles", func(t *testing.T) {
+		img, err := mbt.GetTile(context.Background(), OSMTileID{x: 6, y: 4, zoom: 3})
+		require.NoError(t, err)
+		assert.Equal(t, TILE_WIDTH_PX, img.Bounds().Dx())
+		r, _, b, _ := img.At(0, 0).RGBA()
+		assert.Equal(t, uint32(0xffff), r)
+		assert.Equal(t, uint32(0), b)
+
+		img, err = mbt.GetTile(context.Background(), OSMTileID{x: 6, y: 3, zoom: 3})
+		require.NoError(t, err)
+		_, _, b, _ = img.At(0, 0).RGBA()
+		assert.Equal(t, uint32(0xffff), b)
+
+		_, err = mbt.GetTile(context.Background(), OSMTileID{x: 1, y: 1, zoom: 3})
+		assert.ErrorIs(t, err, ErrTileNotFound)
+
+		// cancelled
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = mbt.GetTile(ctx, OSMTileID{x: 6, y: 4, zoom: 3})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Test metadata", func(t *testing.T) {
+		assert.Equal(t, "Perth", mbt.Metadata("name"))
+		assert.Equal(t, "© OpenStreetMap", TileProviderAttribution(mbt))
+
+		min, max := TileProviderZoomRange(mbt)
+		assert.Equal(t, 3, min)
+		assert.Equal(t, 5, max)
+
+		minLat, minLong, maxLat, maxLong, ok := mbt.Bounds()
+		assert.True(t, ok)
+		assert.Equal(t, []float64{-32.5, 115.5, -31.5, 116.5}, []float64{minLat, minLong, maxLat, maxLong})
+
+		// no center, so the middle of the bounds
+		lat, long, zoom, ok := mbt.Centre()
+		assert.True(t, ok)
+		assert.Equal(t, -32.0, lat)
+		assert.Equal(t, 116.0, long)
+		assert.Equal(t, 3, zoom)
+	})
+
+	t.Run("Test minimal metadata", func(t *testing.T) {
+		mbt, err := OpenMBTiles(createMBTiles(t, map[string]string{"center": "115.86,-31.95,9"}, nil))
+		require.NoError(t, err)
+		defer mbt.Close()
+		min, max := mbt.ZoomRange()
+		assert.Equal(t, 0, min)
+		assert.Equal(t, 22, max)
+		assert.Empty(t, TileProviderAttribution(mbt))
+		_, _, _, _, ok := mbt.Bounds()
+		assert.False(t, ok)
+		lat, long, zoom, ok := mbt.Centre()
+		assert.True(t, ok)
+		assert.Equal(t, []float64{-31.95, 115.86}, []float64{lat, long})
+		assert.Equal(t, 9, zoom)
+	})
+
+	t.Run("Test path needing escaping", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "perth #1 ?100%.mbtiles")
+		require.NoError(t, os.Rename(createMBTiles(t, map[string]string{"format": "png"}, map[[3]int]color.Color{{3, 6, 3}: red}), filePath))
+		mbt, err := OpenMBTiles(filePath)
+		require.NoError(t, err)
+		defer mbt.Close()
+		img, err := mbt.GetTile(context.Background(), OSMTileID{x: 6, y: 4, zoom: 3})
+		require.NoError(t, err)
+		r, _, _, _ := img.At(0, 0).RGBA()
+		assert.Equal(t, uint32(0xffff), r)
+	})
+
+	t.Run("Test invalid", func(t *testing.T) {
+		// vector tiles
+		_, err := OpenMBTiles(createMBTiles(t, map[string]string{"format": "pbf"}, nil))
+		assert.ErrorIs(t, err, ErrMBTilesFormat)
+
+		_, err = OpenMBTiles(filepath.Join(t.TempDir(), "missing.mbtiles"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,95 @@
+package slippymap
+
+import (
+	"container/list"
+	"context"
+	"image"
+	"sync"
+)
+
+const (
+	MEMORY_TILE_CACHE_SIZE = 256 // number of decoded tiles to keep in memory, a few screens worth
+)
+
+// MemoryTileProvider wraps another TileProvider to keep the most recently used tiles in memory, so
+// panning back & forth or zooming in & out doesn't re-read or re-decode them
+type MemoryTileProvider struct {
+	tileProvider TileProvider
+	size         int
+
+	mutex sync.Mutex
+	tiles map[OSMTileID]*list.Element // elements in lru
+	lru   *list.List                  // of *memoryTile, most recently used first
+}
+
+type memoryTile struct {
+	osm OSMTileID
+	img image.Image
+}
+
+var (
+	_ TileProvider = &MemoryTileProvider{}
+	_ TileCache    = &MemoryTileProvider{}
+)
+
+func NewMemoryTileProvider(tileProvider TileProvider, size int) *MemoryTileProvider {
+	// Returns a tile provider keeping the size most recently used tiles from tileProvider in memory
+	return &MemoryTileProvider{
+		tileProvider: tileProvider,
+		size:         size,
+		tiles:        make(map[OSMTileID]*list.Element),
+		lru:          list.New(),
+	}
+}
+
+func (mtp *MemoryTileProvider) GetTile(ctx context.Context, osm OSMTileID) (image.Image, error) {
+	// returns the tile osm from memory, or from the wrapped tile provider
+	mtp.mutex.Lock()
+	if e, ok := mtp.tiles[osm]; ok {
+		mtp.lru.MoveToFront(e)
+		mtp.mutex.Unlock()
+		return e.Value.(*memoryTile).img, nil
+	}
+	mtp.mutex.Unlock()
+
+	// don't hold the lock while loading, so tiles load concurrently
+	img, err := mtp.tileProvider.GetTile(ctx, osm)
+	if err != nil {
+		return nil, err
+	}
+
+	mtp.mutex.Lock()
+	defer mtp.mutex.Unlock()
+	if e, ok := mtp.tiles[osm]; ok {
+		// loaded concurrently
+		mtp.lru.MoveToFront(e)
+		return img, nil
+	}
+	mtp.tiles[osm] = mtp.lru.PushFront(&memoryTile{osm: osm, img: img})
+	for mtp.lru.Len() > mtp.size {
+		e := mtp.lru.Back()
+		mtp.lru.Remove(e)
+		delete(mtp.tiles, e.Value.(*memoryTile).osm)
+	}
+	return img, nil
+}
+
+func (mtp *MemoryTileProvider) CachedTile(osm OSMTileID) (img image.Image, ok bool) {
+	// returns the tile osm if it is in memory, without loading it
+	mtp.mutex.Lock()
+	defer mtp.mutex.Unlock()
+	if e, ok := mtp.tiles[osm]; ok {
+		return e.Value.(*memoryTile).img, true
+	}
+	return nil, false
+}
+
+func (mtp *MemoryTileProvider) ZoomRange() (min, max int) {
+	// returns the zoom levels of the wrapped tile provider
+	return TileProviderZoomRange(mtp.tileProvider)
+}
+
+func (mtp *MemoryTileProvider) TileAttribution() string {
+	// returns the credit for the wrapped tile provider's tiles
+	return TileProviderAttribution(mtp.tileProvider)
+}
@@ -0,0 +1,83 @@
+package slippymap
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingTileProvider returns a single colour tile, counting the requests for each tile
+type countingTileProvider struct {
+	mutex    sync.Mutex
+	requests map[OSMTileID]int
+}
+
+func (ctp *countingTileProvider) GetTile(ctx context.Context, osm OSMTileID) (image.Image, error) {
+	// returns a red tile, or ErrTileNotFound for tiles at zoom level 0
+	ctp.mutex.Lock()
+	defer ctp.mutex.Unlock()
+	ctp.requests[osm]++
+	if osm.zoom == 0 {
+		return nil, ErrTileNotFound
+	}
+	img := image.NewRGBA(image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	return img, nil
+}
+
+func TestMemoryTileProvider(t *testing.T) {
+	next := &countingTileProvider{requests: make(map[OSMTileID]int)}
+	mtp := NewMemoryTileProvider(next, 2)
+	ctx := context.Background()
+	a := OSMTileID{x: 1, y: 1, zoom: 3}
+	b := OSMTileID{x: 2, y: 1, zoom: 3}
+	c := OSMTileID{x: 3, y: 1, zoom: 3}
+
+	t.Run("Test cached", func(t *testing.T) {
+		img, err := mtp.GetTile(ctx, a)
+		require.NoError(t, err)
+		assertTileColour(t, color.RGBA{R: 255, A: 255}, img)
+		_, err = mtp.GetTile(ctx, a)
+		require.NoError(t, err)
+		assert.Equal(t, 1, next.requests[a])
+	})
+
+	t.Run("Test least recently used evicted", func(t *testing.T) {
+		_, err := mtp.GetTile(ctx, b)
+		require.NoError(t, err)
+		_, err = mtp.GetTile(ctx, a) // b is now least recently used
+		require.NoError(t, err)
+		_, err = mtp.GetTile(ctx, c)
+		require.NoError(t, err)
+
+		_, err = mtp.GetTile(ctx, a)
+		require.NoError(t, err)
+		assert.Equal(t, 1, next.requests[a])
+		_, err = mtp.GetTile(ctx, b)
+		require.NoError(t, err)
+		assert.Equal(t, 2, next.requests[b])
+	})
+
+	t.Run("Test CachedTile", func(t *testing.T) {
+		img, ok := mtp.CachedTile(a)
+		assert.True(t, ok)
+		assert.NotNil(t, img)
+		_, ok = mtp.CachedTile(OSMTileID{x: 9, y: 9, zoom: 9})
+		assert.False(t, ok)
+		assert.Zero(t, next.requests[OSMTileID{x: 9, y: 9, zoom: 9}])
+	})
+
+	t.Run("Test errors not cached", func(t *testing.T) {
+		missing := OSMTileID{zoom: 0}
+		_, err := mtp.GetTile(ctx, missing)
+		assert.ErrorIs(t, err, ErrTileNotFound)
+		_, err = mtp.GetTile(ctx, missing)
+		assert.ErrorIs(t, err, ErrTileNotFound)
+		assert.Equal(t, 2, next.requests[missing])
+	})
+}
@@ -53,3 +53,23 @@ func (osm *OSMTileID) GetNeighbour(direction int) OSMTileID {
 
 	return neighbour.enforceBounds()
 }
+
+func (osm *OSMTileID) GetAncestor(levels int) OSMTileID {
+	// return an OSMTileID of the tile levels zoom levels out that contains the tile defined by OSM
+	return OSMTileID{
+		x:    osm.x >> levels,
+		y:    osm.y >> levels,
+		zoom: osm.zoom - levels,
+	}
+}
+
+func (osm *OSMTileID) GetChildren() [4]OSMTileID {
+	// return OSMTileIDs of the four tiles one zoom level in that make up the tile defined by OSM
+	// in the order north west, north east, south west, south east
+	return [4]OSMTileID{
+		{x: osm.x * 2, y: osm.y * 2, zoom: osm.zoom + 1},
+		{x: osm.x*2 + 1, y: osm.y * 2, zoom: osm.zoom + 1},
+		{x: osm.x * 2, y: osm.y*2 + 1, zoom: osm.zoom + 1},
+		{x: osm.x*2 + 1, y: osm.y*2 + 1, zoom: osm.zoom + 1},
+	}
+}
@@ -143,4 +143,25 @@ func TestOSMTileID(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Test GetAncestor", func(t *testing.T) {
+		osm := OSMTileID{x: 26929, y: 19456, zoom: 15}
+		assert.Equal(t, OSMTileID{x: 13464, y: 9728, zoom: 14}, osm.GetAncestor(1))
+		assert.Equal(t, OSMTileID{x: 3366, y: 2432, zoom: 12}, osm.GetAncestor(3))
+		assert.Equal(t, osm, osm.GetAncestor(0))
+	})
+
+	t.Run("Test GetChildren", func(t *testing.T) {
+		osm := OSMTileID{x: 3, y: 5, zoom: 4}
+		children := osm.GetChildren()
+		assert.Equal(t, [4]OSMTileID{
+			{x: 6, y: 10, zoom: 5},
+			{x: 7, y: 10, zoom: 5},
+			{x: 6, y: 11, zoom: 5},
+			{x: 7, y: 11, zoom: 5},
+		}, children)
+		for _, child := range children {
+			assert.Equal(t, osm, child.GetAncestor(1))
+		}
+	})
 }
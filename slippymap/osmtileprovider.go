@@ -12,7 +12,7 @@ type OSMTileProvider struct {
 	osm_url_prefix int32
 }
 
-var _ TileProvider = &OSMTileProvider{}
+var _ TileAddressProvider = &OSMTileProvider{}
 
 func (op *OSMTileProvider) GetTileAddress(osm OSMTileID) (tilePath string, err error) {
 	var url string
@@ -0,0 +1,63 @@
+package slippymap
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+const (
+	OVERZOOM_MAX_LEVELS = 4 // zoom levels past the wrapped tile provider's maximum to scale tiles up to
+)
+
+// OverzoomTileProvider wraps another TileProvider to allow zooming past its maximum zoom level, by scaling up
+// the part of the tile at its maximum zoom level
+type OverzoomTileProvider struct {
+	tileProvider TileProvider
+}
+
+var (
+	_ TileProvider = &OverzoomTileProvider{}
+	_ TileCache    = &OverzoomTileProvider{}
+)
+
+func NewOverzoomTileProvider(tileProvider TileProvider) *OverzoomTileProvider {
+	// Returns a tile provider scaling up tiles from tileProvider past its maximum zoom level
+	return &OverzoomTileProvider{tileProvider: tileProvider}
+}
+
+func (otp *OverzoomTileProvider) GetTile(ctx context.Context, osm OSMTileID) (image.Image, error) {
+	// returns the tile osm from the wrapped tile provider, or scaled up from the tile at its maximum zoom level
+	_, max := TileProviderZoomRange(otp.tileProvider)
+	if osm.zoom <= max {
+		return otp.tileProvider.GetTile(ctx, osm)
+	}
+	levels := osm.zoom - max
+	if levels > OVERZOOM_MAX_LEVELS {
+		return nil, fmt.Errorf("%w: %d", ErrZoomLevelUnavailable, osm.zoom)
+	}
+	ancestor, err := otp.tileProvider.GetTile(ctx, osm.GetAncestor(levels))
+	if err != nil {
+		return nil, err
+	}
+	return cropTile(ancestor, osm, levels), nil
+}
+
+func (otp *OverzoomTileProvider) CachedTile(osm OSMTileID) (img image.Image, ok bool) {
+	// returns the tile osm if the wrapped tile provider has it in memory
+	if tc, ok := otp.tileProvider.(TileCache); ok {
+		return tc.CachedTile(osm)
+	}
+	return nil, false
+}
+
+func (otp *OverzoomTileProvider) ZoomRange() (min, max int) {
+	// returns the zoom levels of the wrapped tile provider, extended by OVERZOOM_MAX_LEVELS
+	min, max = TileProviderZoomRange(otp.tileProvider)
+	return min, max + OVERZOOM_MAX_LEVELS
+}
+
+func (otp *OverzoomTileProvider) TileAttribution() string {
+	// returns the credit for the wrapped tile provider's tiles
+	return TileProviderAttribution(otp.tileProvider)
+}
@@ -0,0 +1,60 @@
+package slippymap
+
+import (
+	"context"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zoomLimitedTileProvider is a countingTileProvider with tiles up to zoom level 10
+type zoomLimitedTileProvider struct {
+	countingTileProvider
+}
+
+func (zltp *zoomLimitedTileProvider) ZoomRange() (min, max int) {
+	// returns zoom levels 3 to 10
+	return 3, 10
+}
+
+func TestOverzoomTileProvider(t *testing.T) {
+	next := &zoomLimitedTileProvider{countingTileProvider{requests: make(map[OSMTileID]int)}}
+	otp := NewOverzoomTileProvider(NewMemoryTileProvider(next, MEMORY_TILE_CACHE_SIZE))
+	ctx := context.Background()
+
+	t.Run("Test zoom range", func(t *testing.T) {
+		min, max := TileProviderZoomRange(otp)
+		assert.Equal(t, []int{3, 10 + OVERZOOM_MAX_LEVELS}, []int{min, max})
+	})
+
+	t.Run("Test native zoom", func(t *testing.T) {
+		osm := OSMTileID{x: 100, y: 200, zoom: 10}
+		img, err := otp.GetTile(ctx, osm)
+		require.NoError(t, err)
+		assertTileColour(t, color.RGBA{R: 255, A: 255}, img)
+		assert.Equal(t, 1, next.requests[osm])
+
+		img, ok := otp.CachedTile(osm)
+		assert.True(t, ok)
+		assert.NotNil(t, img)
+	})
+
+	t.Run("Test overzoom", func(t *testing.T) {
+		// scaled up from the top left of 100/200 at zoom 10
+		osm := OSMTileID{x: 400, y: 800, zoom: 12}
+		img, err := otp.GetTile(ctx, osm)
+		require.NoError(t, err)
+		assert.Equal(t, TILE_WIDTH_PX, img.Bounds().Dx())
+		r, _, _, _ := img.At(0, 0).RGBA()
+		assert.NotZero(t, r)
+		assert.Zero(t, next.requests[osm])
+		assert.Equal(t, 1, next.requests[OSMTileID{x: 100, y: 200, zoom: 10}], "ancestor from memory")
+	})
+
+	t.Run("Test too far", func(t *testing.T) {
+		_, err := otp.GetTile(ctx, OSMTileID{zoom: 10 + OVERZOOM_MAX_LEVELS + 1})
+		assert.ErrorIs(t, err, ErrZoomLevelUnavailable)
+	})
+}
@@ -1,6 +1,7 @@
 package slippymap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	_ "image/png"
@@ -49,6 +50,11 @@ type mapTile struct {
 	// Alpha for smooth fade-in
 	alpha float64 // tile transparency (for fade-in)
 
+	// Loading state, eg: TILE_STATE_PENDING, protected by imgMutex
+	state int
+
+	// Cancels loading the tile image, if the tile is removed before it loads
+	cancel context.CancelFunc
 }
 
 type SlippyMap struct {
@@ -80,7 +86,10 @@ type SlippyMap struct {
 	offsetMaximumY int // maximum Y value for map tiles
 	offsetMutex    sync.Mutex
 
-	tileProvider TileProvider // the tile provider for the slippymap
+	tileProvider TileProvider       // the tile provider for the slippymap
+	ctx          context.Context    // parent context of tile loads
+	cancel       context.CancelFunc // cancels all tile loads, when the slippymap is replaced
+	tileRetrier  *tileRetrier       // wakes failed tiles when connectivity returns
 
 	aircraftDb *datasources.AircraftDB // aircraft db
 }
@@ -171,6 +180,7 @@ func (sm *SlippyMap) rmTile(tile *mapTile) {
 	}
 
 	if tileFound {
+		tile.cancel()
 		sm.tiles[tileIndex] = sm.tiles[len(sm.tiles)-1]
 		sm.tiles = sm.tiles[:len(sm.tiles)-1]
 		sm.scheduleDraw()
@@ -393,31 +403,10 @@ func (sm *SlippyMap) makeTile(osmX, osmY, offsetX, offsetY int) {
 		// Prepare image
 		img: ebiten.NewImage(TILE_WIDTH_PX, TILE_WIDTH_PX),
 	}
+	ctx, cancel := context.WithCancel(sm.ctx)
+	t.cancel = cancel
 
-	go func(t *mapTile, sm *SlippyMap) {
-
-		// get tile artwork
-		tilePath, err := sm.tileProvider.GetTileAddress(t.osm)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// load the image
-		img, _, err := ebitenutil.NewImageFromFile(tilePath)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// draw image
-		t.imgMutex.Lock()
-		t.img.DrawImage(img, nil)
-		t.imgMutex.Unlock()
-
-		// ensure ebiten updates & draws
-		sm.scheduleUpdate()
-		sm.scheduleDraw()
-
-	}(t, sm)
+	go sm.loadTile(ctx, t)
 
 	// Add tile to slippymap
 	t.imgMutex.Lock()
@@ -431,6 +420,11 @@ func (sm *SlippyMap) makeTile(osmX, osmY, offsetX, offsetY int) {
 	sm.scheduleDraw()
 }
 
+func (sm *SlippyMap) Close() {
+	// cancels loading tiles, once the slippymap has been replaced (eg: by SetSize or SetZoomLevel)
+	sm.cancel()
+}
+
 func (sm *SlippyMap) SetSize(mapWidthPx, mapHeightPx int) (newsm *SlippyMap) {
 	// todo fix race
 	// updates the slippy map when window size is changed
@@ -446,6 +440,7 @@ func (sm *SlippyMap) SetSize(mapWidthPx, mapHeightPx int) (newsm *SlippyMap) {
 
 	// copy the current map image into the zoom previous level background image
 	sm.Draw(newsm.zoomPrevLevelImg, false)
+	sm.Close()
 
 	return newsm
 }
@@ -543,6 +538,24 @@ func (sm *SlippyMap) LatLongToPixel(lat_deg, long_deg float64) (x, y int, err er
 	return x, y, nil
 }
 
+func (sm *SlippyMap) LatLongToPixelUnbounded(lat_deg, long_deg float64) (x, y float64, err error) {
+	// return the pixel x/y for a given lat/long, which may be off the edge of the slippymap
+	// (eg: for drawing polygons that extend past the visible area)
+
+	// find the tile for the given lat/long
+	osmX, osmY, offsetX, offsetY := gpsCoordsToTileInfo(lat_deg, long_deg, sm.zoomLevel)
+
+	// measure from any tile on the slippymap
+	sm.tilesMutex.Lock()
+	defer sm.tilesMutex.Unlock()
+	for _, t := range sm.tiles {
+		x = float64((osmX-t.osm.x)*TILE_WIDTH_PX+t.offsetX) + offsetX
+		y = float64((osmY-t.osm.y)*TILE_HEIGHT_PX+t.offsetY) + offsetY
+		return x, y, nil
+	}
+	return 0, 0, errors.New("No tiles")
+}
+
 func (sm *SlippyMap) ZoomIn(lat_deg, long_deg float64) (newsm *SlippyMap, err error) {
 	// zoom in, with map centred on given lat/long (in degrees)
 	newsm, err = sm.SetZoomLevel(sm.zoomLevel+1, lat_deg, long_deg)
@@ -558,8 +571,9 @@ func (sm *SlippyMap) ZoomOut(lat_deg, long_deg float64) (newsm *SlippyMap, err e
 func (sm *SlippyMap) SetZoomLevel(zoomLevel int, lat_deg, long_deg float64) (newsm *SlippyMap, err error) {
 	// sets zoom level, with map centred on given lat/long (in degrees)
 
-	// ensure we're within ZOOM_LEVEL_MAX & ZOOM_LEVEL_MIN
-	if zoomLevel > ZOOM_LEVEL_MAX || zoomLevel < ZOOM_LEVEL_MIN {
+	// ensure we're within ZOOM_LEVEL_MAX & ZOOM_LEVEL_MIN, and the zoom levels the tile provider has
+	minZoom, maxZoom := TileProviderZoomRange(sm.tileProvider)
+	if zoomLevel > maxZoom || zoomLevel < minZoom {
 		return &SlippyMap{}, errors.New("Requested zoom level unavailable")
 	}
 
@@ -570,6 +584,7 @@ func (sm *SlippyMap) SetZoomLevel(zoomLevel int, lat_deg, long_deg float64) (new
 
 	// copy the current map image into the zoom previous level background image
 	sm.Draw(newsm.zoomPrevLevelImg, false)
+	sm.Close()
 
 	// return the new slippymap and no error
 	return newsm, nil
@@ -591,7 +606,11 @@ func NewSlippyMap(
 	centreTileOSMX, centreTileOSMY, pixelOffsetX, pixelOffsetY := gpsCoordsToTileInfo(centreLat, centreLong, zoomLevel)
 
 	// create a new SlippyMap to return
+	ctx, cancel := context.WithCancel(context.Background())
 	sm = &SlippyMap{
+		ctx:              ctx,
+		cancel:           cancel,
+		tileRetrier:      newTileRetrier(),
 		img:              ebiten.NewImage(mapWidthPx, mapHeightPx), // initialise main image
 		zoomPrevLevelImg: ebiten.NewImage(mapWidthPx, mapHeightPx), // initialise image of previous zoom level
 		zoomLevel:        zoomLevel,                                // set zoom level
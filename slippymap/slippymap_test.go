@@ -29,7 +29,10 @@ func TestSlippyMap(t *testing.T) {
 
 	// get tile provider
 	t.Run("Test TileProviderForOS", func(t *testing.T) {
-		tileProvider, err = TileProviderForOS()
+		var tp *TemplateTileProvider
+		tp, err = GetTileProvider(DEFAULT_TILE_PROVIDER)
+		require.NoError(t, err, "GetTileProvider returned error")
+		tileProvider, err = TileProviderForOS(tp, DEFAULT_TILE_PROVIDER)
 		require.NoError(t, err, "TileProviderForOS returned error")
 	})
 
@@ -72,6 +75,20 @@ func TestSlippyMap(t *testing.T) {
 		assert.Equal(t, SLIPPYMAP_HEIGHT/2, y, "LatLongToPixel returned unexpected y")
 	})
 
+	// test LatLongToPixelUnbounded
+	t.Run("Test LatLongToPixelUnbounded", func(t *testing.T) {
+		x, y, err := smInitial.LatLongToPixelUnbounded(INIT_CENTRE_LAT, INIT_CENTRE_LONG)
+		require.NoError(t, err, "LatLongToPixelUnbounded returned error")
+		assert.InDelta(t, SLIPPYMAP_WIDTH/2, x, 1, "LatLongToPixelUnbounded returned unexpected x")
+		assert.InDelta(t, SLIPPYMAP_HEIGHT/2, y, 1, "LatLongToPixelUnbounded returned unexpected y")
+
+		// off the edge of the map
+		x, y, err = smInitial.LatLongToPixelUnbounded(INIT_CENTRE_LAT-10, INIT_CENTRE_LONG+10)
+		require.NoError(t, err, "LatLongToPixelUnbounded returned error")
+		assert.Greater(t, x, float64(SLIPPYMAP_WIDTH))
+		assert.Greater(t, y, float64(SLIPPYMAP_HEIGHT))
+	})
+
 	// test Update
 	t.Run("Test Update", func(t *testing.T) {
 		for i := 0; i <= 100; i++ {
@@ -0,0 +1,74 @@
+package slippymap
+
+// Stand-in tiles are synthesised from tiles already in memory at other zoom levels, and shown while a tile
+// loads: a parent tile cropped & scaled up, or the four child tiles scaled down.
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	STAND_IN_MAX_LEVELS = 4 // zoom levels out to look for a parent tile, at 4 the parent's 16x16 pixels are scaled to a whole tile
+)
+
+func cropTile(ancestor image.Image, osm OSMTileID, levels int) image.Image {
+	// returns the part of ancestor, the tile levels zoom levels out, covering osm, scaled to a whole tile
+	b := ancestor.Bounds()
+	w := b.Dx() >> levels
+	h := b.Dy() >> levels
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	mask := (1 << levels) - 1
+	sx := b.Min.X + (osm.x&mask)*w
+	sy := b.Min.Y + (osm.y&mask)*h
+	dst := image.NewRGBA(image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), ancestor, image.Rect(sx, sy, sx+w, sy+h), draw.Src, nil)
+	return dst
+}
+
+func mergeChildTiles(children [4]image.Image) image.Image {
+	// returns the children (in GetChildren order) scaled down into a whole tile, leaving missing children transparent
+	dst := image.NewRGBA(image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX))
+	for i, child := range children {
+		if child == nil {
+			continue
+		}
+		x := (i % 2) * TILE_WIDTH_PX / 2
+		y := (i / 2) * TILE_HEIGHT_PX / 2
+		draw.ApproxBiLinear.Scale(dst, image.Rect(x, y, x+TILE_WIDTH_PX/2, y+TILE_HEIGHT_PX/2), child, child.Bounds(), draw.Src, nil)
+	}
+	return dst
+}
+
+func standInTile(cache TileCache, osm OSMTileID) (img image.Image, ok bool) {
+	// returns a stand-in for tile osm from tiles in cache, preferring all four children (sharpest),
+	// then the nearest parent, then whichever children there are
+
+	var children [4]image.Image
+	numChildren := 0
+	for i, child := range osm.GetChildren() {
+		if children[i], ok = cache.CachedTile(child); ok {
+			numChildren++
+		}
+	}
+	if numChildren == len(children) {
+		return mergeChildTiles(children), true
+	}
+
+	for levels := 1; levels <= STAND_IN_MAX_LEVELS && levels <= osm.zoom; levels++ {
+		if ancestor, ok := cache.CachedTile(osm.GetAncestor(levels)); ok {
+			return cropTile(ancestor, osm, levels), true
+		}
+	}
+
+	if numChildren > 0 {
+		return mergeChildTiles(children), true
+	}
+	return nil, false
+}
@@ -0,0 +1,126 @@
+package slippymap
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testQuadrantColours = [4]color.RGBA{
+		{R: 255, A: 255},         // north west
+		{G: 255, A: 255},         // north east
+		{B: 255, A: 255},         // south west
+		{R: 255, G: 255, A: 255}, // south east
+	}
+)
+
+// mapTileCache is a TileCache of the tiles in the map
+type mapTileCache map[OSMTileID]image.Image
+
+func (mtc mapTileCache) CachedTile(osm OSMTileID) (image.Image, bool) {
+	// returns the tile osm, if it's in the map
+	img, ok := mtc[osm]
+	return img, ok
+}
+
+func solidTile(c color.Color) image.Image {
+	// returns a tile entirely coloured c
+	img := image.NewRGBA(image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func quadrantTile() image.Image {
+	// returns a tile with each quarter coloured by testQuadrantColours
+	img := image.NewRGBA(image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX))
+	for i, c := range testQuadrantColours {
+		x := (i % 2) * TILE_WIDTH_PX / 2
+		y := (i / 2) * TILE_HEIGHT_PX / 2
+		draw.Draw(img, image.Rect(x, y, x+TILE_WIDTH_PX/2, y+TILE_HEIGHT_PX/2), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func assertQuadrants(t *testing.T, img image.Image, expected [4]color.Color) {
+	// checks the centre of each quarter of img is the expected colour, nil for transparent
+	t.Helper()
+	for i, c := range expected {
+		if c == nil {
+			c = color.RGBA{}
+		}
+		x := (i%2)*TILE_WIDTH_PX/2 + TILE_WIDTH_PX/4
+		y := (i/2)*TILE_HEIGHT_PX/2 + TILE_HEIGHT_PX/4
+		assert.Equal(t, color.RGBAModel.Convert(c), color.RGBAModel.Convert(img.At(x, y)), "quadrant %d", i)
+	}
+}
+
+func TestCropTile(t *testing.T) {
+	parent := OSMTileID{x: 3, y: 5, zoom: 4}
+	for i, child := range parent.GetChildren() {
+		img := cropTile(quadrantTile(), child, 1)
+		assert.Equal(t, image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX), img.Bounds())
+		c := testQuadrantColours[i]
+		assertQuadrants(t, img, [4]color.Color{c, c, c, c})
+	}
+
+	// two levels, the north west quarter of the south east quarter
+	img := cropTile(quadrantTile(), OSMTileID{x: 3*4 + 2, y: 5*4 + 2, zoom: 6}, 2)
+	c := testQuadrantColours[3]
+	assertQuadrants(t, img, [4]color.Color{c, c, c, c})
+}
+
+func TestStandInTile(t *testing.T) {
+	osm := OSMTileID{x: 3, y: 5, zoom: 4}
+	children := osm.GetChildren()
+
+	t.Run("Test nothing cached", func(t *testing.T) {
+		_, ok := standInTile(mapTileCache{}, osm)
+		assert.False(t, ok)
+	})
+
+	t.Run("Test parent", func(t *testing.T) {
+		cache := mapTileCache{osm.GetAncestor(1): quadrantTile()}
+		img, ok := standInTile(cache, osm)
+		assert.True(t, ok)
+		c := testQuadrantColours[3] // 3/5 is the south east quarter of 1/2
+		assertQuadrants(t, img, [4]color.Color{c, c, c, c})
+	})
+
+	t.Run("Test distant ancestor", func(t *testing.T) {
+		cache := mapTileCache{osm.GetAncestor(3): solidTile(color.White)}
+		img, ok := standInTile(cache, osm)
+		assert.True(t, ok)
+		assertQuadrants(t, img, [4]color.Color{color.White, color.White, color.White, color.White})
+
+		_, ok = standInTile(mapTileCache{osm.GetAncestor(STAND_IN_MAX_LEVELS + 1): solidTile(color.White)}, OSMTileID{x: 3, y: 5, zoom: 8})
+		assert.False(t, ok)
+	})
+
+	t.Run("Test children", func(t *testing.T) {
+		cache := mapTileCache{osm.GetAncestor(1): solidTile(color.White)}
+		for i, child := range children {
+			cache[child] = solidTile(testQuadrantColours[i])
+		}
+		img, ok := standInTile(cache, osm)
+		assert.True(t, ok)
+		assertQuadrants(t, img, [4]color.Color{testQuadrantColours[0], testQuadrantColours[1], testQuadrantColours[2], testQuadrantColours[3]})
+	})
+
+	t.Run("Test some children", func(t *testing.T) {
+		// parent preferred over some children
+		cache := mapTileCache{osm.GetAncestor(1): solidTile(color.White), children[1]: solidTile(testQuadrantColours[1])}
+		img, ok := standInTile(cache, osm)
+		assert.True(t, ok)
+		assertQuadrants(t, img, [4]color.Color{color.White, color.White, color.White, color.White})
+
+		// but better than nothing
+		delete(cache, osm.GetAncestor(1))
+		img, ok = standInTile(cache, osm)
+		assert.True(t, ok)
+		assertQuadrants(t, img, [4]color.Color{nil, testQuadrantColours[1], nil, nil})
+	})
+}
@@ -0,0 +1,76 @@
+package slippymap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	ErrZoomLevelUnavailable = errors.New("tile provider has no tiles at this zoom level")
+)
+
+// TemplateTileProvider generates the URLs to XYZ tiles from a URL template, eg:
+// "https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png", where:
+//
+//	{s} is replaced by each of Subdomains in turn
+//	{z}, {x} & {y} are replaced by the tile's zoom level & coordinates
+//	{apikey} is replaced by APIKey
+//
+// Headers are sent by HTTPTileProvider, but in WASM the tile server must allow them (CORS), so API keys should
+// be in the URL where possible.
+type TemplateTileProvider struct {
+	URLTemplate string
+	Subdomains  []string          // eg: []string{"a", "b", "c"}, required if URLTemplate contains {s}
+	MinZoom     int               // lowest zoom level with tiles
+	MaxZoom     int               // highest zoom level with tiles
+	Attribution string            // credit to display on the map, eg: "© OpenStreetMap"
+	Headers     map[string]string // extra HTTP headers, eg: for authentication
+	APIKey      string
+
+	// used to round-robin requests across subdomains
+	nextSubdomain int32
+}
+
+var _ TileAddressProvider = &TemplateTileProvider{}
+
+func (tp *TemplateTileProvider) GetTileAddress(osm OSMTileID) (tilePath string, err error) {
+	// returns the URL of the tile osm
+	if osm.zoom < tp.MinZoom || osm.zoom > tp.MaxZoom {
+		return "", fmt.Errorf("%w: %d", ErrZoomLevelUnavailable, osm.zoom)
+	}
+
+	url := tp.URLTemplate
+	if strings.Contains(url, "{s}") {
+		if len(tp.Subdomains) == 0 {
+			return "", fmt.Errorf("tile URL template %s has {s} but no subdomains", tp.URLTemplate)
+		}
+		// atomic, as tiles are fetched concurrently
+		i := int(uint32(atomic.AddInt32(&tp.nextSubdomain, 1)-1) % uint32(len(tp.Subdomains)))
+		url = strings.ReplaceAll(url, "{s}", tp.Subdomains[i])
+	}
+	url = strings.NewReplacer(
+		"{z}", strconv.Itoa(osm.zoom),
+		"{x}", strconv.Itoa(osm.x),
+		"{y}", strconv.Itoa(osm.y),
+		"{apikey}", tp.APIKey,
+	).Replace(url)
+	return url, nil
+}
+
+func (tp *TemplateTileProvider) ZoomRange() (min, max int) {
+	// returns the lowest & highest zoom levels with tiles
+	return tp.MinZoom, tp.MaxZoom
+}
+
+func (tp *TemplateTileProvider) TileHeaders() map[string]string {
+	// returns the extra HTTP headers to send when downloading tiles
+	return tp.Headers
+}
+
+func (tp *TemplateTileProvider) TileAttribution() string {
+	// returns the credit to display on the map
+	return tp.Attribution
+}
@@ -0,0 +1,121 @@
+package slippymap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateTileProvider(t *testing.T) {
+
+	t.Run("Test subdomains", func(t *testing.T) {
+		tp := &TemplateTileProvider{
+			URLTemplate: "https://{s}.tile.example.com/{z}/{x}/{y}.png",
+			Subdomains:  []string{"a", "b"},
+			MaxZoom:     19,
+		}
+		for _, expected := range []string{
+			"https://a.tile.example.com/3/1/2.png",
+			"https://b.tile.example.com/3/1/2.png",
+			"https://a.tile.example.com/3/1/2.png",
+		} {
+			url, err := tp.GetTileAddress(OSMTileID{x: 1, y: 2, zoom: 3})
+			require.NoError(t, err)
+			assert.Equal(t, expected, url)
+		}
+	})
+
+	t.Run("Test API key", func(t *testing.T) {
+		tp := &TemplateTileProvider{
+			URLTemplate: "https://tiles.example.com/{z}/{x}/{y}.png?key={apikey}",
+			MaxZoom:     19,
+			APIKey:      "secret",
+		}
+		url, err := tp.GetTileAddress(OSMTileID{x: 10, y: 20, zoom: 5})
+		require.NoError(t, err)
+		assert.Equal(t, "https://tiles.example.com/5/10/20.png?key=secret", url)
+	})
+
+	t.Run("Test zoom range", func(t *testing.T) {
+		tp := &TemplateTileProvider{
+			URLTemplate: "https://tiles.example.com/{z}/{x}/{y}.png",
+			MinZoom:     4,
+			MaxZoom:     12,
+		}
+		_, err := tp.GetTileAddress(OSMTileID{zoom: 3})
+		assert.ErrorIs(t, err, ErrZoomLevelUnavailable)
+		_, err = tp.GetTileAddress(OSMTileID{zoom: 13})
+		assert.ErrorIs(t, err, ErrZoomLevelUnavailable)
+
+		min, max := TileProviderZoomRange(tp)
+		assert.Equal(t, 4, min)
+		assert.Equal(t, 12, max)
+
+		// limited to the zoom levels the map supports
+		min, max = TileProviderZoomRange(&TemplateTileProvider{MaxZoom: 22})
+		assert.Equal(t, ZOOM_LEVEL_MIN, min)
+		assert.Equal(t, ZOOM_LEVEL_MAX, max)
+		min, max = TileProviderZoomRange(&OSMTileProvider{})
+		assert.Equal(t, ZOOM_LEVEL_MIN, min)
+		assert.Equal(t, ZOOM_LEVEL_MAX, max)
+	})
+
+	t.Run("Test missing subdomains", func(t *testing.T) {
+		tp := &TemplateTileProvider{URLTemplate: "https://{s}.tile.example.com/{z}/{x}/{y}.png", MaxZoom: 19}
+		_, err := tp.GetTileAddress(OSMTileID{x: 1, y: 2, zoom: 3})
+		assert.Error(t, err)
+	})
+}
+
+func TestGetTileProvider(t *testing.T) {
+
+	t.Run("Test named", func(t *testing.T) {
+		assert.Contains(t, TileProviderNames(), DEFAULT_TILE_PROVIDER)
+		for _, name := range TileProviderNames() {
+			tp, err := GetTileProvider(name)
+			require.NoError(t, err, name)
+			assert.NotEmpty(t, TileProviderAttribution(tp), name)
+			_, err = tp.GetTileAddress(OSMTileID{x: 1, y: 2, zoom: 3})
+			assert.NoError(t, err, name)
+		}
+
+		tp, err := GetTileProvider("osm")
+		require.NoError(t, err)
+		url, err := tp.GetTileAddress(OSMTileID{x: 1, y: 2, zoom: 3})
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.tile.openstreetmap.org/3/1/2.png", url)
+	})
+
+	t.Run("Test headers not shared", func(t *testing.T) {
+		tp, err := GetTileProvider("osm")
+		require.NoError(t, err)
+		tp.Headers["X-Test"] = "1"
+		tp, err = GetTileProvider("osm")
+		require.NoError(t, err)
+		assert.Empty(t, tp.Headers)
+	})
+
+	t.Run("Test registered", func(t *testing.T) {
+		RegisterTileProvider("test", TemplateTileProvider{URLTemplate: "http://localhost/{z}/{x}/{y}.png", MaxZoom: 10, Attribution: "Test"})
+		assert.Contains(t, TileProviderNames(), "test")
+		tp, err := GetTileProvider("test")
+		require.NoError(t, err)
+		assert.Equal(t, "Test", tp.Attribution)
+	})
+
+	t.Run("Test URL template", func(t *testing.T) {
+		tp, err := GetTileProvider("https://{s}.example.com/{z}/{x}/{y}.png")
+		require.NoError(t, err)
+		url, err := tp.GetTileAddress(OSMTileID{x: 1, y: 2, zoom: 3})
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.example.com/3/1/2.png", url)
+		assert.Equal(t, "custom-", TileCacheName("https://{s}.example.com/{z}/{x}/{y}.png")[:7])
+		assert.Equal(t, "osm", TileCacheName("osm"))
+	})
+
+	t.Run("Test unknown", func(t *testing.T) {
+		_, err := GetTileProvider("nope")
+		assert.Error(t, err)
+	})
+}
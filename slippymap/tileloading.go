@@ -0,0 +1,132 @@
+package slippymap
+
+import (
+	"context"
+	"image/color"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+const (
+	TILE_STATE_PENDING = 0 // tile artwork loading
+	TILE_STATE_LOADED  = 1 // tile artwork loaded
+	TILE_STATE_FAILED  = 2 // tile artwork failed to load, showing placeholder & retrying
+	TILE_STATE_MISSING = 3 // tile provider has no artwork for tile, showing placeholder
+)
+
+var (
+	placeholderTileImg  *ebiten.Image
+	placeholderTileOnce sync.Once
+
+	placeholderTileFill   = color.RGBA{R: 0xd8, G: 0xd8, B: 0xd8, A: 0xff}
+	placeholderTileBorder = color.RGBA{R: 0xc0, G: 0xc0, B: 0xc0, A: 0xff}
+)
+
+func placeholderTile() *ebiten.Image {
+	// returns the neutral grey image drawn for tiles that failed to load
+	placeholderTileOnce.Do(func() {
+		placeholderTileImg = ebiten.NewImage(TILE_WIDTH_PX, TILE_HEIGHT_PX)
+		placeholderTileImg.Fill(placeholderTileFill)
+		ebitenutil.DrawRect(placeholderTileImg, 0, 0, TILE_WIDTH_PX, 1, placeholderTileBorder)
+		ebitenutil.DrawRect(placeholderTileImg, 0, 0, 1, TILE_HEIGHT_PX, placeholderTileBorder)
+	})
+	return placeholderTileImg
+}
+
+func (t *mapTile) setState(state int) {
+	// sets the loading state of the tile
+	t.imgMutex.Lock()
+	defer t.imgMutex.Unlock()
+	t.state = state
+}
+
+func (sm *SlippyMap) loadTile(ctx context.Context, t *mapTile) {
+	// loads the artwork for tile t, showing a stand-in while it loads, or a placeholder & retrying with
+	// exponential backoff if it fails
+	defer t.cancel()
+
+	// show a stand-in from tiles at other zoom levels while loading, replaced when the tile loads
+	standIn := false
+	if tc, ok := sm.tileProvider.(TileCache); ok {
+		if img, ok := standInTile(tc, t.osm); ok {
+			t.imgMutex.Lock()
+			t.img.DrawImage(ebiten.NewImageFromImage(img), nil)
+			t.imgMutex.Unlock()
+			standIn = true
+			sm.scheduleDraw()
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+
+		// get the retry channel before trying, so connectivity returning during the attempt isn't missed
+		retry := sm.tileRetrier.wait()
+
+		// get tile artwork
+		img, err := sm.tileProvider.GetTile(ctx, t.osm)
+		if ctx.Err() != nil {
+			// tile scrolled off the map, or the map was replaced
+			return
+		}
+		if err == nil {
+			t.imgMutex.Lock()
+			t.img.Clear()
+			t.img.DrawImage(ebiten.NewImageFromImage(img), nil)
+			t.state = TILE_STATE_LOADED
+			t.imgMutex.Unlock()
+			sm.tileRetrier.loaded()
+			sm.scheduleUpdate()
+			sm.scheduleDraw()
+			return
+		}
+
+		// show the placeholder, unless there's a stand-in
+		if attempt == 0 && !standIn {
+			t.imgMutex.Lock()
+			t.img.DrawImage(placeholderTile(), nil)
+			t.imgMutex.Unlock()
+			sm.scheduleDraw()
+		}
+
+		if tileErrorIsPermanent(err) {
+			log.Printf("slippymap: %s", err)
+			t.setState(TILE_STATE_MISSING)
+			return
+		}
+
+		t.setState(TILE_STATE_FAILED)
+		sm.tileRetrier.failed(err)
+		delay := tileRetryDelay(attempt)
+		log.Printf("slippymap: tile %d/%d/%d: %s, retrying in %s", t.osm.zoom, t.osm.x, t.osm.y, err, delay)
+
+		// wait to retry
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-retry:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (sm *SlippyMap) GetTileCounts() (pending, failed int) {
+	// returns the number of tiles still loading, and that failed to load (& are being retried)
+	for _, t := range sm.iterTiles() {
+		t.imgMutex.Lock()
+		switch t.state {
+		case TILE_STATE_PENDING:
+			pending++
+		case TILE_STATE_FAILED:
+			failed++
+		}
+		t.imgMutex.Unlock()
+	}
+	return pending, failed
+}
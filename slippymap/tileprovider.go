@@ -0,0 +1,91 @@
+package slippymap
+
+// Tile providers return the artwork for map tiles, and compose, eg:
+//
+//	NewOverzoomTileProvider(NewMemoryTileProvider(NewCachedTileProvider(dir, NewHTTPTileProvider(&TemplateTileProvider{...})), 256))
+//
+// Requests are cancelled through ctx when tiles scroll off the map, or the map is replaced.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrTileNotFound = errors.New("tile not found")
+)
+
+// TileProvider returns the image for a map tile
+type TileProvider interface {
+	GetTile(ctx context.Context, osm OSMTileID) (image.Image, error)
+}
+
+// TileDataProvider is a TileProvider that can also return tiles as they are stored (eg: PNG), so they can be cached
+type TileDataProvider interface {
+	TileProvider
+	GetTileData(ctx context.Context, osm OSMTileID) ([]byte, error)
+}
+
+// TileCache is a tile provider that can return tiles it already has without loading them, eg: for stand-ins
+type TileCache interface {
+	CachedTile(osm OSMTileID) (img image.Image, ok bool)
+}
+
+// TileAddressProvider generates the URLs of tiles, for HTTPTileProvider
+type TileAddressProvider interface {
+	GetTileAddress(osm OSMTileID) (tilePath string, err error)
+}
+
+// ZoomRangeProvider is a tile provider that only has tiles at some zoom levels
+type ZoomRangeProvider interface {
+	ZoomRange() (min, max int)
+}
+
+// TileHeaderProvider is a TileAddressProvider that needs extra HTTP headers sent when downloading tiles
+type TileHeaderProvider interface {
+	TileHeaders() map[string]string
+}
+
+// AttributionProvider is a tile provider whose tiles must be credited on the map
+type AttributionProvider interface {
+	TileAttribution() string
+}
+
+func TileProviderZoomRange(tileProvider interface{}) (min, max int) {
+	// Returns the zoom levels the map can show with tileProvider, within ZOOM_LEVEL_MIN & ZOOM_LEVEL_MAX
+	min, max = ZOOM_LEVEL_MIN, ZOOM_LEVEL_MAX
+	if zrp, ok := tileProvider.(ZoomRangeProvider); ok {
+		pMin, pMax := zrp.ZoomRange()
+		if pMin > min {
+			min = pMin
+		}
+		if pMax < max {
+			max = pMax
+		}
+	}
+	return min, max
+}
+
+func TileProviderAttribution(tileProvider interface{}) string {
+	// Returns the credit for tileProvider's tiles, or "" if none is needed
+	if ap, ok := tileProvider.(AttributionProvider); ok {
+		return ap.TileAttribution()
+	}
+	return ""
+}
+
+func decodeTile(osm OSMTileID, data []byte) (image.Image, error) {
+	// returns the PNG, JPEG or WebP tile image in data
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("tile %d/%d/%d: %w", osm.zoom, osm.x, osm.y, err)
+	}
+	return img, nil
+}
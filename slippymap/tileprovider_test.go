@@ -0,0 +1,45 @@
+package slippymap
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestTile(t *testing.T, c color.Color) []byte {
+	// returns a PNG tile with the top-left pixel coloured c
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, TILE_WIDTH_PX, TILE_HEIGHT_PX))
+	img.Set(0, 0, c)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func assertTileColour(t *testing.T, c color.Color, img image.Image) {
+	// checks the top-left pixel of the tile img is coloured c
+	t.Helper()
+	require.NotNil(t, img)
+	assert.Equal(t, TILE_WIDTH_PX, img.Bounds().Dx())
+	r, g, b, a := c.RGBA()
+	ir, ig, ib, ia := img.At(0, 0).RGBA()
+	assert.Equal(t, []uint32{r, g, b, a}, []uint32{ir, ig, ib, ia})
+}
+
+func TestDecodeTile(t *testing.T) {
+	t.Run("Test PNG", func(t *testing.T) {
+		img, err := decodeTile(OSMTileID{x: 1, y: 2, zoom: 3}, encodeTestTile(t, color.RGBA{R: 255, A: 255}))
+		require.NoError(t, err)
+		assertTileColour(t, color.RGBA{R: 255, A: 255}, img)
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		_, err := decodeTile(OSMTileID{x: 1, y: 2, zoom: 3}, []byte("<html>oops</html>"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "3/1/2")
+	})
+}
@@ -8,11 +8,14 @@ import (
 	"runtime"
 )
 
-// If we are running in WASM/JS, then the browser does all relevant tile caching for us.
-// If running in desktop app mode, we need to cache the tiles ourselves
-func TileProviderForOS() (TileProvider, error) {
+// Returns a tile provider downloading the tiles at the URLs given by addresses, keeping recent tiles in memory.
+// If we are running in WASM/JS, then the browser does all relevant tile caching on disk for us.
+// If running in desktop app mode, we need to cache the tiles ourselves, in a directory per tile provider
+// (cacheName) so tiles from different providers don't get mixed up
+func TileProviderForOS(addresses TileAddressProvider, cacheName string) (TileProvider, error) {
+	tileProvider := NewHTTPTileProvider(addresses)
 	if runtime.GOOS == "js" {
-		return &OSMTileProvider{}, nil
+		return NewMemoryTileProvider(tileProvider, MEMORY_TILE_CACHE_SIZE), nil
 	}
 
 	// try to get user home dir (for map cache)
@@ -35,5 +38,12 @@ func TileProviderForOS() (TileProvider, error) {
 		log.Fatal(err)
 	}
 
-	return NewCachedTileProvider(pathTileCache, &OSMTileProvider{}), nil
+	// create directory structure $HOME/.plane.watch/tilecache/<cacheName> if it doesn't exist
+	pathTileCache = path.Join(pathTileCache, cacheName)
+	err = localdata.MakeDirIfNotExist(pathTileCache, 0700)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return NewMemoryTileProvider(NewCachedTileProvider(pathTileCache, tileProvider), MEMORY_TILE_CACHE_SIZE), nil
 }
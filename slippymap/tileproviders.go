@@ -0,0 +1,104 @@
+package slippymap
+
+// named tile providers, selectable with --tiles
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DEFAULT_TILE_PROVIDER = "osm"
+)
+
+var (
+	tileProvidersMutex sync.Mutex
+	tileProviders      = map[string]TemplateTileProvider{
+		"osm": {
+			URLTemplate: "https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png",
+			Subdomains:  []string{"a", "b", "c"},
+			MinZoom:     0,
+			MaxZoom:     19,
+			Attribution: "© OpenStreetMap",
+		},
+		"carto-dark": {
+			URLTemplate: "https://{s}.basemaps.cartocdn.com/dark_all/{z}/{x}/{y}.png",
+			Subdomains:  []string{"a", "b", "c", "d"},
+			MinZoom:     0,
+			MaxZoom:     20,
+			Attribution: "© OpenStreetMap © CARTO",
+		},
+		"stamen-toner": {
+			// hosted by Stadia Maps, needs an API key for non-localhost use
+			URLTemplate: "https://tiles.stadiamaps.com/tiles/stamen_toner/{z}/{x}/{y}.png?api_key={apikey}",
+			MinZoom:     0,
+			MaxZoom:     20,
+			Attribution: "© Stadia Maps © Stamen Design © OpenStreetMap",
+		},
+		"local": {
+			// tileserver-gl (https://github.com/maptiler/tileserver-gl) with its default style
+			URLTemplate: "http://localhost:8080/styles/basic-preview/{z}/{x}/{y}.png",
+			MinZoom:     0,
+			MaxZoom:     20,
+			Attribution: "© OpenStreetMap",
+		},
+	}
+)
+
+func RegisterTileProvider(name string, tp TemplateTileProvider) {
+	// Adds (or replaces) the named tile provider
+	tileProvidersMutex.Lock()
+	defer tileProvidersMutex.Unlock()
+	tileProviders[name] = tp
+}
+
+func TileProviderNames() []string {
+	// Returns the names of the registered tile providers, sorted
+	tileProvidersMutex.Lock()
+	defer tileProvidersMutex.Unlock()
+	names := make([]string, 0, len(tileProviders))
+	for name := range tileProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func GetTileProvider(name string) (*TemplateTileProvider, error) {
+	// Returns a new tile provider for the named provider, or for name if it is a URL template
+	// subdomains default to a, b & c, as is usual
+	if strings.Contains(name, "{z}") {
+		return &TemplateTileProvider{
+			URLTemplate: name,
+			Subdomains:  []string{"a", "b", "c"},
+			MinZoom:     ZOOM_LEVEL_MIN,
+			MaxZoom:     ZOOM_LEVEL_MAX,
+		}, nil
+	}
+
+	tileProvidersMutex.Lock()
+	defer tileProvidersMutex.Unlock()
+	tp, ok := tileProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tile provider %q", name)
+	}
+
+	// copy, so providers don't share headers
+	headers := make(map[string]string, len(tp.Headers))
+	for k, v := range tp.Headers {
+		headers[k] = v
+	}
+	tp.Headers = headers
+	return &tp, nil
+}
+
+func TileCacheName(name string) string {
+	// Returns a directory name for caching tiles from the tile provider given to GetTileProvider as name
+	if strings.Contains(name, "{z}") {
+		return fmt.Sprintf("custom-%08x", crc32.ChecksumIEEE([]byte(name)))
+	}
+	return name
+}
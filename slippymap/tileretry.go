@@ -0,0 +1,68 @@
+package slippymap
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	TILE_RETRY_DELAY_MIN = time.Second // delay before retrying a failed tile, doubled each attempt
+	TILE_RETRY_DELAY_MAX = time.Minute // longest delay between retries, so tiles reload soon after connectivity returns
+)
+
+func tileRetryDelay(attempt int) time.Duration {
+	// returns how long to wait before retrying a tile that has failed attempt+1 times
+	delay := TILE_RETRY_DELAY_MIN
+	for i := 0; i < attempt && delay < TILE_RETRY_DELAY_MAX; i++ {
+		delay *= 2
+	}
+	if delay > TILE_RETRY_DELAY_MAX {
+		delay = TILE_RETRY_DELAY_MAX
+	}
+	return delay
+}
+
+func tileErrorIsPermanent(err error) bool {
+	// returns true if retrying won't help, eg: outside the area of an MBTiles file
+	return errors.Is(err, ErrTileNotFound) || errors.Is(err, ErrZoomLevelUnavailable)
+}
+
+// tileRetrier wakes tiles waiting to retry, when connectivity to the tile provider returns
+type tileRetrier struct {
+	mutex       sync.Mutex
+	retry       chan struct{} // closed to wake waiting tiles
+	unreachable bool          // the tile provider was unreachable on the last attempt
+}
+
+func newTileRetrier() *tileRetrier {
+	// returns a new tileRetrier
+	return &tileRetrier{retry: make(chan struct{})}
+}
+
+func (tr *tileRetrier) wait() <-chan struct{} {
+	// returns a channel closed when tiles should retry now
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.retry
+}
+
+func (tr *tileRetrier) failed(err error) {
+	// records a failed tile load
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if errors.Is(err, ErrTileProviderUnreachable) {
+		tr.unreachable = true
+	}
+}
+
+func (tr *tileRetrier) loaded() {
+	// records a loaded tile, waking any waiting tiles if the tile provider was unreachable
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if tr.unreachable {
+		tr.unreachable = false
+		close(tr.retry)
+		tr.retry = make(chan struct{})
+	}
+}
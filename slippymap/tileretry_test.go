@@ -0,0 +1,65 @@
+package slippymap
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTileRetryDelay(t *testing.T) {
+	assert.Equal(t, TILE_RETRY_DELAY_MIN, tileRetryDelay(0))
+	assert.Equal(t, 2*TILE_RETRY_DELAY_MIN, tileRetryDelay(1))
+	assert.Equal(t, 8*TILE_RETRY_DELAY_MIN, tileRetryDelay(3))
+	assert.Equal(t, TILE_RETRY_DELAY_MAX, tileRetryDelay(10))
+	assert.Equal(t, TILE_RETRY_DELAY_MAX, tileRetryDelay(1000))
+}
+
+func TestTileErrorIsPermanent(t *testing.T) {
+	assert.True(t, tileErrorIsPermanent(fmt.Errorf("%w: 3/1/2", ErrTileNotFound)))
+	assert.True(t, tileErrorIsPermanent(fmt.Errorf("%w: 17", ErrZoomLevelUnavailable)))
+	assert.False(t, tileErrorIsPermanent(errors.New("downloading returned: 500 Internal Server Error")))
+	assert.False(t, tileErrorIsPermanent(unreachableError{err: errors.New("connection refused")}))
+}
+
+func TestTileRetrier(t *testing.T) {
+
+	isClosed := func(c <-chan struct{}) bool {
+		select {
+		case <-c:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}
+
+	t.Run("Test loaded without failures", func(t *testing.T) {
+		tr := newTileRetrier()
+		retry := tr.wait()
+		tr.loaded()
+		assert.False(t, isClosed(retry))
+	})
+
+	t.Run("Test server errors don't wake", func(t *testing.T) {
+		tr := newTileRetrier()
+		retry := tr.wait()
+		tr.failed(errors.New("downloading returned: 500 Internal Server Error"))
+		tr.loaded()
+		assert.False(t, isClosed(retry))
+	})
+
+	t.Run("Test connectivity returns", func(t *testing.T) {
+		tr := newTileRetrier()
+		retry := tr.wait()
+		tr.failed(unreachableError{err: errors.New("connection refused")})
+		tr.loaded()
+		assert.True(t, isClosed(retry))
+
+		// only wakes once
+		retry = tr.wait()
+		tr.loaded()
+		assert.False(t, isClosed(retry))
+	})
+}
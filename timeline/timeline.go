@@ -0,0 +1,171 @@
+package timeline
+
+// this module contains the timeline scrubber shown when replaying a recording
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	TIMELINE_HEIGHT       = 40  // pixels, including text
+	TIMELINE_BAR_HEIGHT   = 8   // pixels
+	TIMELINE_MARGIN       = 20  // pixels either side of the bar
+	TIMELINE_SKIP_SECONDS = 10  // left/right arrow keys skip by this much
+	TIMELINE_BG_ALPHA     = 166 // background opacity
+)
+
+// speeds selectable with the number keys 1, 2, 3
+var PlaybackSpeeds = []float64{1, 2, 10}
+
+// Player is something that can be scrubbed with a Timeline, eg: datasources.ReplaySource
+type Player interface {
+	StartTime() time.Time
+	Duration() time.Duration
+	Position() time.Duration
+	Seek(position time.Duration)
+	Speed() float64
+	SetSpeed(speed float64)
+	Paused() bool
+	Pause()
+	Resume()
+}
+
+// Timeline draws a playback position bar and handles input to control a Player
+type Timeline struct {
+	player   Player
+	bounds   image.Rectangle // area of the screen the timeline occupies
+	dragging bool            // true while the user is dragging the playhead
+}
+
+func NewTimeline(player Player) *Timeline {
+	// Returns a Timeline that controls player
+	return &Timeline{player: player}
+}
+
+func (t *Timeline) SetBounds(bounds image.Rectangle) {
+	// sets the area of the screen the timeline occupies
+	t.bounds = bounds
+}
+
+func (t *Timeline) Contains(x, y int) bool {
+	// returns true if x, y is on the timeline
+	return image.Pt(x, y).In(t.bounds)
+}
+
+func (t *Timeline) barRect() image.Rectangle {
+	// returns the area of the screen the bar occupies
+	x0 := t.bounds.Min.X + TIMELINE_MARGIN
+	x1 := t.bounds.Max.X - TIMELINE_MARGIN
+	y0 := t.bounds.Max.Y - TIMELINE_MARGIN/2 - TIMELINE_BAR_HEIGHT
+	return image.Rect(x0, y0, x1, y0+TIMELINE_BAR_HEIGHT)
+}
+
+func (t *Timeline) positionAtPixel(x int) time.Duration {
+	// returns the playback position under pixel x
+	bar := t.barRect()
+	if bar.Dx() <= 0 {
+		return 0
+	}
+	fraction := float64(x-bar.Min.X) / float64(bar.Dx())
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return time.Duration(fraction * float64(t.player.Duration()))
+}
+
+func (t *Timeline) Update() {
+	// handles keyboard & mouse input
+
+	// space: play/pause
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		if t.player.Paused() {
+			t.player.Resume()
+		} else {
+			t.player.Pause()
+		}
+		ebiten.ScheduleFrame()
+	}
+
+	// 1, 2, 3: playback speed
+	for i, key := range []ebiten.Key{ebiten.KeyDigit1, ebiten.KeyDigit2, ebiten.KeyDigit3} {
+		if i < len(PlaybackSpeeds) && inpututil.IsKeyJustPressed(key) {
+			t.player.SetSpeed(PlaybackSpeeds[i])
+			ebiten.ScheduleFrame()
+		}
+	}
+
+	// left/right arrows: skip
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+		t.player.Seek(t.player.Position() - time.Second*TIMELINE_SKIP_SECONDS)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		t.player.Seek(t.player.Position() + time.Second*TIMELINE_SKIP_SECONDS)
+	}
+
+	// mouse: click or drag on the timeline to seek
+	mouseX, mouseY := ebiten.CursorPosition()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && t.Contains(mouseX, mouseY) {
+		t.dragging = true
+	}
+	if t.dragging {
+		t.player.Seek(t.positionAtPixel(mouseX))
+		ebiten.ScheduleFrame()
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			t.dragging = false
+		}
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	// formats d as hh:mm:ss
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+func (t *Timeline) Draw(screen *ebiten.Image) {
+	// draws the timeline
+
+	position := t.player.Position()
+	duration := t.player.Duration()
+
+	// background
+	bg := ebiten.NewImage(t.bounds.Dx(), t.bounds.Dy())
+	bg.Fill(color.RGBA{R: 0, G: 0, B: 0, A: TIMELINE_BG_ALPHA})
+	bgDio := &ebiten.DrawImageOptions{}
+	bgDio.GeoM.Translate(float64(t.bounds.Min.X), float64(t.bounds.Min.Y))
+	screen.DrawImage(bg, bgDio)
+
+	// status text
+	state := "Playing"
+	if t.player.Paused() {
+		state = "Paused"
+	}
+	statusText := fmt.Sprintf("Replay: %s %gx  %s / %s  (%s)  [space: play/pause, 1/2/3: 1x/2x/10x, arrows: skip]",
+		state,
+		t.player.Speed(),
+		formatDuration(position),
+		formatDuration(duration),
+		t.player.StartTime().Add(position).Format("2006-01-02 15:04:05 MST"),
+	)
+	ebitenutil.DebugPrintAt(screen, statusText, t.bounds.Min.X+TIMELINE_MARGIN, t.bounds.Min.Y+2)
+
+	// bar, played portion & playhead
+	bar := t.barRect()
+	ebitenutil.DrawRect(screen, float64(bar.Min.X), float64(bar.Min.Y), float64(bar.Dx()), float64(bar.Dy()), color.RGBA{R: 100, G: 100, B: 100, A: 255})
+	played := 0.0
+	if duration > 0 {
+		played = float64(bar.Dx()) * float64(position) / float64(duration)
+	}
+	ebitenutil.DrawRect(screen, float64(bar.Min.X), float64(bar.Min.Y), played, float64(bar.Dy()), color.RGBA{R: 80, G: 160, B: 255, A: 255})
+	ebitenutil.DrawRect(screen, float64(bar.Min.X)+played-2, float64(bar.Min.Y)-4, 4, float64(bar.Dy())+8, color.White)
+}
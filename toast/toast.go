@@ -0,0 +1,72 @@
+package toast
+
+// this module contains short-lived notifications ("toasts") shown at the top right of the screen
+
+import (
+	"image/color"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+const (
+	TOAST_DURATION_SECONDS = 8   // how long each toast is shown
+	TOAST_MAX              = 5   // maximum toasts shown at once, oldest are dropped
+	TOAST_HEIGHT           = 20  // pixels
+	TOAST_MARGIN           = 10  // pixels from the screen edge & between toasts
+	TOAST_CHAR_WIDTH       = 6   // pixels per character of the debug font
+	TOAST_BG_ALPHA         = 200 // background opacity
+)
+
+type toast struct {
+	text    string
+	expires time.Time
+}
+
+// Toasts is a stack of notifications, safe to Add to from any goroutine
+type Toasts struct {
+	mutex  sync.Mutex
+	toasts []toast
+}
+
+func (ts *Toasts) Add(text string) {
+	// shows text for TOAST_DURATION_SECONDS
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.toasts = append(ts.toasts, toast{text: text, expires: time.Now().Add(time.Second * TOAST_DURATION_SECONDS)})
+	if len(ts.toasts) > TOAST_MAX {
+		ts.toasts = ts.toasts[len(ts.toasts)-TOAST_MAX:]
+	}
+	ebiten.ScheduleFrame()
+}
+
+func (ts *Toasts) Active() bool {
+	// returns true if any toasts are being shown, removing expired toasts
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	now := time.Now()
+	i := 0
+	for i < len(ts.toasts) && now.After(ts.toasts[i].expires) {
+		i++
+	}
+	ts.toasts = ts.toasts[i:]
+	return len(ts.toasts) > 0
+}
+
+func (ts *Toasts) Draw(screen *ebiten.Image) {
+	// draws the toasts at the top right of screen, newest at the top
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	w, _ := screen.Size()
+	y := TOAST_MARGIN
+	for i := len(ts.toasts) - 1; i >= 0; i-- {
+		t := ts.toasts[i]
+		tw := (len(t.text) * TOAST_CHAR_WIDTH) + TOAST_MARGIN
+		x := w - tw - TOAST_MARGIN
+		ebitenutil.DrawRect(screen, float64(x), float64(y), float64(tw), TOAST_HEIGHT, color.RGBA{R: 0, G: 0, B: 0, A: TOAST_BG_ALPHA})
+		ebitenutil.DebugPrintAt(screen, t.text, x+(TOAST_MARGIN/2), y+2)
+		y += TOAST_HEIGHT + (TOAST_MARGIN / 2)
+	}
+}